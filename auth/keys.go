@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// APIKey represents a static API key accepted from MCP clients. Only the
+// SHA-256 hash of the key is configured, never the key itself.
+type APIKey struct {
+	Name    string   `json:"name"`
+	Hash    string   `json:"hash"`
+	Tools   []string `json:"tools"`
+	Schemas []string `json:"schemas"`
+
+	digest []byte
+}
+
+// HashAPIKey returns the hash to configure for a key, in the same format
+// as `printf %s "$KEY" | sha256sum`, prefixed with sha256:
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// LoadAPIKeys parses the keys configured inline (MCP_API_KEYS) and in a
+// file (MCP_API_KEYS_FILE), both JSON arrays of APIKey
+func LoadAPIKeys(inline, file string) ([]APIKey, error) {
+	var keys []APIKey
+
+	if strings.TrimSpace(inline) != "" {
+		parsed, err := parseAPIKeys([]byte(inline))
+		if err != nil {
+			return nil, fmt.Errorf("invalid MCP_API_KEYS: %w", err)
+		}
+		keys = append(keys, parsed...)
+	}
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read MCP_API_KEYS_FILE: %w", err)
+		}
+		parsed, err := parseAPIKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MCP_API_KEYS_FILE %s: %w", file, err)
+		}
+		keys = append(keys, parsed...)
+	}
+
+	return keys, nil
+}
+
+func parseAPIKeys(data []byte) ([]APIKey, error) {
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for i := range keys {
+		key := &keys[i]
+		if key.Name == "" {
+			return nil, fmt.Errorf("key %d has no name", i+1)
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("key name %q is used twice", key.Name)
+		}
+		names[key.Name] = true
+
+		digest, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(key.Hash), "sha256:"))
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("key %q: hash must be the hex SHA-256 of the key", key.Name)
+		}
+		key.digest = digest
+	}
+	return keys, nil
+}
+
+// matchAPIKey returns the configured key matching token. Every key is
+// compared in constant time so timing reveals nothing about the others.
+func matchAPIKey(keys []APIKey, token string) *APIKey {
+	sum := sha256.Sum256([]byte(token))
+
+	var match *APIKey
+	for i := range keys {
+		if subtle.ConstantTimeCompare(keys[i].digest, sum[:]) == 1 {
+			match = &keys[i]
+		}
+	}
+	return match
+}
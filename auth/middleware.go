@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/mcp"
+	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Errors returned by Authenticate
+var (
+	ErrMissingCredentials = errors.New("authentication required: send an API key or a JWT as a Bearer token")
+	ErrInvalidCredentials = errors.New("invalid API key or token")
+	ErrNoAccess           = errors.New("token does not grant access to this server")
+)
+
+// Authenticator checks the credentials of MCP clients and enforces the
+// tools and schemas each one may use. It implements mcp.Guard.
+type Authenticator struct {
+	required  bool
+	keys      []APIKey
+	jwtSecret []byte
+
+	// Tokens signed with jwtSecret may use the service role or an mcp
+	// grant only when issued by one of keyIssuers
+	keyIssuers []string
+
+	// OAuth: user access tokens must come from one of issuers and carry
+	// audience; their roles are mapped to permissions with roles
+	publicURL           string
+	authorizationServer string
+	issuers             []string
+	audience            string
+	roles               map[string]Permissions
+
+	// stub, when set, is the local test issuer whose tokens are accepted
+	stub *StubIssuer
+}
+
+// NewAuthenticator creates an authenticator from the configured API keys,
+// the Supabase JWT secret and the OAuth settings
+func NewAuthenticator(cfg *config.Config) (*Authenticator, error) {
+	keys, err := LoadAPIKeys(cfg.Auth.APIKeys, cfg.Auth.APIKeysFile)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := parseRolePermissions(cfg.Auth.RolePermissions)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Authenticator{
+		required:            cfg.Auth.Required,
+		keys:                keys,
+		jwtSecret:           []byte(cfg.Supabase.JWTSecret),
+		keyIssuers:          cfg.Auth.KeyIssuers,
+		publicURL:           cfg.Auth.PublicURL,
+		authorizationServer: cfg.Auth.OAuthIssuer,
+		issuers:             []string{cfg.Auth.OAuthIssuer},
+		audience:            cfg.Auth.OAuthAudience,
+		roles:               roles,
+	}
+	if cfg.Auth.StubIssuer {
+		// Advertise the stub so that clients run the flow against it
+		a.authorizationServer = StubIssuerURL(cfg.Auth.PublicURL)
+	}
+
+	switch {
+	case !a.required:
+		logrus.Warn("MCP_AUTH_REQUIRED=false: requests without credentials get full service-role access")
+	case len(a.keys) == 0 && len(a.jwtSecret) == 0:
+		logrus.Warn("No MCP_API_KEYS and no SUPABASE_JWT_SECRET configured: every request will be rejected")
+	}
+	return a, nil
+}
+
+// Authenticate resolves a bearer token to a principal
+func (a *Authenticator) Authenticate(token string) (*Principal, error) {
+	if key := matchAPIKey(a.keys, token); key != nil {
+		return &Principal{
+			Name:    key.Name,
+			Method:  MethodAPIKey,
+			ID:      MethodAPIKey + ":" + key.Name,
+			Tools:   key.Tools,
+			Schemas: key.Schemas,
+		}, nil
+	}
+
+	if !utils.LooksLikeJWT(token) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if a.stub != nil {
+		claims, err := utils.VerifyJWT(a.stub.key, token)
+		if err == nil {
+			return a.userPrincipal(claims, []string{a.stub.issuer})
+		}
+		if !errors.Is(err, utils.ErrJWTInvalid) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+	}
+
+	if len(a.jwtSecret) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	claims, err := utils.VerifyJWT(a.jwtSecret, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return a.jwtPrincipal(claims)
+}
+
+// TrustStubIssuer accepts the tokens of the stub authorization server,
+// which are signed with its own key
+func (a *Authenticator) TrustStubIssuer(stub *StubIssuer) {
+	a.stub = stub
+}
+
+// ImpersonationIssuer is the iss of the tokens minted to run a call as an
+// end user. They are signed with the Supabase JWT secret so that PostgREST
+// accepts them, and are never accepted here, whatever the configured
+// issuers.
+const ImpersonationIssuer = "supabase-mcp-impersonation"
+
+// jwtPrincipal maps the claims of a Supabase JWT to a principal after
+// checking its issuer:
+//   - user access tokens from GoTrue get the permissions of their roles
+//   - tokens from one of keyIssuers, such as the Supabase API keys, have
+//     full access with the service role, or get the tools and schemas
+//     listed in an mcp claim
+//
+// Tokens from any other issuer are rejected, and the public anon key
+// grants nothing.
+func (a *Authenticator) jwtPrincipal(claims utils.Claims) (*Principal, error) {
+	issuer := claims.String("iss")
+	if issuer == ImpersonationIssuer {
+		return nil, fmt.Errorf("%w: impersonation tokens cannot authenticate MCP clients", ErrInvalidCredentials)
+	}
+	if contains(a.issuers, issuer) {
+		return a.userPrincipal(claims, a.issuers)
+	}
+	if !contains(a.keyIssuers, issuer) {
+		return nil, fmt.Errorf("%w: token issuer %q is not trusted", ErrInvalidCredentials, issuer)
+	}
+
+	principal := claimsPrincipal(claims)
+	if claims.String("role") == "service_role" {
+		return principal, nil
+	}
+
+	if grant, ok := claims["mcp"].(map[string]interface{}); ok {
+		var err error
+		if principal.Tools, err = stringList(grant["tools"]); err != nil {
+			return nil, fmt.Errorf("%w: mcp.tools %v", ErrInvalidCredentials, err)
+		}
+		if principal.Schemas, err = stringList(grant["schemas"]); err != nil {
+			return nil, fmt.Errorf("%w: mcp.schemas %v", ErrInvalidCredentials, err)
+		}
+		return principal, nil
+	}
+	return nil, ErrNoAccess
+}
+
+// userPrincipal gives a user access token from one of issuers the
+// permissions of its roles
+func (a *Authenticator) userPrincipal(claims utils.Claims, issuers []string) (*Principal, error) {
+	if claims.String("sub") == "" {
+		return nil, ErrNoAccess
+	}
+	if !contains(issuers, claims.String("iss")) {
+		return nil, fmt.Errorf("%w: token was not issued by %s", ErrInvalidCredentials, strings.Join(issuers, " or "))
+	}
+	if !hasAudience(claims, a.audience) {
+		return nil, fmt.Errorf("%w: token audience is not %s", ErrInvalidCredentials, a.audience)
+	}
+
+	var grants []Permissions
+	for _, role := range userRoles(claims) {
+		if grant, ok := a.roles[role]; ok {
+			grants = append(grants, grant)
+		}
+	}
+	if len(grants) == 0 {
+		return nil, ErrNoAccess
+	}
+	granted := mergePermissions(grants)
+	principal := claimsPrincipal(claims)
+	principal.Tools = granted.Tools
+	principal.Schemas = granted.Schemas
+	return principal, nil
+}
+
+// claimsPrincipal names the principal of a JWT after its email, subject
+// or role, and identifies it by its issuer and subject or role
+func claimsPrincipal(claims utils.Claims) *Principal {
+	subject := claims.String("sub")
+	if subject == "" {
+		subject = claims.String("role")
+	}
+	name := claims.String("email")
+	if name == "" {
+		name = subject
+	}
+	return &Principal{Name: name, Method: MethodJWT, ID: MethodJWT + ":" + claims.String("iss") + "#" + subject}
+}
+
+// stringList converts a claim to a list; a missing claim gives nil, which
+// allows everything
+func stringList(value interface{}) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be an array of strings")
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Middleware authenticates requests and stores the principal in the
+// request context. Requests without credentials are rejected with 401
+// unless authentication is optional.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" {
+			if !a.required {
+				return
+			}
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="mcp", resource_metadata=%q`, a.ResourceMetadataURL()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrMissingCredentials.Error()})
+			return
+		}
+
+		principal, err := a.Authenticate(token)
+		if errors.Is(err, ErrNoAccess) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="mcp", error="invalid_token", resource_metadata=%q`, a.ResourceMetadataURL()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), principal))
+	}
+}
+
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// AllowTool implements mcp.Guard
+func (a *Authenticator) AllowTool(ctx context.Context, tool mcp.Tool) bool {
+	principal := FromContext(ctx)
+	if principal == nil {
+		return true
+	}
+	if !principal.AllowTool(tool.Name) {
+		return false
+	}
+	// Tools that reach every schema are hidden from restricted principals
+	return principal.Schemas == nil || !tool.AnySchema
+}
+
+// CallerID implements mcp.Guard with the ID of the principal
+func (a *Authenticator) CallerID(ctx context.Context) string {
+	return IDFromContext(ctx)
+}
+
+// CheckCall implements mcp.Guard by checking the schema a call works on
+func (a *Authenticator) CheckCall(ctx context.Context, tool mcp.Tool, arguments map[string]interface{}) error {
+	principal := FromContext(ctx)
+	if principal == nil || principal.Schemas == nil || tool.SchemaArgument == "" {
+		return nil
+	}
+
+	schema, _ := arguments[tool.SchemaArgument].(string)
+	if schema == "" {
+		return fmt.Errorf("%s is required: access is limited to schemas %s", tool.SchemaArgument, strings.Join(principal.Schemas, ", "))
+	}
+	if !principal.AllowSchema(schema) {
+		return fmt.Errorf("not allowed to use schema %s: access is limited to schemas %s", schema, strings.Join(principal.Schemas, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/mcp"
+	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHashAPIKey(t *testing.T) {
+	// printf %s test | sha256sum
+	want := "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if got := HashAPIKey("test"); got != want {
+		t.Errorf("HashAPIKey(test) = %s, want %s", got, want)
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(file, []byte(`[{"name": "from-file", "hash": "`+HashAPIKey("file-key")+`"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadAPIKeys(`[{"name": "inline", "hash": "`+strings.ToUpper(strings.TrimPrefix(HashAPIKey("inline-key"), "sha256:"))+`", "tools": ["list_tables"]}]`, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("LoadAPIKeys() returned %d keys, want 2", len(keys))
+	}
+	if key := matchAPIKey(keys, "inline-key"); key == nil || key.Name != "inline" {
+		t.Errorf("matchAPIKey(inline-key) = %v", key)
+	}
+	if key := matchAPIKey(keys, "file-key"); key == nil || key.Name != "from-file" {
+		t.Errorf("matchAPIKey(file-key) = %v", key)
+	}
+	if key := matchAPIKey(keys, HashAPIKey("inline-key")); key != nil {
+		t.Errorf("the hash itself matched key %s", key.Name)
+	}
+
+	for _, inline := range []string{
+		`[{"hash": "` + HashAPIKey("a") + `"}]`,
+		`[{"name": "a", "hash": "` + HashAPIKey("a") + `"}, {"name": "a", "hash": "` + HashAPIKey("b") + `"}]`,
+		`[{"name": "a", "hash": "plain-text-key"}]`,
+		`[{"name": "a", "hash": "sha256:abcd"}]`,
+		`{"name": "a"}`,
+	} {
+		if _, err := LoadAPIKeys(inline, ""); err == nil {
+			t.Errorf("LoadAPIKeys(%s) succeeded, want an error", inline)
+		}
+	}
+}
+
+// sign returns a token signed with secret that expires in an hour unless
+// claims set exp
+func sign(secret string, claims utils.Claims) string {
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+	token, _ := utils.SignJWT([]byte(secret), claims)
+	return token
+}
+
+func TestAuthenticate(t *testing.T) {
+	cfg := testConfig("http://mcp.test")
+	cfg.Auth.APIKeys = `[{"name": "reporting", "hash": "` + HashAPIKey("reporting-key") + `", "tools": ["query_table"], "schemas": ["public"]}]`
+	authenticator, err := NewAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := func(claims utils.Claims) utils.Claims {
+		base := utils.Claims{"iss": testGoTrueURL, "aud": "authenticated", "sub": "user-1", "email": "user@example.com", "role": "authenticated"}
+		for name, value := range claims {
+			base[name] = value
+		}
+		return base
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		err     error
+		tools   []string
+		schemas []string
+	}{
+		{"api key", "reporting-key", nil, []string{"query_table"}, []string{"public"}},
+		{"unknown api key", "other-key", ErrInvalidCredentials, nil, nil},
+		{"service role key", sign(testJWTSecret, utils.Claims{"iss": "supabase", "role": "service_role"}), nil, nil, nil},
+		{"anon key", sign(testJWTSecret, utils.Claims{"iss": "supabase", "role": "anon"}), ErrNoAccess, nil, nil},
+		{"mcp grant", sign(testJWTSecret, utils.Claims{"iss": "supabase", "role": "anon", "mcp": map[string]interface{}{"tools": []string{"list_tables"}, "schemas": []string{"app"}}}),
+			nil, []string{"list_tables"}, []string{"app"}},
+		{"malformed mcp grant", sign(testJWTSecret, utils.Claims{"iss": "supabase", "mcp": map[string]interface{}{"tools": "list_tables"}}), ErrInvalidCredentials, nil, nil},
+		{"service role from an unknown issuer", sign(testJWTSecret, utils.Claims{"iss": "https://evil.test", "role": "service_role"}), ErrInvalidCredentials, nil, nil},
+		{"service role without issuer", sign(testJWTSecret, utils.Claims{"role": "service_role"}), ErrInvalidCredentials, nil, nil},
+		{"mcp grant from an unknown issuer", sign(testJWTSecret, utils.Claims{"iss": "https://evil.test", "mcp": map[string]interface{}{}}), ErrInvalidCredentials, nil, nil},
+		{"user token", sign(testJWTSecret, user(nil)), nil, []string{"list_tables"}, []string{"public"}},
+		{"user token with mcp_role", sign(testJWTSecret, user(utils.Claims{"app_metadata": map[string]interface{}{"mcp_role": "admin"}})), nil, nil, nil},
+		{"user token claiming the service role", sign(testJWTSecret, user(utils.Claims{"role": "service_role"})), ErrNoAccess, nil, nil},
+		{"user token with an mcp grant", sign(testJWTSecret, user(utils.Claims{"mcp": map[string]interface{}{}})), nil, []string{"list_tables"}, []string{"public"}},
+		{"user token for another audience", sign(testJWTSecret, user(utils.Claims{"aud": "other"})), ErrInvalidCredentials, nil, nil},
+		{"user token without subject", sign(testJWTSecret, user(utils.Claims{"sub": ""})), ErrNoAccess, nil, nil},
+		{"expired token", sign(testJWTSecret, utils.Claims{"iss": "supabase", "role": "service_role", "exp": time.Now().Add(-time.Hour).Unix()}), ErrInvalidCredentials, nil, nil},
+		{"wrong secret", sign("another-secret", utils.Claims{"iss": "supabase", "role": "service_role"}), ErrInvalidCredentials, nil, nil},
+	}
+
+	for _, tt := range tests {
+		principal, err := authenticator.Authenticate(tt.token)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: Authenticate() = %v, %v, want %v", tt.name, principal, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Authenticate() = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(principal.Tools, tt.tools) || !reflect.DeepEqual(principal.Schemas, tt.schemas) {
+			t.Errorf("%s: tools %v, schemas %v, want %v, %v", tt.name, principal.Tools, principal.Schemas, tt.tools, tt.schemas)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testConfig("http://mcp.test")
+	authenticator, err := NewAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.GET("/whoami", authenticator.Middleware(), whoami)
+
+	tests := []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"Bearer not-a-key", http.StatusUnauthorized},
+		{"Bearer " + sign(testJWTSecret, utils.Claims{"iss": "supabase", "role": "anon"}), http.StatusForbidden},
+		{"bearer " + sign(testJWTSecret, utils.Claims{"iss": "supabase", "role": "service_role"}), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != tt.status {
+			t.Errorf("Authorization %q: status %d, want %d", tt.header, recorder.Code, tt.status)
+		}
+		if tt.status == http.StatusUnauthorized && !strings.Contains(recorder.Header().Get("WWW-Authenticate"), "resource_metadata=") {
+			t.Errorf("Authorization %q: WWW-Authenticate %q lacks resource_metadata", tt.header, recorder.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// Without MCP_AUTH_REQUIRED, requests without credentials pass with no
+	// principal, but bad credentials are still rejected
+	cfg.Auth.Required = false
+	optional, _ := NewAuthenticator(cfg)
+	router = gin.New()
+	router.GET("/whoami", optional.Middleware(), whoami)
+	for header, status := range map[string]int{"": http.StatusOK, "Bearer not-a-key": http.StatusUnauthorized} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		router.ServeHTTP(recorder, req)
+		if recorder.Code != status {
+			t.Errorf("optional auth, Authorization %q: status %d, want %d", header, recorder.Code, status)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	authenticator := &Authenticator{}
+	listTables := mcp.Tool{Name: "list_tables", SchemaArgument: "schema"}
+	executeQuery := mcp.Tool{Name: "execute_query", AnySchema: true}
+	listBuckets := mcp.Tool{Name: "list_buckets"}
+
+	restricted := WithPrincipal(context.Background(), &Principal{
+		Name:    "reporting",
+		Tools:   []string{"list_tables", "execute_query", "list_buckets"},
+		Schemas: []string{"public", "app"},
+	})
+	toolsOnly := WithPrincipal(context.Background(), &Principal{Name: "tools-only", Tools: []string{"list_tables"}})
+	full := WithPrincipal(context.Background(), &Principal{Name: "admin"})
+
+	allowTests := []struct {
+		ctx   context.Context
+		tool  mcp.Tool
+		allow bool
+	}{
+		{context.Background(), executeQuery, true},
+		{full, executeQuery, true},
+		{restricted, listTables, true},
+		{restricted, executeQuery, false},
+		{toolsOnly, listTables, true},
+		{toolsOnly, listBuckets, false},
+	}
+	for _, tt := range allowTests {
+		if got := authenticator.AllowTool(tt.ctx, tt.tool); got != tt.allow {
+			t.Errorf("AllowTool(%s, %s) = %v, want %v", FromContext(tt.ctx), tt.tool.Name, got, tt.allow)
+		}
+	}
+
+	callTests := []struct {
+		ctx       context.Context
+		tool      mcp.Tool
+		arguments map[string]interface{}
+		allow     bool
+	}{
+		{restricted, listTables, map[string]interface{}{"schema": "app"}, true},
+		{restricted, listTables, map[string]interface{}{"schema": "private"}, false},
+		{restricted, listTables, map[string]interface{}{}, false},
+		{restricted, listTables, map[string]interface{}{"schema": 42}, false},
+		{restricted, listBuckets, map[string]interface{}{}, true},
+		{toolsOnly, listTables, map[string]interface{}{"schema": "private"}, true},
+		{context.Background(), listTables, map[string]interface{}{"schema": "private"}, true},
+	}
+	for _, tt := range callTests {
+		err := authenticator.CheckCall(tt.ctx, tt.tool, tt.arguments)
+		if (err == nil) != tt.allow {
+			t.Errorf("CheckCall(%s, %s, %v) = %v, want allowed=%v", FromContext(tt.ctx), tt.tool.Name, tt.arguments, err, tt.allow)
+		}
+	}
+
+	wildcard := &Principal{Tools: []string{"*"}, Schemas: []string{"*"}}
+	if !wildcard.AllowTool("anything") || !wildcard.AllowSchema("anything") {
+		t.Error("wildcard principal is restricted")
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ProtectedResourcePath is where the OAuth protected resource metadata
+// (RFC 9728) is served
+const ProtectedResourcePath = "/.well-known/oauth-protected-resource"
+
+// Permissions lists the tools and schemas granted to a role; nil allows
+// everything
+type Permissions struct {
+	Tools   []string `json:"tools"`
+	Schemas []string `json:"schemas"`
+}
+
+// parseRolePermissions parses MCP_ROLE_PERMISSIONS
+func parseRolePermissions(data string) (map[string]Permissions, error) {
+	roles := map[string]Permissions{}
+	if data == "" {
+		return roles, nil
+	}
+	if err := json.Unmarshal([]byte(data), &roles); err != nil {
+		return nil, fmt.Errorf("invalid MCP_ROLE_PERMISSIONS: %w", err)
+	}
+	return roles, nil
+}
+
+// userRoles lists the roles of a GoTrue access token that can grant
+// permissions: app_metadata.mcp_role, app_metadata.roles and the database
+// role. user_metadata is ignored because users can edit it themselves.
+func userRoles(claims utils.Claims) []string {
+	var roles []string
+	if appMetadata, ok := claims["app_metadata"].(map[string]interface{}); ok {
+		if role, ok := appMetadata["mcp_role"].(string); ok && role != "" {
+			roles = append(roles, role)
+		}
+		if list, err := stringList(appMetadata["roles"]); err == nil {
+			roles = append(roles, list...)
+		}
+	}
+	if role := claims.String("role"); role != "" {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+// mergePermissions combines the permissions of several roles
+func mergePermissions(grants []Permissions) Permissions {
+	var merged Permissions
+	allTools, allSchemas := false, false
+	tools, schemas := map[string]bool{}, map[string]bool{}
+
+	for _, grant := range grants {
+		if grant.Tools == nil {
+			allTools = true
+		}
+		for _, tool := range grant.Tools {
+			tools[tool] = true
+		}
+		if grant.Schemas == nil {
+			allSchemas = true
+		}
+		for _, schema := range grant.Schemas {
+			schemas[schema] = true
+		}
+	}
+
+	if !allTools {
+		merged.Tools = sortedKeys(tools)
+	}
+	if !allSchemas {
+		merged.Schemas = sortedKeys(schemas)
+	}
+	return merged
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasAudience reports whether the aud claim, a string or a list, contains
+// audience
+func hasAudience(claims utils.Claims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, item := range aud {
+			if item == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResourceMetadataURL is the address of the protected resource metadata,
+// sent to clients in WWW-Authenticate
+func (a *Authenticator) ResourceMetadataURL() string {
+	return a.publicURL + ProtectedResourcePath
+}
+
+// HandleProtectedResource serves the OAuth protected resource metadata,
+// pointing MCP clients to the authorization server that issues tokens for
+// this server
+func (a *Authenticator) HandleProtectedResource(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"resource":                 a.publicURL + "/mcp",
+		"authorization_servers":    []string{a.authorizationServer},
+		"bearer_methods_supported": []string{"header"},
+		"resource_name":            "Supabase Self-Hosted MCP Server",
+	})
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+)
+
+// Methods a principal can authenticate with
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal represents an authenticated MCP client and what it may use
+type Principal struct {
+	// Name identifies the client in logs: the API key name or the subject
+	// of the token
+	Name   string
+	Method string
+	// ID tells principals apart for state bound to one of them, such as
+	// confirmation tokens: the API key, or the issuer and subject of the
+	// token
+	ID string
+
+	// Tools and Schemas restrict the principal; nil allows everything
+	Tools   []string
+	Schemas []string
+}
+
+// AllowTool reports whether the principal may use the tool
+func (p *Principal) AllowTool(name string) bool {
+	return p.Tools == nil || contains(p.Tools, name)
+}
+
+// AllowSchema reports whether the principal may work on the schema
+func (p *Principal) AllowSchema(schema string) bool {
+	return p.Schemas == nil || contains(p.Schemas, schema)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value || item == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of the request, or nil when the
+// request was not authenticated (authentication disabled, or stdio)
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// IDFromContext returns the ID of the principal of the request, or an
+// empty string when the request was not authenticated
+func IDFromContext(ctx context.Context) string {
+	if principal := FromContext(ctx); principal != nil {
+		return principal.ID
+	}
+	return ""
+}
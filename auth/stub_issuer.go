@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// StubIssuerPath is where the stub authorization server is mounted
+const StubIssuerPath = "/stub-issuer"
+
+const (
+	stubCodeTTL  = time.Minute
+	stubTokenTTL = time.Hour
+)
+
+// StubIssuerURL returns the issuer identifier of the stub served by the
+// server reachable at publicURL
+func StubIssuerURL(publicURL string) string {
+	return publicURL + StubIssuerPath
+}
+
+// StubIssuer is a minimal OAuth 2.1 authorization server for testing MCP
+// clients without GoTrue. It approves every authorization request for a
+// fixed test user and issues access tokens shaped like GoTrue's, which go
+// through the same role mapping as real ones. They are signed with a key
+// generated at startup rather than the Supabase JWT secret, so PostgREST
+// and GoTrue reject them, and only an Authenticator told to trust the stub
+// accepts them. It must never be enabled in production.
+type StubIssuer struct {
+	issuer   string
+	audience string
+	key      []byte
+	claims   utils.Claims
+
+	mu      sync.Mutex
+	clients map[string][]string
+	codes   map[string]stubCode
+}
+
+// stubCode is an issued authorization code awaiting exchange
+type stubCode struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	expiresAt   time.Time
+}
+
+// NewStubIssuer creates the stub authorization server. OAUTH_STUB_CLAIMS
+// overrides the claims of the test user, e.g. to set app_metadata; it
+// cannot set role or mcp, which would lift the permission checks.
+func NewStubIssuer(cfg *config.Config) (*StubIssuer, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("unable to generate the stub signing key: %w", err)
+	}
+
+	claims := utils.Claims{
+		"sub":          "00000000-0000-4000-8000-000000000001",
+		"email":        "stub@localhost",
+		"role":         "authenticated",
+		"app_metadata": map[string]interface{}{"provider": "stub"},
+	}
+	if cfg.Auth.StubIssuerClaims != "" {
+		var overrides utils.Claims
+		if err := json.Unmarshal([]byte(cfg.Auth.StubIssuerClaims), &overrides); err != nil {
+			return nil, fmt.Errorf("invalid OAUTH_STUB_CLAIMS: %w", err)
+		}
+		for name, value := range overrides {
+			if name == "role" || name == "mcp" {
+				return nil, fmt.Errorf("invalid OAUTH_STUB_CLAIMS: the %s claim cannot be overridden", name)
+			}
+			claims[name] = value
+		}
+	}
+
+	logrus.Warnf("OAUTH_STUB_ISSUER is enabled: %s grants tokens to anyone without a login", StubIssuerURL(cfg.Auth.PublicURL))
+
+	return &StubIssuer{
+		issuer:   StubIssuerURL(cfg.Auth.PublicURL),
+		audience: cfg.Auth.OAuthAudience,
+		key:      key,
+		claims:   claims,
+		clients:  make(map[string][]string),
+		codes:    make(map[string]stubCode),
+	}, nil
+}
+
+// Register mounts the stub's endpoints. The metadata is served both at the
+// RFC 8414 location and under the issuer path, where older clients look.
+func (s *StubIssuer) Register(router gin.IRoutes) {
+	router.GET("/.well-known/oauth-authorization-server"+StubIssuerPath, s.HandleMetadata)
+	router.GET(StubIssuerPath+"/.well-known/oauth-authorization-server", s.HandleMetadata)
+	router.POST(StubIssuerPath+"/register", s.HandleRegister)
+	router.GET(StubIssuerPath+"/authorize", s.HandleAuthorize)
+	router.POST(StubIssuerPath+"/token", s.HandleToken)
+}
+
+// HandleMetadata serves the authorization server metadata
+func (s *StubIssuer) HandleMetadata(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"registration_endpoint":                 s.issuer + "/register",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"none"},
+	})
+}
+
+// HandleRegister implements dynamic client registration for public clients
+func (s *StubIssuer) HandleRegister(c *gin.Context) {
+	var req struct {
+		RedirectURIs []string `json:"redirect_uris"`
+		ClientName   string   `json:"client_name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		oauthError(c, "invalid_client_metadata", err.Error())
+		return
+	}
+	if len(req.RedirectURIs) == 0 {
+		oauthError(c, "invalid_redirect_uri", "at least one redirect_uri is required")
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		if parsed, err := url.Parse(uri); err != nil || !parsed.IsAbs() {
+			oauthError(c, "invalid_redirect_uri", fmt.Sprintf("%q is not an absolute URI", uri))
+			return
+		}
+	}
+
+	clientID := randomToken()
+	s.mu.Lock()
+	s.clients[clientID] = req.RedirectURIs
+	s.mu.Unlock()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"client_id":                  clientID,
+		"client_name":                req.ClientName,
+		"redirect_uris":              req.RedirectURIs,
+		"grant_types":                []string{"authorization_code"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	})
+}
+
+// HandleAuthorize approves the request at once and redirects back with an
+// authorization code. PKCE with S256 is mandatory, as in OAuth 2.1.
+func (s *StubIssuer) HandleAuthorize(c *gin.Context) {
+	clientID := c.Query("client_id")
+	redirectURI := c.Query("redirect_uri")
+
+	s.mu.Lock()
+	redirectURIs, known := s.clients[clientID]
+	s.mu.Unlock()
+	if !known {
+		oauthError(c, "invalid_client", "unknown client_id; register the client first")
+		return
+	}
+	if redirectURI == "" && len(redirectURIs) == 1 {
+		redirectURI = redirectURIs[0]
+	}
+	if !contains(redirectURIs, redirectURI) {
+		oauthError(c, "invalid_request", "redirect_uri is not registered for this client")
+		return
+	}
+
+	// From here on errors are reported to the client through the redirect
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		oauthError(c, "invalid_request", err.Error())
+		return
+	}
+	query := target.Query()
+	if state := c.Query("state"); state != "" {
+		query.Set("state", state)
+	}
+	query.Set("iss", s.issuer)
+
+	switch {
+	case c.Query("response_type") != "code":
+		query.Set("error", "unsupported_response_type")
+	case c.Query("code_challenge") == "" || c.Query("code_challenge_method") != "S256":
+		query.Set("error", "invalid_request")
+		query.Set("error_description", "code_challenge with code_challenge_method=S256 is required")
+	default:
+		code := randomToken()
+		s.mu.Lock()
+		s.sweepLocked()
+		s.codes[code] = stubCode{
+			clientID:    clientID,
+			redirectURI: redirectURI,
+			challenge:   c.Query("code_challenge"),
+			expiresAt:   time.Now().Add(stubCodeTTL),
+		}
+		s.mu.Unlock()
+		query.Set("code", code)
+	}
+
+	target.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, target.String())
+}
+
+// HandleToken exchanges an authorization code for an access token
+func (s *StubIssuer) HandleToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	if grantType := c.PostForm("grant_type"); grantType != "authorization_code" {
+		oauthError(c, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	s.mu.Lock()
+	code, ok := s.codes[c.PostForm("code")]
+	delete(s.codes, c.PostForm("code"))
+	s.mu.Unlock()
+
+	switch {
+	case !ok || time.Now().After(code.expiresAt):
+		oauthError(c, "invalid_grant", "authorization code is invalid or has expired")
+		return
+	case c.PostForm("client_id") != code.clientID:
+		oauthError(c, "invalid_grant", "authorization code was issued to another client")
+		return
+	case c.PostForm("redirect_uri") != "" && c.PostForm("redirect_uri") != code.redirectURI:
+		oauthError(c, "invalid_grant", "redirect_uri does not match the authorization request")
+		return
+	case pkceChallenge(c.PostForm("code_verifier")) != code.challenge:
+		oauthError(c, "invalid_grant", "code_verifier does not match the code_challenge")
+		return
+	}
+
+	now := time.Now()
+	claims := utils.Claims{}
+	for name, value := range s.claims {
+		claims[name] = value
+	}
+	claims["iss"] = s.issuer
+	claims["aud"] = s.audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(stubTokenTTL).Unix()
+
+	token, err := utils.SignJWT(s.key, claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(stubTokenTTL.Seconds()),
+	})
+}
+
+// sweepLocked drops expired authorization codes
+func (s *StubIssuer) sweepLocked() {
+	now := time.Now()
+	for code, issued := range s.codes {
+		if now.After(issued.expiresAt) {
+			delete(s.codes, code)
+		}
+	}
+}
+
+// pkceChallenge derives the S256 code challenge of a verifier
+func pkceChallenge(verifier string) string {
+	if verifier == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomToken() string {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// oauthError writes an OAuth error response
+func oauthError(c *gin.Context, code, description string) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": code, "error_description": description})
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testJWTSecret   = "super-secret-jwt-token-with-at-least-32-characters"
+	testGoTrueURL   = "http://supabase.test/auth/v1"
+	testRedirectURI = "http://client.test/callback"
+)
+
+// testConfig returns the configuration shared by the auth tests
+func testConfig(publicURL string) *config.Config {
+	cfg := &config.Config{}
+	cfg.Supabase.JWTSecret = testJWTSecret
+	cfg.Auth.Required = true
+	cfg.Auth.PublicURL = publicURL
+	cfg.Auth.OAuthIssuer = testGoTrueURL
+	cfg.Auth.OAuthAudience = "authenticated"
+	cfg.Auth.KeyIssuers = []string{"supabase"}
+	cfg.Auth.RolePermissions = `{"authenticated": {"tools": ["list_tables"], "schemas": ["public"]}, "admin": {"tools": null, "schemas": null}}`
+	return cfg
+}
+
+// whoami answers with the principal the middleware stored
+func whoami(c *gin.Context) {
+	c.JSON(http.StatusOK, FromContext(c.Request.Context()))
+}
+
+// stubServer serves the stub issuer and a protected route, as main does
+func stubServer(t *testing.T, claims string) (*httptest.Server, *Authenticator) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	cfg := testConfig(server.URL)
+	cfg.Auth.StubIssuer = true
+	cfg.Auth.StubIssuerClaims = claims
+
+	authenticator, err := NewAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub, err := NewStubIssuer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.Register(router)
+	authenticator.TrustStubIssuer(stub)
+	router.GET("/whoami", authenticator.Middleware(), whoami)
+	return server, authenticator
+}
+
+// noRedirects is a client that reports redirects instead of following them
+var noRedirects = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func decodeBody(t *testing.T, resp *http.Response) map[string]interface{} {
+	t.Helper()
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	return body
+}
+
+// authorize runs /authorize and returns the query of the redirect
+func authorize(t *testing.T, server *httptest.Server, params url.Values) url.Values {
+	t.Helper()
+	resp, err := noRedirects.Get(server.URL + StubIssuerPath + "/authorize?" + params.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d, want 302", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), testRedirectURI) {
+		t.Fatalf("authorize redirected to %q", resp.Header.Get("Location"))
+	}
+	return location.Query()
+}
+
+func exchange(t *testing.T, server *httptest.Server, form url.Values) (int, map[string]interface{}) {
+	t.Helper()
+	resp, err := http.PostForm(server.URL+StubIssuerPath+"/token", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, decodeBody(t, resp)
+}
+
+func callWhoami(t *testing.T, server *httptest.Server, token string) (int, map[string]interface{}) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, decodeBody(t, resp)
+}
+
+func TestStubIssuerRoundTrip(t *testing.T) {
+	server, _ := stubServer(t, `{"email": "tester@localhost"}`)
+
+	// Dynamic client registration
+	resp, err := http.Post(server.URL+StubIssuerPath+"/register", "application/json",
+		strings.NewReader(`{"client_name": "test", "redirect_uris": ["`+testRedirectURI+`"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("register: status %d, want 201", resp.StatusCode)
+	}
+	clientID, _ := decodeBody(t, resp)["client_id"].(string)
+
+	verifier := "a-sufficiently-long-code-verifier-for-the-pkce-test"
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {testRedirectURI},
+		"state":                 {"xyz"},
+		"code_challenge":        {pkceChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	// PKCE is mandatory
+	withoutPKCE := url.Values{"response_type": {"code"}, "client_id": {clientID}, "redirect_uri": {testRedirectURI}}
+	if query := authorize(t, server, withoutPKCE); query.Get("error") != "invalid_request" || query.Get("code") != "" {
+		t.Errorf("authorize without PKCE redirected with %v", query)
+	}
+
+	// A wrong verifier burns the code
+	query := authorize(t, server, params)
+	if query.Get("state") != "xyz" || query.Get("code") == "" {
+		t.Fatalf("authorize redirected with %v", query)
+	}
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {query.Get("code")}, "client_id": {clientID},
+		"redirect_uri": {testRedirectURI}, "code_verifier": {"wrong-verifier"}}
+	if status, body := exchange(t, server, form); status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("exchange with a wrong verifier: status %d, %v", status, body)
+	}
+	form.Set("code_verifier", verifier)
+	if status, _ := exchange(t, server, form); status != http.StatusBadRequest {
+		t.Errorf("exchange of a used code: status %d, want 400", status)
+	}
+
+	// A fresh code with the right verifier gives a token
+	form.Set("code", authorize(t, server, params).Get("code"))
+	status, body := exchange(t, server, form)
+	token, _ := body["access_token"].(string)
+	if status != http.StatusOK || token == "" {
+		t.Fatalf("exchange: status %d, %v", status, body)
+	}
+
+	// The middleware maps the token like a GoTrue one
+	status, principal := callWhoami(t, server, token)
+	if status != http.StatusOK || principal["Name"] != "tester@localhost" || principal["Method"] != MethodJWT {
+		t.Fatalf("whoami with the stub token: status %d, %v", status, principal)
+	}
+	if tools, _ := principal["Tools"].([]interface{}); len(tools) != 1 || tools[0] != "list_tables" {
+		t.Errorf("stub token got tools %v, want [list_tables]", principal["Tools"])
+	}
+
+	// The token is not signed with the Supabase JWT secret
+	if _, err := utils.VerifyJWT([]byte(testJWTSecret), token); err == nil {
+		t.Error("stub token verifies with SUPABASE_JWT_SECRET")
+	}
+}
+
+func TestStubIssuerTokensOnlyTrustedWhenEnabled(t *testing.T) {
+	server, _ := stubServer(t, "")
+
+	// A token signed with the shared secret cannot pose as the stub
+	forged, _ := utils.SignJWT([]byte(testJWTSecret), utils.Claims{
+		"iss":  StubIssuerURL(server.URL),
+		"aud":  "authenticated",
+		"sub":  "00000000-0000-4000-8000-000000000001",
+		"role": "authenticated",
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	})
+	if status, body := callWhoami(t, server, forged); status != http.StatusUnauthorized {
+		t.Errorf("shared-secret token with the stub issuer: status %d, %v", status, body)
+	}
+
+	// Another authenticator does not trust the stub's key
+	cfg := testConfig(server.URL)
+	stub, err := NewStubIssuer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := utils.SignJWT(stub.key, utils.Claims{
+		"iss": stub.issuer, "aud": "authenticated", "sub": "x", "role": "authenticated",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	authenticator, _ := NewAuthenticator(cfg)
+	if _, err := authenticator.Authenticate(token); err == nil {
+		t.Error("stub token accepted without TrustStubIssuer")
+	}
+}
+
+func TestStubIssuerClaimOverrides(t *testing.T) {
+	for _, claims := range []string{
+		`{"role": "service_role"}`,
+		`{"mcp": {"tools": ["execute_sql_write"]}}`,
+		`not json`,
+	} {
+		cfg := testConfig("http://mcp.test")
+		cfg.Auth.StubIssuerClaims = claims
+		if _, err := NewStubIssuer(cfg); err == nil {
+			t.Errorf("NewStubIssuer with OAUTH_STUB_CLAIMS=%s succeeded, want an error", claims)
+		}
+	}
+
+	cfg := testConfig("http://mcp.test")
+	cfg.Auth.StubIssuerClaims = `{"app_metadata": {"mcp_role": "admin"}}`
+	if _, err := NewStubIssuer(cfg); err != nil {
+		t.Errorf("NewStubIssuer with app_metadata = %v", err)
+	}
+}
@@ -1,29 +1,126 @@
 package config
 
 import (
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config represents the application configuration
 type Config struct {
 	Supabase SupabaseConfig
 	Server   ServerConfig
+	SQL      SQLConfig
+	Auth     AuthConfig
+	Storage  StorageConfig
 }
 
 // SupabaseConfig contains Supabase connection details
 type SupabaseConfig struct {
-	URL        string
-	Key        string
-	AnonKey    string
-	JWTSecret  string
-	PGConnStr  string
+	URL       string
+	Key       string
+	AnonKey   string
+	JWTSecret string
+	PGConnStr string
+
+	// HTTP client used for the Supabase APIs
+	HTTPTimeout  time.Duration
+	MaxIdleConns int
+	// MaxRetries is how often idempotent requests are retried after a
+	// 502, 503 or 504 response
+	MaxRetries int
 }
 
+// Supported MCP transports
+const (
+	TransportHTTP  = "http"
+	TransportStdio = "stdio"
+)
+
 // ServerConfig contains server configuration
 type ServerConfig struct {
-	Port int
-	Env  string
+	Port      int
+	Env       string
+	Transport string
+	// MaxRequestSize bounds the body of the MCP and REST requests in bytes;
+	// it defaults to room for an upload of STORAGE_MAX_UPLOAD_SIZE encoded
+	// as base64
+	MaxRequestSize int64
+}
+
+// SQLConfig contains settings for the SQL tools
+type SQLConfig struct {
+	// WriteEnabled exposes the execute_sql_write tool
+	WriteEnabled bool
+	// ConfirmSecret signs the confirmation tokens issued by dry runs; a
+	// random secret is generated at startup when empty
+	ConfirmSecret string
+	// ConfirmTTL is how long a confirmation token stays valid
+	ConfirmTTL time.Duration
+	// StatementTimeout bounds each SQL request on the direct connection;
+	// zero disables it
+	StatementTimeout time.Duration
+}
+
+// StorageConfig contains limits for the storage object tools, which pass
+// file contents through the MCP channel
+type StorageConfig struct {
+	// MaxUploadSize and MaxDownloadSize bound the size in bytes of an
+	// object uploaded or downloaded in a single tool call
+	MaxUploadSize   int64
+	MaxDownloadSize int64
+	// PublicURL is the Supabase address used in the links handed out by the
+	// storage URL tools, SUPABASE_PUBLIC_URL or else SUPABASE_URL
+	PublicURL string
+
+	// UploadSessionTTL is how long a resumable upload session is kept
+	// without activity
+	UploadSessionTTL time.Duration
+	// UploadDir is the directory resumable uploads may read local files
+	// from; empty disables uploads from local files
+	UploadDir string
+	// TUSStub sends resumable uploads to a local TUS server that writes
+	// them under TUSStubDir, for testing without Supabase
+	TUSStub    bool
+	TUSStubDir string
+}
+
+// AuthConfig contains the credentials accepted from MCP clients
+type AuthConfig struct {
+	// Required rejects unauthenticated requests; disabling it leaves the
+	// server open to anyone who can reach it
+	Required bool
+	// APIKeys is a JSON array of hashed API keys with the tools and schemas
+	// each one may use; APIKeysFile names a file with the same content
+	APIKeys     string
+	APIKeysFile string
+	// AllowedOrigins lists the browser origins allowed by CORS; "*" allows
+	// any origin
+	AllowedOrigins []string
+
+	// PublicURL is the address clients use to reach this server; it
+	// identifies the protected resource in the OAuth metadata
+	PublicURL string
+	// OAuthIssuer is the authorization server advertised to MCP clients,
+	// GoTrue by default, and OAuthAudience the aud its tokens carry
+	OAuthIssuer   string
+	OAuthAudience string
+	// RolePermissions is a JSON object mapping Supabase roles, or the roles
+	// listed in app_metadata, to the tools and schemas they may use
+	RolePermissions string
+	// KeyIssuers are the iss claims of the tokens signed with the JWT
+	// secret that may use the service role or carry an mcp grant, such as
+	// the Supabase API keys
+	KeyIssuers []string
+	// StubIssuer serves a local authorization server that approves every
+	// request, for testing OAuth clients without GoTrue
+	StubIssuer       bool
+	StubIssuerClaims string
 }
 
 // LoadConfig loads configuration from environment variables
@@ -37,19 +134,74 @@ func LoadConfig() *Config {
 		}
 	}
 
+	supabaseURL := getEnv("SUPABASE_URL", "http://localhost:8000")
+	maxUploadSize := int64(getEnvInt("STORAGE_MAX_UPLOAD_SIZE", 10<<20))
+
 	return &Config{
 		Supabase: SupabaseConfig{
-			URL:        getEnv("SUPABASE_URL", "http://localhost:8000"),
-			Key:        getEnv("SUPABASE_KEY", ""),
-			AnonKey:    getEnv("SUPABASE_ANON_KEY", ""),
-			JWTSecret:  getEnv("SUPABASE_JWT_SECRET", ""),
-			PGConnStr:  getEnv("PG_CONNECTION_STRING", ""),
+			URL:          supabaseURL,
+			Key:          getEnv("SUPABASE_KEY", ""),
+			AnonKey:      getEnv("SUPABASE_ANON_KEY", ""),
+			JWTSecret:    getEnv("SUPABASE_JWT_SECRET", ""),
+			PGConnStr:    getEnv("PG_CONNECTION_STRING", ""),
+			HTTPTimeout:  time.Duration(getEnvInt("SUPABASE_HTTP_TIMEOUT", 30)) * time.Second,
+			MaxIdleConns: getEnvInt("SUPABASE_MAX_IDLE_CONNS", 100),
+			MaxRetries:   getEnvInt("SUPABASE_MAX_RETRIES", 2),
 		},
 		Server: ServerConfig{
-			Port: port,
-			Env:  getEnv("GO_ENV", "development"),
+			Port:           port,
+			Env:            getEnv("GO_ENV", "development"),
+			Transport:      getEnv("MCP_TRANSPORT", TransportHTTP),
+			MaxRequestSize: int64(getEnvInt("MCP_MAX_REQUEST_SIZE", int((maxUploadSize+2)/3*4+1<<20))),
 		},
+		SQL: SQLConfig{
+			WriteEnabled:     getEnvBool("SQL_WRITE_ENABLED", false),
+			ConfirmSecret:    getEnv("SQL_CONFIRM_SECRET", ""),
+			ConfirmTTL:       time.Duration(getEnvInt("SQL_CONFIRM_TTL", 600)) * time.Second,
+			StatementTimeout: time.Duration(getEnvInt("SQL_STATEMENT_TIMEOUT", 30)) * time.Second,
+		},
+		Auth: AuthConfig{
+			Required:       getEnvBool("MCP_AUTH_REQUIRED", true),
+			APIKeys:        getEnv("MCP_API_KEYS", ""),
+			APIKeysFile:    getEnv("MCP_API_KEYS_FILE", ""),
+			AllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS", ""),
+
+			PublicURL:        strings.TrimRight(getEnv("MCP_PUBLIC_URL", fmt.Sprintf("http://localhost:%d", port)), "/"),
+			OAuthIssuer:      strings.TrimRight(getEnv("OAUTH_ISSUER", strings.TrimRight(supabaseURL, "/")+"/auth/v1"), "/"),
+			OAuthAudience:    getEnv("OAUTH_AUDIENCE", "authenticated"),
+			RolePermissions:  getEnv("MCP_ROLE_PERMISSIONS", ""),
+			KeyIssuers:       getEnvList("MCP_JWT_KEY_ISSUERS", "supabase,supabase-demo"),
+			StubIssuer:       getEnvBool("OAUTH_STUB_ISSUER", false),
+			StubIssuerClaims: getEnv("OAUTH_STUB_CLAIMS", ""),
+		},
+		Storage: StorageConfig{
+			MaxUploadSize:   maxUploadSize,
+			MaxDownloadSize: int64(getEnvInt("STORAGE_MAX_DOWNLOAD_SIZE", 10<<20)),
+			PublicURL:       publicURL(supabaseURL),
+
+			UploadSessionTTL: time.Duration(getEnvInt("STORAGE_UPLOAD_SESSION_TTL", 3600)) * time.Second,
+			UploadDir:        getEnv("STORAGE_UPLOAD_DIR", ""),
+			TUSStub:          getEnvBool("STORAGE_TUS_STUB", false),
+			TUSStubDir:       getEnv("STORAGE_TUS_STUB_DIR", filepath.Join(os.TempDir(), "supabase-mcp-tus")),
+		},
+	}
+}
+
+// publicURL returns SUPABASE_PUBLIC_URL, or else the Supabase URL as is
+func publicURL(supabaseURL string) string {
+	return strings.TrimRight(getEnv("SUPABASE_PUBLIC_URL", supabaseURL), "/")
+}
+
+// InternalHost reports whether the host of rawURL is a single-label name
+// other than localhost, such as kong in http://kong:8000, which usually
+// only resolves inside a Docker network
+func InternalHost(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
 	}
+	host := u.Hostname()
+	return host != "" && host != "localhost" && !strings.Contains(host, ".") && net.ParseIP(host) == nil
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -60,3 +212,33 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvBool gets a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList gets a comma-separated environment variable as a list or
+// returns the default list
+func getEnvList(key, defaultValue string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvInt gets an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestPublicURL(t *testing.T) {
+	t.Setenv("SUPABASE_PUBLIC_URL", "")
+	if got := publicURL("http://kong:8000/"); got != "http://kong:8000" {
+		t.Errorf("publicURL(http://kong:8000/) = %s, want the host unchanged", got)
+	}
+
+	t.Setenv("SUPABASE_PUBLIC_URL", "https://supabase.example.com/")
+	if got := publicURL("http://kong:8000"); got != "https://supabase.example.com" {
+		t.Errorf("publicURL with SUPABASE_PUBLIC_URL = %s", got)
+	}
+}
+
+func TestInternalHost(t *testing.T) {
+	tests := map[string]bool{
+		"http://kong:8000":            true,
+		"http://supabase-kong":        true,
+		"http://localhost:8000":       false,
+		"http://127.0.0.1:8000":       false,
+		"http://[::1]:8000":           false,
+		"https://supabase.example.io": false,
+		"":                            false,
+	}
+	for rawURL, want := range tests {
+		if got := InternalHost(rawURL); got != want {
+			t.Errorf("InternalHost(%q) = %v, want %v", rawURL, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// exactJSON binds a JSON request body like binding.JSON, but decodes
+// numbers held in interface{} fields as json.Number. Filter values, row
+// data and RPC arguments therefore reach PostgREST as sent, where float64
+// would round bigint ids above 2^53.
+type exactJSON struct{}
+
+func (exactJSON) Name() string {
+	return "json"
+}
+
+func (exactJSON) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return fmt.Errorf("invalid request")
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// bindJSON binds the request body of a tool call into obj
+func bindJSON(c *gin.Context, obj interface{}) error {
+	return c.ShouldBindWith(obj, exactJSON{})
+}
@@ -1,9 +1,18 @@
 package controllers
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/dirgocs/supabase-self-hosted-mcp/auth"
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
 	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
 	"github.com/dirgocs/supabase-self-hosted-mcp/types"
 	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
@@ -15,24 +24,49 @@ import (
 // DatabaseController handles database-related operations
 type DatabaseController struct {
 	supabase *supabase.SupabaseClientExtended
+	db       database.Executor
+	sql      config.SQLConfig
+
+	impersonator *Impersonator
+
+	// confirmSecret signs dry-run confirmation tokens; usedTokens keeps
+	// spent tokens until they expire so each one commits only once
+	confirmSecret []byte
+	tokensMu      sync.Mutex
+	usedTokens    map[string]time.Time
 }
 
 // NewDatabaseController creates a new database controller
-func NewDatabaseController(client *supabase.SupabaseClientExtended) *DatabaseController {
+func NewDatabaseController(client *supabase.SupabaseClientExtended, db database.Executor, sqlConfig config.SQLConfig, impersonator *Impersonator) *DatabaseController {
+	secret := []byte(sqlConfig.ConfirmSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+
 	return &DatabaseController{
-		supabase: client,
+		supabase:      client,
+		db:            db,
+		sql:           sqlConfig,
+		impersonator:  impersonator,
+		confirmSecret: secret,
+		usedTokens:    make(map[string]time.Time),
 	}
 }
 
 // ExecuteQueryRequest represents the request body for executing a query
 type ExecuteQueryRequest struct {
-	Query string `json:"query"`
+	Query       string         `json:"query" jsonschema:"required" description:"SQL query to execute (read-only operations only)"`
+	Timeout     int            `json:"timeout" jsonschema:"minimum=1,maximum=600" description:"Statement timeout in seconds (optional, defaults to SQL_STATEMENT_TIMEOUT)"`
+	Impersonate *Impersonation `json:"impersonate" description:"Run the query as an end user, with row level security; requires PG_CONNECTION_STRING (optional, defaults to the service role)"`
 }
 
 // ExecuteQuery executes a SQL query (read-only for security)
 func (dc *DatabaseController) ExecuteQuery(c *gin.Context) {
 	var req ExecuteQueryRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -43,34 +77,161 @@ func (dc *DatabaseController) ExecuteQuery(c *gin.Context) {
 	}
 
 	// Check if the query is read-only
-	if !utils.IsReadOnlyQuery(req.Query) {
+	if err := database.CheckReadOnly(req.Query); err != nil {
 		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Only read-only queries are allowed through this endpoint for security reasons",
+			"error":  "Only read-only queries are allowed through this endpoint for security reasons",
+			"reason": err.Error(),
 		})
 		return
 	}
 
-	// Execute query through Functions API
-	var result interface{}
-	// Use the Functions API to execute the query
-	err := dc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": req.Query,
-	}, &result)
+	ctx := statementContext(c, req.Timeout)
+	if req.Impersonate != nil {
+		_, claims, err := dc.impersonator.userToken(req.Impersonate)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		ctx = database.AsIdentity(ctx, identity(claims))
+	}
 
+	result, err := dc.db.QueryReadOnly(ctx, req.Query)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   err.Error(),
-			"message": "Unable to execute query. You may need to create a custom function 'execute_sql' in your Supabase instance.",
-		})
+		body := sqlError(err)
+		var apiErr *supabase.APIError
+		if errors.As(err, &apiErr) && apiErr.IsFunctionNotFound() {
+			body["message"] = "Unable to execute query. Set PG_CONNECTION_STRING or create a custom function 'execute_sql' in your Supabase instance."
+		}
+		c.JSON(errorStatus(err), body)
 		return
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
+// ExecuteSQLWriteRequest represents the request body for executing write statements
+type ExecuteSQLWriteRequest struct {
+	Query   string `json:"query" jsonschema:"required" description:"SQL statements to execute (DML or DDL); several statements run in one transaction"`
+	DryRun  bool   `json:"dry_run" description:"Execute, report affected rows and notices, then roll back (optional, defaults to false)"`
+	Confirm string `json:"confirm" description:"Confirmation token returned by a dry run of the same query; required to commit"`
+	Timeout int    `json:"timeout" jsonschema:"minimum=1,maximum=600" description:"Statement timeout in seconds (optional, defaults to SQL_STATEMENT_TIMEOUT)"`
+}
+
+// ExecuteSQLWrite executes DML or DDL statements inside a transaction. A dry
+// run rolls the transaction back and returns a confirmation token; the same
+// query is only committed when that token is sent back.
+func (dc *DatabaseController) ExecuteSQLWrite(c *gin.Context) {
+	var req ExecuteSQLWriteRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !dc.sql.WriteEnabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Write queries are disabled. Set SQL_WRITE_ENABLED=true to enable them."})
+		return
+	}
+
+	transactor, ok := dc.db.(database.Transactor)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Write queries require a direct database connection. Set PG_CONNECTION_STRING."})
+		return
+	}
+
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query is required"})
+		return
+	}
+	if err := database.CheckTransactional(req.Query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Confirmation tokens are only valid for the principal that ran the
+	// dry run
+	payload := auth.IDFromContext(c.Request.Context()) + "\n" + req.Query
+	if !req.DryRun {
+		if req.Confirm == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A confirmation token is required. Run the query with dry_run first and pass the returned confirm token."})
+			return
+		}
+		if err := dc.reserveConfirmToken(payload, req.Confirm); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	result, err := transactor.ExecScript(statementContext(c, req.Timeout), req.Query, !req.DryRun)
+	if err != nil {
+		if !req.DryRun {
+			// Nothing was committed, so the token can be used again
+			dc.releaseConfirmToken(req.Confirm)
+		}
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
+
+	response := gin.H{
+		"dry_run":    req.DryRun,
+		"committed":  result.Committed,
+		"statements": result.Statements,
+		"notices":    result.Notices,
+	}
+
+	if req.DryRun {
+		expiresAt := time.Now().Add(dc.sql.ConfirmTTL)
+		response["confirm"] = utils.NewConfirmToken(dc.confirmSecret, payload, expiresAt)
+		response["confirm_expires_at"] = expiresAt.UTC().Format(time.RFC3339)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// reserveConfirmToken verifies a confirmation token and marks it as used,
+// so that concurrent calls cannot commit the same query twice. A failed
+// commit gives the token back with releaseConfirmToken.
+func (dc *DatabaseController) reserveConfirmToken(payload, token string) error {
+	if err := utils.VerifyConfirmToken(dc.confirmSecret, payload, token); err != nil {
+		return err
+	}
+
+	dc.tokensMu.Lock()
+	defer dc.tokensMu.Unlock()
+
+	now := time.Now()
+	for used, expiresAt := range dc.usedTokens {
+		if now.After(expiresAt) {
+			delete(dc.usedTokens, used)
+		}
+	}
+
+	if _, spent := dc.usedTokens[token]; spent {
+		return fmt.Errorf("confirmation token has already been used; run a new dry run")
+	}
+	dc.usedTokens[token] = now.Add(dc.sql.ConfirmTTL)
+	return nil
+}
+
+// releaseConfirmToken makes a reserved confirmation token usable again
+func (dc *DatabaseController) releaseConfirmToken(token string) {
+	dc.tokensMu.Lock()
+	defer dc.tokensMu.Unlock()
+	delete(dc.usedTokens, token)
+}
+
+// statementContext returns the request context, carrying the statement
+// timeout given in seconds when there is one
+func statementContext(c *gin.Context, seconds int) context.Context {
+	ctx := c.Request.Context()
+	if seconds > 0 {
+		ctx = database.WithStatementTimeout(ctx, time.Duration(seconds)*time.Second)
+	}
+	return ctx
+}
+
 // GetDatabaseSchemaRequest represents the request body for getting database schema
 type GetDatabaseSchemaRequest struct {
-	Schema string `json:"schema"`
+	Schema string `json:"schema" description:"Schema name (optional, defaults to all schemas)"`
 }
 
 // SchemaData represents the processed schema data structure
@@ -122,7 +283,7 @@ type SchemaItem struct {
 // GetDatabaseSchema gets database schema information
 func (dc *DatabaseController) GetDatabaseSchema(c *gin.Context) {
 	var req GetDatabaseSchemaRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -150,11 +311,11 @@ func (dc *DatabaseController) GetDatabaseSchema(c *gin.Context) {
 			CASE 
 				WHEN co.contype = 'f' THEN ref_class.relname
 				ELSE NULL
-			END AS reference_table,
+			END AS referenced_table,
 			CASE 
 				WHEN co.contype = 'f' THEN ref_attr.attname
 				ELSE NULL
-			END AS reference_column
+			END AS referenced_column
 		FROM pg_attribute a
 		JOIN pg_class c ON a.attrelid = c.oid
 		JOIN pg_namespace n ON c.relnamespace = n.oid
@@ -173,22 +334,25 @@ func (dc *DatabaseController) GetDatabaseSchema(c *gin.Context) {
 		WHERE a.attnum > 0 AND NOT a.attisdropped AND c.relkind = 'r'
 	`
 
+	var params []interface{}
 	if req.Schema != "" {
-		query += fmt.Sprintf(" AND n.nspname = '%s'", req.Schema)
+		query += " AND n.nspname = $1"
+		params = append(params, req.Schema)
 	} else {
 		query += " AND n.nspname NOT IN ('pg_catalog', 'information_schema')"
 	}
 
 	query += " ORDER BY n.nspname, c.relname, a.attnum"
 
-	// Execute query through RPC
-	var rawData []types.SchemaItem
-	err := dc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": query,
-	}, &rawData)
-
+	result, err := dc.db.Query(c.Request.Context(), query, params...)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
+
+	var rawData []types.SchemaItem
+	if err := result.Decode(&rawData); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -246,13 +410,13 @@ func (dc *DatabaseController) GetDatabaseSchema(c *gin.Context) {
 
 // CreateSchemaRequest represents the request body for creating a schema
 type CreateSchemaRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" jsonschema:"required" description:"Schema name"`
 }
 
 // CreateSchema creates a new schema
 func (dc *DatabaseController) CreateSchema(c *gin.Context) {
 	var req CreateSchemaRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -262,15 +426,12 @@ func (dc *DatabaseController) CreateSchema(c *gin.Context) {
 		return
 	}
 
-	sql := fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS "%s"`, req.Name)
+	sql := "CREATE SCHEMA IF NOT EXISTS " + database.QuoteIdent(req.Name)
 
-	var result interface{}
-	err := dc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	_, err := dc.db.Query(c.Request.Context(), sql)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
@@ -282,14 +443,14 @@ func (dc *DatabaseController) CreateSchema(c *gin.Context) {
 
 // DeleteSchemaRequest represents the request body for deleting a schema
 type DeleteSchemaRequest struct {
-	Name    string `json:"name"`
-	Cascade bool   `json:"cascade"`
+	Name    string `json:"name" jsonschema:"required" description:"Schema name"`
+	Cascade bool   `json:"cascade" description:"Whether to cascade the deletion (optional, defaults to false)"`
 }
 
 // DeleteSchema deletes a schema
 func (dc *DatabaseController) DeleteSchema(c *gin.Context) {
 	var req DeleteSchemaRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -299,19 +460,16 @@ func (dc *DatabaseController) DeleteSchema(c *gin.Context) {
 		return
 	}
 
-	sql := fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s"`, req.Name)
+	sql := "DROP SCHEMA IF EXISTS " + database.QuoteIdent(req.Name)
 
 	if req.Cascade {
 		sql += " CASCADE"
 	}
 
-	var result interface{}
-	err := dc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	_, err := dc.db.Query(c.Request.Context(), sql)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
@@ -323,8 +481,8 @@ func (dc *DatabaseController) DeleteSchema(c *gin.Context) {
 
 // GetRLSPoliciesRequest represents the request body for getting RLS policies
 type GetRLSPoliciesRequest struct {
-	Schema string `json:"schema"`
-	Table  string `json:"table"`
+	Schema string `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table  string `json:"table" description:"Table name (optional, if not provided returns policies for all tables)"`
 }
 
 // RLSPolicy represents an RLS policy
@@ -339,10 +497,115 @@ type RLSPolicy struct {
 	Roles            []string `json:"roles"`
 }
 
+// validatePolicyExpressions checks the USING and WITH CHECK expressions of
+// a policy before they are placed in a CREATE POLICY statement
+func validatePolicyExpressions(definition, check string) error {
+	if err := database.ValidateExpression(definition); err != nil {
+		return fmt.Errorf("definition: %v", err)
+	}
+	if check != "" {
+		if err := database.ValidateExpression(check); err != nil {
+			return fmt.Errorf("check: %v", err)
+		}
+	}
+	return nil
+}
+
+// policySpec describes an RLS policy to create
+type policySpec struct {
+	Name       string
+	Command    string
+	Type       string
+	Roles      []string
+	Definition string
+	Check      string
+}
+
+// policyRoles combines the role and roles arguments of a policy request;
+// without either the policy applies to public
+func policyRoles(role string, roles []string) ([]string, error) {
+	if role != "" {
+		roles = append([]string{role}, roles...)
+	}
+	for _, name := range roles {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("role names cannot be empty")
+		}
+	}
+	if len(roles) == 0 {
+		return []string{"public"}, nil
+	}
+	return roles, nil
+}
+
+// newPolicySpec validates the arguments of a policy to create
+func newPolicySpec(name, operation, kind, role string, roles []string, definition, check string) (policySpec, error) {
+	policy := policySpec{Name: name, Definition: definition, Check: check}
+
+	var err error
+	if policy.Command, err = database.PolicyCommand(operation); err != nil {
+		return policy, err
+	}
+	if kind == "" {
+		kind = "PERMISSIVE"
+	}
+	if policy.Type, err = database.PolicyType(kind); err != nil {
+		return policy, err
+	}
+	if policy.Roles, err = policyRoles(role, roles); err != nil {
+		return policy, err
+	}
+	if err := validatePolicyExpressions(definition, check); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// quoteRoles quotes role names for a TO clause. PostgreSQL still reads a
+// quoted "public" as the PUBLIC keyword.
+func quoteRoles(roles []string) string {
+	quoted := make([]string, len(roles))
+	for i, role := range roles {
+		quoted[i] = database.QuoteIdent(role)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// createPolicySQL builds the CREATE POLICY statement for a validated
+// command and expressions. INSERT policies only take a WITH CHECK
+// expression, which defaults to the definition.
+func createPolicySQL(table string, policy policySpec) string {
+	kind := policy.Type
+	if kind == "" {
+		kind = "PERMISSIVE"
+	}
+
+	sql := fmt.Sprintf(`CREATE POLICY %s ON %s 
+               AS %s 
+               FOR %s 
+               TO %s`, database.QuoteIdent(policy.Name), table, kind, policy.Command, quoteRoles(policy.Roles))
+
+	if policy.Command == "INSERT" {
+		check := policy.Check
+		if check == "" {
+			check = policy.Definition
+		}
+		return sql + fmt.Sprintf(` WITH CHECK (%s)`, check)
+	}
+	sql += fmt.Sprintf(` 
+               USING (%s)`, policy.Definition)
+
+	// Add WITH CHECK clause for operations that need it
+	if policy.Check != "" && (policy.Command == "UPDATE" || policy.Command == "ALL") {
+		sql += fmt.Sprintf(` WITH CHECK (%s)`, policy.Check)
+	}
+	return sql
+}
+
 // GetRLSPolicies gets RLS policies
 func (dc *DatabaseController) GetRLSPolicies(c *gin.Context) {
 	var req GetRLSPoliciesRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -390,18 +653,15 @@ func (dc *DatabaseController) GetRLSPolicies(c *gin.Context) {
 
 	query += " ORDER BY n.nspname, c.relname, p.polname"
 
-	var policies []types.RLSPolicy
-
-	// Execute the query to get RLS policies
-	err := dc.supabase.Functions().Invoke("get_rls_policies", map[string]interface{}{
-		"query": query,
-		"params": params,
-	}, &policies)
+	result, err := dc.db.Query(c.Request.Context(), query, params...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   err.Error(),
-			"message": "Unable to execute query. You may need to create a custom function 'execute_sql' in your Supabase instance.",
-		})
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
+
+	policies := []RLSPolicy{}
+	if err := result.Decode(&policies); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -410,19 +670,21 @@ func (dc *DatabaseController) GetRLSPolicies(c *gin.Context) {
 
 // CreateRLSPolicyRequest represents the request body for creating an RLS policy
 type CreateRLSPolicyRequest struct {
-	Schema     string `json:"schema"`
-	Table      string `json:"table"`
-	Name       string `json:"name"`
-	Operation  string `json:"operation"`
-	Definition string `json:"definition"`
-	Check      string `json:"check"`
-	Role       string `json:"role"`
+	Schema     string   `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table      string   `json:"table" jsonschema:"required" description:"Table name"`
+	Name       string   `json:"name" jsonschema:"required" description:"Policy name"`
+	Operation  string   `json:"operation" jsonschema:"required,enum=SELECT|INSERT|UPDATE|DELETE|ALL" description:"Operation type that the policy applies to"`
+	Definition string   `json:"definition" jsonschema:"required" description:"Policy definition (using expression syntax)"`
+	Check      string   `json:"check" description:"Optional check expression for INSERT/UPDATE operations"`
+	Role       string   `json:"role" description:"Optional role name (defaults to public when no roles are given)"`
+	Roles      []string `json:"roles" description:"Role names the policy applies to, in addition to role (optional)"`
+	Type       string   `json:"type" jsonschema:"enum=PERMISSIVE|RESTRICTIVE,default=PERMISSIVE" description:"Whether the policy grants access (PERMISSIVE) or further restricts it (RESTRICTIVE) (optional, defaults to PERMISSIVE)"`
 }
 
 // CreateRLSPolicy creates a new RLS policy
 func (dc *DatabaseController) CreateRLSPolicy(c *gin.Context) {
 	var req CreateRLSPolicyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -437,30 +699,19 @@ func (dc *DatabaseController) CreateRLSPolicy(c *gin.Context) {
 		schema = "public"
 	}
 
-	role := req.Role
-	if role == "" {
-		role = "public"
+	policy, err := newPolicySpec(req.Name, req.Operation, req.Type, req.Role, req.Roles, req.Definition, req.Check)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	tableIdentifier := fmt.Sprintf(`"%s"."%s"`, schema, req.Table)
-
-	sql := fmt.Sprintf(`CREATE POLICY "%s" ON %s 
-               FOR %s 
-               TO "%s" 
-               USING (%s)`, req.Name, tableIdentifier, req.Operation, role, req.Definition)
-
-	// Add WITH CHECK clause for operations that need it
-	if req.Check != "" && (req.Operation == "INSERT" || req.Operation == "UPDATE" || req.Operation == "ALL") {
-		sql += fmt.Sprintf(` WITH CHECK (%s)`, req.Check)
-	}
+	tableIdentifier := database.QualifiedName(schema, req.Table)
+	sql := createPolicySQL(tableIdentifier, policy)
 
-	var result interface{}
-	err := dc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	_, err = dc.db.Query(c.Request.Context(), sql)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
@@ -472,23 +723,29 @@ func (dc *DatabaseController) CreateRLSPolicy(c *gin.Context) {
 
 // UpdateRLSPolicyRequest represents the request body for updating an RLS policy
 type UpdateRLSPolicyRequest struct {
-	Schema     string `json:"schema"`
-	Table      string `json:"table"`
-	Name       string `json:"name"`
-	Operation  string `json:"operation"`
-	Definition string `json:"definition"`
-	Check      string `json:"check"`
+	Schema     string   `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table      string   `json:"table" jsonschema:"required" description:"Table name"`
+	Name       string   `json:"name" jsonschema:"required" description:"Policy name"`
+	NewName    string   `json:"new_name" description:"New policy name (optional)"`
+	Operation  string   `json:"operation" jsonschema:"enum=SELECT|INSERT|UPDATE|DELETE|ALL" description:"Operation type that the policy applies to (optional, unchanged by default)"`
+	Type       string   `json:"type" jsonschema:"enum=PERMISSIVE|RESTRICTIVE" description:"PERMISSIVE or RESTRICTIVE (optional, unchanged by default)"`
+	Roles      []string `json:"roles" description:"Role names the policy applies to (optional, unchanged by default)"`
+	Definition string   `json:"definition" description:"Policy definition (using expression syntax) (optional, unchanged by default)"`
+	Check      string   `json:"check" description:"Check expression for INSERT/UPDATE operations (optional, unchanged by default)"`
 }
 
-// UpdateRLSPolicy updates an existing RLS policy
+// UpdateRLSPolicy updates an existing RLS policy. Roles, expressions and
+// the name are changed with ALTER POLICY, so the policy never disappears.
+// The command and type cannot be altered; changing them drops and
+// recreates the policy in a single statement, keeping every other setting.
 func (dc *DatabaseController) UpdateRLSPolicy(c *gin.Context) {
 	var req UpdateRLSPolicyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if req.Table == "" || req.Name == "" || req.Definition == "" {
+	if req.Table == "" || req.Name == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
 		return
 	}
@@ -498,62 +755,231 @@ func (dc *DatabaseController) UpdateRLSPolicy(c *gin.Context) {
 		schema = "public"
 	}
 
-	tableIdentifier := fmt.Sprintf(`"%s"."%s"`, schema, req.Table)
+	if req.Definition != "" {
+		if err := database.ValidateExpression(req.Definition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("definition: %v", err)})
+			return
+		}
+	}
+	if req.Check != "" {
+		if err := database.ValidateExpression(req.Check); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("check: %v", err)})
+			return
+		}
+	}
 
-	// First drop the existing policy
-	dropSQL := fmt.Sprintf(`DROP POLICY IF EXISTS "%s" ON %s`, req.Name, tableIdentifier)
-	var dropResult interface{}
-	err := dc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": dropSQL,
-	}, &dropResult)
+	tableIdentifier := database.QualifiedName(schema, req.Table)
 
+	current, err := loadPolicy(c.Request.Context(), dc.db, tableIdentifier, req.Name)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
-
-	// Then recreate it with new parameters
-	operation := req.Operation
-	if operation == "" {
-		operation = "ALL"
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("RLS policy '%s' not found on %s", req.Name, tableIdentifier)})
+		return
 	}
 
-	createSQL := fmt.Sprintf(`CREATE POLICY "%s" ON %s 
-                      FOR %s 
-                      USING (%s)`, req.Name, tableIdentifier, operation, req.Definition)
+	statements, recreate, err := updatePolicySQL(tableIdentifier, current, policyChanges{
+		NewName:    req.NewName,
+		Operation:  req.Operation,
+		Type:       req.Type,
+		Roles:      req.Roles,
+		Definition: req.Definition,
+		Check:      req.Check,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Add WITH CHECK clause for operations that need it
-	if req.Check != "" && (operation == "INSERT" || operation == "UPDATE" || operation == "ALL") {
-		createSQL += fmt.Sprintf(` WITH CHECK (%s)`, req.Check)
+	if len(statements) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
 	}
 
-	var createResult interface{}
-	err = dc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": createSQL,
-	}, &createResult)
+	_, err = dc.db.Query(c.Request.Context(), database.Atomic(statements...))
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("RLS policy '%s' updated on %s", req.Name, tableIdentifier),
+		"success":   true,
+		"recreated": recreate,
+		"message":   fmt.Sprintf("RLS policy '%s' updated on %s", policyName(req.Name, req.NewName), tableIdentifier),
 	})
 }
 
+// policyChanges holds the settings to change on an existing policy; empty
+// fields keep their current value
+type policyChanges struct {
+	NewName    string
+	Operation  string
+	Type       string
+	Roles      []string
+	Definition string
+	Check      string
+}
+
+// policyName returns the name a policy has after an update
+func policyName(name, newName string) string {
+	if newName != "" {
+		return newName
+	}
+	return name
+}
+
+// updatePolicySQL builds the statements that apply changes to the current
+// policy and reports whether the policy is dropped and recreated
+func updatePolicySQL(table string, current *policySpec, changes policyChanges) ([]string, bool, error) {
+	var err error
+	target := *current
+	if changes.Operation != "" {
+		if target.Command, err = database.PolicyCommand(changes.Operation); err != nil {
+			return nil, false, err
+		}
+	}
+	if changes.Type != "" {
+		if target.Type, err = database.PolicyType(changes.Type); err != nil {
+			return nil, false, err
+		}
+	}
+	if len(changes.Roles) > 0 {
+		if target.Roles, err = policyRoles("", changes.Roles); err != nil {
+			return nil, false, err
+		}
+	}
+	if changes.Definition != "" {
+		target.Definition = changes.Definition
+	}
+	if changes.Check != "" {
+		target.Check = changes.Check
+	}
+	if changes.NewName != "" {
+		target.Name = changes.NewName
+	}
+
+	// INSERT policies only have a check, which the definition stands for;
+	// SELECT and DELETE policies have no check
+	switch target.Command {
+	case "INSERT":
+		if changes.Definition != "" && changes.Check == "" {
+			target.Check = changes.Definition
+		}
+		if target.Check == "" {
+			target.Check = target.Definition
+		}
+		target.Definition = ""
+	case "SELECT", "DELETE":
+		if changes.Check != "" {
+			return nil, false, fmt.Errorf("check cannot be used with %s policies", target.Command)
+		}
+		target.Check = ""
+	}
+
+	recreate := target.Command != current.Command || target.Type != current.Type
+	if !recreate {
+		return alterPolicySQL(table, current, &target), false, nil
+	}
+	if target.Command != "INSERT" && target.Definition == "" {
+		return nil, false, fmt.Errorf("definition is required to turn an INSERT policy into a %s policy", target.Command)
+	}
+	return []string{
+		fmt.Sprintf(`DROP POLICY %s ON %s`, database.QuoteIdent(current.Name), table),
+		createPolicySQL(table, target),
+	}, true, nil
+}
+
+// alterPolicySQL builds the ALTER POLICY statements that turn current into
+// target when both apply to the same command
+func alterPolicySQL(table string, current, target *policySpec) []string {
+	var clauses []string
+	if strings.Join(target.Roles, ",") != strings.Join(current.Roles, ",") {
+		clauses = append(clauses, "TO "+quoteRoles(target.Roles))
+	}
+	if target.Definition != current.Definition {
+		clauses = append(clauses, fmt.Sprintf("USING (%s)", target.Definition))
+	}
+	if target.Check != current.Check {
+		clauses = append(clauses, fmt.Sprintf("WITH CHECK (%s)", target.Check))
+	}
+
+	var statements []string
+	if len(clauses) > 0 {
+		statements = append(statements, fmt.Sprintf(`ALTER POLICY %s ON %s %s`, database.QuoteIdent(current.Name), table, strings.Join(clauses, " ")))
+	}
+	if target.Name != current.Name {
+		statements = append(statements, fmt.Sprintf(`ALTER POLICY %s ON %s RENAME TO %s`, database.QuoteIdent(current.Name), table, database.QuoteIdent(target.Name)))
+	}
+	return statements
+}
+
+// loadPolicy reads the current settings of a policy, or nil if the table
+// has no policy with that name
+func loadPolicy(ctx context.Context, db database.Executor, table, name string) (*policySpec, error) {
+	query := `
+		SELECT
+			CASE WHEN p.polpermissive THEN 'PERMISSIVE' ELSE 'RESTRICTIVE' END AS type,
+			CASE p.polcmd
+				WHEN 'r' THEN 'SELECT'
+				WHEN 'a' THEN 'INSERT'
+				WHEN 'w' THEN 'UPDATE'
+				WHEN 'd' THEN 'DELETE'
+				ELSE 'ALL'
+			END AS command,
+			ARRAY(
+				SELECT CASE WHEN r.oid = 0 THEN 'public' ELSE pg_get_userbyid(r.oid) END
+				FROM unnest(p.polroles) AS r(oid)
+			) AS roles,
+			COALESCE(pg_get_expr(p.polqual, p.polrelid), '') AS definition,
+			COALESCE(pg_get_expr(p.polwithcheck, p.polrelid), '') AS check_expression
+		FROM pg_policy p
+		WHERE p.polrelid = to_regclass($1) AND p.polname = $2
+	`
+
+	result, err := db.Query(ctx, query, table, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Type       string   `json:"type"`
+		Command    string   `json:"command"`
+		Roles      []string `json:"roles"`
+		Definition string   `json:"definition"`
+		Check      string   `json:"check_expression"`
+	}
+	if err := result.Decode(&rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	row := rows[0]
+	return &policySpec{
+		Name:       name,
+		Command:    row.Command,
+		Type:       row.Type,
+		Roles:      row.Roles,
+		Definition: row.Definition,
+		Check:      row.Check,
+	}, nil
+}
+
 // DeleteRLSPolicyRequest represents the request body for deleting an RLS policy
 type DeleteRLSPolicyRequest struct {
-	Schema string `json:"schema"`
-	Table  string `json:"table"`
-	Name   string `json:"name"`
+	Schema string `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table  string `json:"table" jsonschema:"required" description:"Table name"`
+	Name   string `json:"name" jsonschema:"required" description:"Policy name"`
 }
 
 // DeleteRLSPolicy deletes an RLS policy
 func (dc *DatabaseController) DeleteRLSPolicy(c *gin.Context) {
 	var req DeleteRLSPolicyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -568,16 +994,13 @@ func (dc *DatabaseController) DeleteRLSPolicy(c *gin.Context) {
 		schema = "public"
 	}
 
-	tableIdentifier := fmt.Sprintf(`"%s"."%s"`, schema, req.Table)
-	sql := fmt.Sprintf(`DROP POLICY IF EXISTS "%s" ON %s`, req.Name, tableIdentifier)
+	tableIdentifier := database.QualifiedName(schema, req.Table)
+	sql := fmt.Sprintf(`DROP POLICY IF EXISTS %s ON %s`, database.QuoteIdent(req.Name), tableIdentifier)
 
-	var result interface{}
-	err := dc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	_, err := dc.db.Query(c.Request.Context(), sql)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
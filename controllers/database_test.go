@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/auth"
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/gin-gonic/gin"
+)
+
+// scriptExecutor runs scripts without a database, failing the commits it
+// is told to
+type scriptExecutor struct {
+	recordingExecutor
+	failCommits int
+	commits     int
+}
+
+func (e *scriptExecutor) ExecScript(ctx context.Context, sql string, commit bool) (*database.ScriptResult, error) {
+	if err := database.CheckTransactional(sql); err != nil {
+		return nil, err
+	}
+	if commit {
+		if e.failCommits > 0 {
+			e.failCommits--
+			return nil, errors.New("could not serialize access due to concurrent update")
+		}
+		e.commits++
+	}
+	return &database.ScriptResult{Statements: []*database.Result{}, Notices: []database.Notice{}, Committed: commit}, nil
+}
+
+// asPrincipal runs a handler for the principal with id
+func asPrincipal(handler gin.HandlerFunc, id string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := auth.WithPrincipal(c.Request.Context(), &auth.Principal{Name: id, ID: id})
+		c.Request = c.Request.WithContext(ctx)
+		handler(c)
+	}
+}
+
+func TestExecuteSQLWriteConfirmation(t *testing.T) {
+	db := &scriptExecutor{failCommits: 1}
+	controller := NewDatabaseController(nil, db, config.SQLConfig{WriteEnabled: true, ConfirmTTL: 10 * time.Minute}, nil)
+	alice := asPrincipal(controller.ExecuteSQLWrite, "api_key:alice")
+	bob := asPrincipal(controller.ExecuteSQLWrite, "api_key:bob")
+
+	const query = "UPDATE posts SET title = 'x' WHERE id = 1"
+	status, dryRun := callJSON(t, alice, gin.H{"query": query, "dry_run": true})
+	if status != http.StatusOK || dryRun["confirm"] == "" {
+		t.Fatalf("dry run: status %d, %v", status, dryRun)
+	}
+	commit := gin.H{"query": query, "confirm": dryRun["confirm"]}
+
+	if status, body := callJSON(t, bob, commit); status != http.StatusForbidden {
+		t.Errorf("commit by another principal: status %d, %v", status, body)
+	}
+	if status, body := callJSON(t, alice, gin.H{"query": query + " ", "confirm": dryRun["confirm"]}); status != http.StatusForbidden {
+		t.Errorf("commit of another query: status %d, %v", status, body)
+	}
+
+	// A failed commit leaves the token usable
+	if status, body := callJSON(t, alice, commit); status == http.StatusOK {
+		t.Fatalf("failing commit: status %d, %v", status, body)
+	}
+	if status, body := callJSON(t, alice, commit); status != http.StatusOK || body["committed"] != true {
+		t.Errorf("commit after a failed one: status %d, %v", status, body)
+	}
+	if status, body := callJSON(t, alice, commit); status != http.StatusForbidden {
+		t.Errorf("second commit with the same token: status %d, %v", status, body)
+	}
+	if db.commits != 1 {
+		t.Errorf("%d commits, want 1", db.commits)
+	}
+}
+
+func TestExecuteSQLWriteRefusesSideEffects(t *testing.T) {
+	controller := NewDatabaseController(nil, &scriptExecutor{}, config.SQLConfig{WriteEnabled: true, ConfirmTTL: 10 * time.Minute}, nil)
+	for _, query := range []string{
+		"SELECT pg_terminate_backend(42)",
+		"COPY posts TO PROGRAM 'sh -c id'",
+		"DO $$ BEGIN PERFORM dblink_exec('host=x', 'DROP TABLE t'); END $$",
+	} {
+		if status, body := callHandler(controller.ExecuteSQLWrite, gin.H{"query": query, "dry_run": true}); status == http.StatusOK {
+			t.Errorf("dry run of %q: status %d, %s", query, status, body)
+		}
+	}
+}
+
+func TestUpdatePolicySQL(t *testing.T) {
+	const table = `"public"."posts"`
+	selectPolicy := policySpec{Name: "read own", Command: "SELECT", Type: "PERMISSIVE", Roles: []string{"authenticated"}, Definition: "owner = auth.uid()"}
+	insertPolicy := policySpec{Name: "add own", Command: "INSERT", Type: "PERMISSIVE", Roles: []string{"authenticated"}, Check: "owner = auth.uid()"}
+	updatePolicy := policySpec{Name: "edit own", Command: "UPDATE", Type: "PERMISSIVE", Roles: []string{"public"}, Definition: "owner = auth.uid()", Check: "owner = auth.uid()"}
+
+	tests := []struct {
+		name     string
+		current  policySpec
+		changes  policyChanges
+		recreate bool
+		want     []string
+		err      string
+	}{
+		{
+			name:    "no changes",
+			current: selectPolicy,
+		},
+		{
+			name:    "roles",
+			current: selectPolicy,
+			changes: policyChanges{Roles: []string{"anon", "authenticated"}},
+			want:    []string{`ALTER POLICY "read own" ON "public"."posts" TO "anon", "authenticated"`},
+		},
+		{
+			name:    "same roles",
+			current: selectPolicy,
+			changes: policyChanges{Roles: []string{"authenticated"}, Operation: "select", Type: "permissive"},
+		},
+		{
+			name:    "definition and rename",
+			current: selectPolicy,
+			changes: policyChanges{Definition: "true", NewName: "read all"},
+			want: []string{
+				`ALTER POLICY "read own" ON "public"."posts" USING (true)`,
+				`ALTER POLICY "read own" ON "public"."posts" RENAME TO "read all"`,
+			},
+		},
+		{
+			name:    "insert definition becomes the check",
+			current: insertPolicy,
+			changes: policyChanges{Definition: "true"},
+			want:    []string{`ALTER POLICY "add own" ON "public"."posts" WITH CHECK (true)`},
+		},
+		{
+			name:    "update check",
+			current: updatePolicy,
+			changes: policyChanges{Check: "status <> 'locked'"},
+			want:    []string{`ALTER POLICY "edit own" ON "public"."posts" WITH CHECK (status <> 'locked')`},
+		},
+		{
+			name:     "operation",
+			current:  selectPolicy,
+			changes:  policyChanges{Operation: "update"},
+			recreate: true,
+			want:     []string{`DROP POLICY "read own" ON "public"."posts"`, `CREATE POLICY "read own" ON "public"."posts"`, `FOR UPDATE`, `USING (owner = auth.uid())`},
+		},
+		{
+			name:     "type",
+			current:  updatePolicy,
+			changes:  policyChanges{Type: "restrictive", NewName: "edit own strictly"},
+			recreate: true,
+			want:     []string{`DROP POLICY "edit own" ON "public"."posts"`, `CREATE POLICY "edit own strictly"`, `AS RESTRICTIVE`, `WITH CHECK (owner = auth.uid())`},
+		},
+		{
+			name:     "to insert",
+			current:  selectPolicy,
+			changes:  policyChanges{Operation: "insert"},
+			recreate: true,
+			want:     []string{`DROP POLICY "read own" ON "public"."posts"`, `FOR INSERT`, `WITH CHECK (owner = auth.uid())`},
+		},
+		{
+			name:    "from insert without a definition",
+			current: insertPolicy,
+			changes: policyChanges{Operation: "select"},
+			err:     "definition is required to turn an INSERT policy into a SELECT policy",
+		},
+		{
+			name:    "check on a select policy",
+			current: selectPolicy,
+			changes: policyChanges{Check: "true"},
+			err:     "check cannot be used with SELECT policies",
+		},
+		{
+			name:    "invalid operation",
+			current: selectPolicy,
+			changes: policyChanges{Operation: "truncate"},
+			err:     "invalid operation",
+		},
+		{
+			name:    "empty role",
+			current: selectPolicy,
+			changes: policyChanges{Roles: []string{" "}},
+			err:     "role names cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		current := tt.current
+		statements, recreate, err := updatePolicySQL(table, &current, tt.changes)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: updatePolicySQL() = %v, want an error containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: updatePolicySQL() = %v", tt.name, err)
+			continue
+		}
+		if recreate != tt.recreate {
+			t.Errorf("%s: recreate = %v, want %v", tt.name, recreate, tt.recreate)
+		}
+
+		if !tt.recreate {
+			if strings.Join(statements, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("%s: statements = %q, want %q", tt.name, statements, tt.want)
+			}
+			continue
+		}
+		// The CREATE statement spans several lines; check its parts
+		if len(statements) != 2 || statements[0] != tt.want[0] {
+			t.Errorf("%s: statements = %q, want %s and a CREATE POLICY", tt.name, statements, tt.want[0])
+			continue
+		}
+		for _, part := range tt.want[1:] {
+			if !strings.Contains(statements[1], part) {
+				t.Errorf("%s: %q does not contain %q", tt.name, statements[1], part)
+			}
+		}
+	}
+}
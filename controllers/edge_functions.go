@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
 	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
 	"github.com/gin-gonic/gin"
 )
@@ -13,24 +13,26 @@ import (
 // EdgeFunctionsController handles edge functions-related operations
 type EdgeFunctionsController struct {
 	supabase *supabase.SupabaseClientExtended
+	db       database.Executor
 }
 
 // NewEdgeFunctionsController creates a new edge functions controller
-func NewEdgeFunctionsController(client *supabase.SupabaseClientExtended) *EdgeFunctionsController {
+func NewEdgeFunctionsController(client *supabase.SupabaseClientExtended, db database.Executor) *EdgeFunctionsController {
 	return &EdgeFunctionsController{
 		supabase: client,
+		db:       db,
 	}
 }
 
 // GetEdgeFunctionsRequest represents the request body for getting edge functions
 type GetEdgeFunctionsRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" description:"Function name (optional, if not provided returns all functions)"`
 }
 
 // GetEdgeFunctions gets edge functions
 func (efc *EdgeFunctionsController) GetEdgeFunctions(c *gin.Context) {
 	var req GetEdgeFunctionsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -39,19 +41,16 @@ func (efc *EdgeFunctionsController) GetEdgeFunctions(c *gin.Context) {
 	// This may vary depending on the setup
 
 	// Option 1: If edge functions are stored in a specific table
-	query := "SELECT * FROM edge_functions"
+	query := database.NewQuery("SELECT * FROM edge_functions")
 
 	if req.Name != "" {
-		query += fmt.Sprintf(" WHERE name = '%s'", req.Name)
+		query.Write(" WHERE name = ").Arg(req.Name)
 	}
 
-	var result []map[string]interface{}
-	err := efc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": query,
-	}, &result)
+	result, err := efc.db.Query(c.Request.Context(), query.SQL(), query.Args()...)
 
-	if err == nil && result != nil {
-		c.JSON(http.StatusOK, result)
+	if err == nil {
+		c.JSON(http.StatusOK, result.Rows)
 		return
 	}
 
@@ -69,16 +68,16 @@ type ImportMap map[string]string
 
 // CreateEdgeFunctionRequest represents the request body for creating an edge function
 type CreateEdgeFunctionRequest struct {
-	Name      string    `json:"name"`
-	Code      string    `json:"code"`
-	VerifyJWT bool      `json:"verify_jwt"`
-	ImportMap ImportMap `json:"import_map"`
+	Name      string    `json:"name" jsonschema:"required" description:"Function name"`
+	Code      string    `json:"code" jsonschema:"required" description:"Function code (JavaScript/TypeScript)"`
+	VerifyJWT bool      `json:"verify_jwt" description:"Whether to verify JWT (optional, defaults to false)"`
+	ImportMap ImportMap `json:"import_map" description:"Optional import map for the function"`
 }
 
 // CreateEdgeFunction creates a new edge function
 func (efc *EdgeFunctionsController) CreateEdgeFunction(c *gin.Context) {
 	var req CreateEdgeFunctionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -97,26 +96,12 @@ func (efc *EdgeFunctionsController) CreateEdgeFunction(c *gin.Context) {
 		return
 	}
 
-	// Escape single quotes in code and import map
-	code := strings.ReplaceAll(req.Code, "'", "''")
-	importMapStr := strings.ReplaceAll(string(importMapJSON), "'", "''")
-
-	insertQuery := fmt.Sprintf(`
+	insertQuery := `
 		INSERT INTO edge_functions (name, code, verify_jwt, import_map, created_at, updated_at)
-		VALUES (
-			'%s',
-			'%s',
-			%t,
-			'%s',
-			NOW(),
-			NOW()
-		)
-	`, req.Name, code, req.VerifyJWT, importMapStr)
-
-	var insertResult interface{}
-	err = efc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": insertQuery,
-	}, &insertResult)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	`
+
+	_, err = efc.db.Query(c.Request.Context(), insertQuery, req.Name, req.Code, req.VerifyJWT, string(importMapJSON))
 
 	if err == nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -136,16 +121,16 @@ func (efc *EdgeFunctionsController) CreateEdgeFunction(c *gin.Context) {
 
 // UpdateEdgeFunctionRequest represents the request body for updating an edge function
 type UpdateEdgeFunctionRequest struct {
-	Name      string    `json:"name"`
-	Code      string    `json:"code"`
-	VerifyJWT *bool     `json:"verify_jwt"`
-	ImportMap ImportMap `json:"import_map"`
+	Name      string    `json:"name" jsonschema:"required" description:"Function name"`
+	Code      string    `json:"code" jsonschema:"required" description:"Function code (JavaScript/TypeScript)"`
+	VerifyJWT *bool     `json:"verify_jwt" description:"Whether to verify JWT (optional)"`
+	ImportMap ImportMap `json:"import_map" description:"Optional import map for the function"`
 }
 
 // UpdateEdgeFunction updates an existing edge function
 func (efc *EdgeFunctionsController) UpdateEdgeFunction(c *gin.Context) {
 	var req UpdateEdgeFunctionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -158,16 +143,10 @@ func (efc *EdgeFunctionsController) UpdateEdgeFunction(c *gin.Context) {
 	// For self-hosted Supabase, specific implementation for update
 
 	// Option 1: If there's a table for storing edge functions
-	// Escape single quotes in code
-	code := strings.ReplaceAll(req.Code, "'", "''")
-
-	updateQuery := fmt.Sprintf(`
-		UPDATE edge_functions 
-		SET code = '%s'
-	`, code)
+	updateQuery := database.NewQuery("UPDATE edge_functions SET code = ").Arg(req.Code)
 
 	if req.VerifyJWT != nil {
-		updateQuery += fmt.Sprintf(`, verify_jwt = %t`, *req.VerifyJWT)
+		updateQuery.Write(", verify_jwt = ").Arg(*req.VerifyJWT)
 	}
 
 	if req.ImportMap != nil {
@@ -176,17 +155,12 @@ func (efc *EdgeFunctionsController) UpdateEdgeFunction(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import map format"})
 			return
 		}
-		// Escape single quotes in import map
-		importMapStr := strings.ReplaceAll(string(importMapJSON), "'", "''")
-		updateQuery += fmt.Sprintf(`, import_map = '%s'`, importMapStr)
+		updateQuery.Write(", import_map = ").Arg(string(importMapJSON))
 	}
 
-	updateQuery += fmt.Sprintf(`, updated_at = NOW() WHERE name = '%s'`, req.Name)
+	updateQuery.Write(", updated_at = NOW() WHERE name = ").Arg(req.Name)
 
-	var updateResult interface{}
-	err := efc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": updateQuery,
-	}, &updateResult)
+	_, err := efc.db.Query(c.Request.Context(), updateQuery.SQL(), updateQuery.Args()...)
 
 	if err == nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -206,13 +180,13 @@ func (efc *EdgeFunctionsController) UpdateEdgeFunction(c *gin.Context) {
 
 // DeleteEdgeFunctionRequest represents the request body for deleting an edge function
 type DeleteEdgeFunctionRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" jsonschema:"required" description:"Function name"`
 }
 
 // DeleteEdgeFunction deletes an edge function
 func (efc *EdgeFunctionsController) DeleteEdgeFunction(c *gin.Context) {
 	var req DeleteEdgeFunctionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -225,15 +199,12 @@ func (efc *EdgeFunctionsController) DeleteEdgeFunction(c *gin.Context) {
 	// Implementation for self-hosted Supabase
 
 	// Option 1: If there's a table for storing edge functions
-	deleteQuery := fmt.Sprintf(`
+	deleteQuery := `
 		DELETE FROM edge_functions 
-		WHERE name = '%s'
-	`, req.Name)
+		WHERE name = $1
+	`
 
-	var deleteResult interface{}
-	err := efc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": deleteQuery,
-	}, &deleteResult)
+	_, err := efc.db.Query(c.Request.Context(), deleteQuery, req.Name)
 
 	if err == nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -253,13 +224,13 @@ func (efc *EdgeFunctionsController) DeleteEdgeFunction(c *gin.Context) {
 
 // DeployEdgeFunctionRequest represents the request body for deploying an edge function
 type DeployEdgeFunctionRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" jsonschema:"required" description:"Function name"`
 }
 
 // DeployEdgeFunction deploys an edge function
 func (efc *EdgeFunctionsController) DeployEdgeFunction(c *gin.Context) {
 	var req DeployEdgeFunctionRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+)
+
+// Embed represents a related table to embed in query results through a
+// foreign key, like author:users(name) in a PostgREST select
+type Embed struct {
+	Relation string           `json:"relation" jsonschema:"required" description:"Related table to embed"`
+	Alias    string           `json:"alias" description:"Key of the embedded rows in the result (optional, defaults to the relation name)"`
+	Select   string           `json:"select" jsonschema:"default=*" description:"Comma-separated list of columns of the related table (optional, defaults to *)"`
+	Hint     string           `json:"hint" description:"Foreign key constraint, column or junction table to use when several relationships link the tables (optional)"`
+	Inner    bool             `json:"inner" description:"Only return parent rows that have matching related rows (optional, defaults to false)"`
+	Where    []WhereCondition `json:"where" description:"Conditions on the related rows (optional)"`
+	Order    []OrderBy        `json:"order" description:"Ordering of the related rows (optional)"`
+	Limit    int              `json:"limit" jsonschema:"minimum=1" description:"Maximum number of related rows per parent row (optional)"`
+	Embed    []Embed          `json:"embed" description:"Relations to embed inside this one (optional)"`
+}
+
+// foreignKey represents a foreign key between two tables of a schema
+type foreignKey struct {
+	Name              string   `json:"name"`
+	Table             string   `json:"table"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+}
+
+// relationship is one way of joining a parent table to a relation
+type relationship struct {
+	kind string // many-to-one, one-to-many or many-to-many
+	fk   foreignKey
+	via  string // junction table of a many-to-many relationship
+}
+
+func (r relationship) String() string {
+	if r.kind == "many-to-many" {
+		return fmt.Sprintf("%s (many-to-many junction table)", r.via)
+	}
+	return fmt.Sprintf("%s (%s on %s)", r.fk.Name, r.kind, strings.Join(r.fk.Columns, ", "))
+}
+
+var (
+	relationNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+	selectColumnPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*:)?[A-Za-z_][A-Za-z0-9_$]*(->>?[A-Za-z0-9_$-]+)*(::[A-Za-z_][A-Za-z0-9_ ]*(\[\])?)?$`)
+)
+
+// loadForeignKeys returns the foreign keys between tables of a schema
+func loadForeignKeys(ctx context.Context, db database.Executor, schema string) ([]foreignKey, error) {
+	query := `
+		SELECT
+			c.conname AS name,
+			cl.relname AS table,
+			(SELECT json_agg(a.attname ORDER BY k.n)
+			   FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, n)
+			   JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum) AS columns,
+			rcl.relname AS referenced_table,
+			(SELECT json_agg(a.attname ORDER BY k.n)
+			   FROM unnest(c.confkey) WITH ORDINALITY AS k(attnum, n)
+			   JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum) AS referenced_columns
+		FROM pg_constraint c
+		JOIN pg_class cl ON cl.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = cl.relnamespace
+		JOIN pg_class rcl ON rcl.oid = c.confrelid
+		JOIN pg_namespace rn ON rn.oid = rcl.relnamespace
+		WHERE c.contype = 'f' AND n.nspname = $1 AND rn.nspname = $1
+		ORDER BY cl.relname, c.conname
+	`
+
+	result, err := db.Query(ctx, query, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var fks []foreignKey
+	if err := result.Decode(&fks); err != nil {
+		return nil, err
+	}
+	return fks, nil
+}
+
+// relationships lists the ways parent and relation are linked, keeping
+// only those that match the hint when one is given
+func relationships(fks []foreignKey, parent, relation, hint string) []relationship {
+	var found []relationship
+	for _, fk := range fks {
+		if fk.Table == parent && fk.ReferencedTable == relation {
+			found = append(found, relationship{kind: "many-to-one", fk: fk})
+		}
+		if fk.Table == relation && fk.ReferencedTable == parent {
+			found = append(found, relationship{kind: "one-to-many", fk: fk})
+		}
+	}
+
+	// Junction tables with a foreign key to each side
+	for _, toParent := range fks {
+		if toParent.ReferencedTable != parent || toParent.Table == parent || toParent.Table == relation {
+			continue
+		}
+		for _, toRelation := range fks {
+			if toRelation.Table == toParent.Table && toRelation.ReferencedTable == relation && toRelation.Name != toParent.Name {
+				found = append(found, relationship{kind: "many-to-many", fk: toRelation, via: toParent.Table})
+			}
+		}
+	}
+
+	if hint == "" {
+		return found
+	}
+
+	var matching []relationship
+	for _, r := range found {
+		switch {
+		case r.kind == "many-to-many" && r.via == hint:
+		case r.kind != "many-to-many" && r.fk.Name == hint:
+		case r.kind != "many-to-many" && len(r.fk.Columns) == 1 && r.fk.Columns[0] == hint:
+		default:
+			continue
+		}
+		matching = append(matching, r)
+	}
+	return matching
+}
+
+// relatedTables lists the tables linked to table, for error messages
+func relatedTables(fks []foreignKey, table string) []string {
+	seen := map[string]bool{}
+	for _, fk := range fks {
+		if fk.Table == table {
+			seen[fk.ReferencedTable] = true
+		}
+		if fk.ReferencedTable == table {
+			seen[fk.Table] = true
+		}
+	}
+
+	tables := make([]string, 0, len(seen))
+	for t := range seen {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// compileEmbeds validates the embed tree of parent against the foreign keys
+// and returns the select entries for it. Filters, ordering and limits of
+// the embedded relations are added to query.
+func compileEmbeds(query *supabase.PostgrestRequest, fks []foreignKey, parent, path string, embeds []Embed) ([]string, error) {
+	entries := make([]string, 0, len(embeds))
+	for _, embed := range embeds {
+		if !relationNamePattern.MatchString(embed.Relation) {
+			return nil, fmt.Errorf("invalid relation %q", embed.Relation)
+		}
+		if embed.Alias != "" && !relationNamePattern.MatchString(embed.Alias) {
+			return nil, fmt.Errorf("invalid alias %q for relation %s", embed.Alias, embed.Relation)
+		}
+		if embed.Hint != "" && !relationNamePattern.MatchString(embed.Hint) {
+			return nil, fmt.Errorf("invalid hint %q for relation %s", embed.Hint, embed.Relation)
+		}
+
+		found := relationships(fks, parent, embed.Relation, embed.Hint)
+		switch {
+		case len(found) == 0 && embed.Hint != "":
+			return nil, fmt.Errorf("no relationship between %s and %s matches hint %q", parent, embed.Relation, embed.Hint)
+		case len(found) == 0:
+			related := relatedTables(fks, parent)
+			if len(related) == 0 {
+				return nil, fmt.Errorf("no foreign key links %s and %s; %s has no relationships", parent, embed.Relation, parent)
+			}
+			return nil, fmt.Errorf("no foreign key links %s and %s; related tables are: %s", parent, embed.Relation, strings.Join(related, ", "))
+		case len(found) > 1:
+			options := make([]string, len(found))
+			for i, r := range found {
+				options[i] = r.String()
+			}
+			return nil, fmt.Errorf("%s and %s are linked in several ways; set hint to one of: %s", parent, embed.Relation, strings.Join(options, "; "))
+		}
+
+		name := embed.Relation
+		if embed.Alias != "" {
+			name = embed.Alias
+		}
+		prefix := path + name + "."
+
+		columns, err := selectColumns(embed.Select)
+		if err != nil {
+			return nil, fmt.Errorf("relation %s: %v", embed.Relation, err)
+		}
+		nested, err := compileEmbeds(query, fks, embed.Relation, prefix, embed.Embed)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := applyWhere(query, prefix, embed.Where); err != nil {
+			return nil, fmt.Errorf("relation %s: %v", embed.Relation, err)
+		}
+		if len(embed.Order) > 0 {
+			order, err := orderParam(embed.Order)
+			if err != nil {
+				return nil, fmt.Errorf("relation %s: %v", embed.Relation, err)
+			}
+			query.Param(prefix+"order", order)
+		}
+		if embed.Limit > 0 {
+			query.Param(prefix+"limit", strconv.Itoa(embed.Limit))
+		}
+
+		entry := embed.Relation
+		if embed.Alias != "" {
+			entry = embed.Alias + ":" + entry
+		}
+		if embed.Hint != "" {
+			entry += "!" + embed.Hint
+		}
+		if embed.Inner {
+			entry += "!inner"
+		}
+		entries = append(entries, entry+"("+strings.Join(append(columns, nested...), ",")+")")
+	}
+	return entries, nil
+}
+
+// selectColumns checks the column list of an embedded relation
+func selectColumns(selectList string) ([]string, error) {
+	if strings.TrimSpace(selectList) == "" {
+		return []string{"*"}, nil
+	}
+
+	var columns []string
+	for _, column := range strings.Split(selectList, ",") {
+		column = strings.TrimSpace(column)
+		if column != "*" && !selectColumnPattern.MatchString(column) {
+			return nil, fmt.Errorf("invalid column %q in select; use embed for nested relations", column)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
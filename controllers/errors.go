@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+	"github.com/gin-gonic/gin"
+)
+
+// invalidRequest marks an error caused by the arguments of a tool call
+type invalidRequest struct {
+	err error
+}
+
+func (e *invalidRequest) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidRequest) Unwrap() error {
+	return e.err
+}
+
+// badRequest formats an error that errorStatus reports as 400
+func badRequest(format string, args ...interface{}) error {
+	return &invalidRequest{err: fmt.Errorf(format, args...)}
+}
+
+// sqlError builds the response body for a failed SQL statement. Errors
+// reported by Postgres carry their SQLSTATE code, detail and hint; errors
+// returned by the Supabase APIs are described by apiError.
+func sqlError(err error) gin.H {
+	var dbErr *database.Error
+	if !errors.As(err, &dbErr) {
+		return apiError(err)
+	}
+
+	body := gin.H{
+		"error": dbErr.Message,
+		"code":  dbErr.Code,
+	}
+	if dbErr.Detail != "" {
+		body["detail"] = dbErr.Detail
+	}
+	if dbErr.Hint != "" {
+		body["hint"] = dbErr.Hint
+	}
+	if dbErr.Position != 0 {
+		body["position"] = dbErr.Position
+	}
+	return body
+}
+
+// apiError builds the response body for a failed Supabase API request,
+// keeping the PostgREST error code, details and hint
+func apiError(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+
+	var supabaseErr *supabase.APIError
+	if errors.As(err, &supabaseErr) {
+		body["error"] = supabaseErr.Message
+		body["status"] = supabaseErr.Status
+		if supabaseErr.Code != "" {
+			body["code"] = supabaseErr.Code
+		}
+		if supabaseErr.Details != "" {
+			body["details"] = supabaseErr.Details
+		}
+		if supabaseErr.Hint != "" {
+			body["hint"] = supabaseErr.Hint
+		}
+	}
+
+	return body
+}
+
+// errorStatus picks the HTTP status for an error. Postgres errors are
+// mapped from their SQLSTATE the way PostgREST does, and Supabase API errors
+// keep their status, except that 5xx become 502, since they come from
+// upstream, and 401 and 403 become 502 too, so that clients do not take
+// them for a rejection of their own credentials; the upstream status stays
+// in the body. Unreachable services and responses that cannot be decoded
+// give 502 or 504. Features missing from the configuration give 501,
+// objects over the size limits 413 and invalid arguments 400. Anything
+// else is an internal error.
+func errorStatus(err error) int {
+	if errors.Is(err, errImpersonationDisabled) || errors.Is(err, database.ErrIdentityUnsupported) ||
+		errors.Is(err, supabase.ErrAnonKeyRequired) || errors.Is(err, errUploadDirDisabled) {
+		return http.StatusNotImplemented
+	}
+	if errors.Is(err, supabase.ErrObjectTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	var dbErr *database.Error
+	if errors.As(err, &dbErr) {
+		return sqlStateStatus(dbErr.Code)
+	}
+
+	var supabaseErr *supabase.APIError
+	if errors.As(err, &supabaseErr) {
+		switch {
+		case supabaseErr.Status >= 500, supabaseErr.Status == http.StatusUnauthorized, supabaseErr.Status == http.StatusForbidden:
+			return http.StatusBadGateway
+		case supabaseErr.Status >= 400:
+			return supabaseErr.Status
+		}
+	}
+
+	var invalid *invalidRequest
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return http.StatusGatewayTimeout
+		}
+		return http.StatusBadGateway
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
+
+// sqlStateStatus maps a SQLSTATE to an HTTP status
+func sqlStateStatus(code string) int {
+	switch code {
+	case "23503", "23505", "40001", "40P01":
+		return http.StatusConflict
+	case "25006":
+		return http.StatusMethodNotAllowed
+	case "42501":
+		return http.StatusForbidden
+	case "42883", "42P01":
+		return http.StatusNotFound
+	case "57014":
+		return http.StatusGatewayTimeout
+	case "P0001":
+		return http.StatusBadRequest
+	}
+
+	switch {
+	case strings.HasPrefix(code, "08"), strings.HasPrefix(code, "53"):
+		return http.StatusServiceUnavailable
+	case strings.HasPrefix(code, "0L"), strings.HasPrefix(code, "0P"), strings.HasPrefix(code, "28"):
+		return http.StatusForbidden
+	case strings.HasPrefix(code, "09"), strings.HasPrefix(code, "25"), strings.HasPrefix(code, "2D"),
+		strings.HasPrefix(code, "38"), strings.HasPrefix(code, "39"), strings.HasPrefix(code, "3B"),
+		strings.HasPrefix(code, "40"), strings.HasPrefix(code, "54"), strings.HasPrefix(code, "55"),
+		strings.HasPrefix(code, "57"), strings.HasPrefix(code, "58"), strings.HasPrefix(code, "F0"),
+		strings.HasPrefix(code, "HV"), strings.HasPrefix(code, "P0"), strings.HasPrefix(code, "XX"):
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+)
+
+func TestErrorStatus(t *testing.T) {
+	var syntaxErr error = &json.SyntaxError{}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"postgres unique violation", &database.Error{Code: "23505"}, http.StatusConflict},
+		{"postgres permission denied", &database.Error{Code: "42501"}, http.StatusForbidden},
+		{"upstream not found", &supabase.APIError{Status: http.StatusNotFound}, http.StatusNotFound},
+		{"upstream bad request", &supabase.APIError{Status: http.StatusBadRequest}, http.StatusBadRequest},
+		{"upstream unauthorized", &supabase.APIError{Status: http.StatusUnauthorized}, http.StatusBadGateway},
+		{"upstream forbidden", &supabase.APIError{Status: http.StatusForbidden}, http.StatusBadGateway},
+		{"upstream server error", &supabase.APIError{Status: http.StatusServiceUnavailable}, http.StatusBadGateway},
+		{"undecodable response", fmt.Errorf("decoding rows: %w", syntaxErr), http.StatusBadGateway},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"missing configuration", errImpersonationDisabled, http.StatusNotImplemented},
+		{"object too large", fmt.Errorf("%w: limit is 10 bytes", supabase.ErrObjectTooLarge), http.StatusRequestEntityTooLarge},
+		{"invalid argument", badRequest("user_id must be a UUID"), http.StatusBadRequest},
+		{"unclassified", errors.New("something broke"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := errorStatus(tt.err); got != tt.want {
+			t.Errorf("errorStatus(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+)
+
+// WhereCondition represents a where condition for table queries. A
+// condition either compares a column (column, operator, value) or groups
+// other conditions with and/or; both forms can be negated with not.
+type WhereCondition struct {
+	Column     string           `json:"column" description:"Column name; JSON paths (data->>name, data->tags->0) and casts (price::text) are accepted"`
+	Operator   string           `json:"operator" jsonschema:"enum=eq|neq|gt|gte|lt|lte|like|ilike|match|imatch|is|isdistinct|in|cs|cd|ov|sl|sr|nxl|nxr|adj|fts|plfts|phfts|wfts" description:"Comparison operator: eq, neq, gt, gte, lt, lte; like/ilike (% or * wildcards); match/imatch (POSIX regex); is (null, true, false, unknown); isdistinct; in (array value); cs/cd (contains/contained in: array, JSON object or range); ov (overlaps); sl, sr, nxl, nxr, adj (range operators); fts, plfts, phfts, wfts (full-text search)"`
+	Value      interface{}      `json:"value" description:"Value to compare against; arrays for in, cs, cd, ov and any/all, [lower, upper] or a range literal for range operators"`
+	Quantifier string           `json:"quantifier" jsonschema:"enum=any|all" description:"Compare against any or all elements of an array value (eq, neq, gt, gte, lt, lte, like, ilike, match, imatch)"`
+	Config     string           `json:"config" description:"Text search configuration for full-text operators, e.g. english"`
+	Not        bool             `json:"not" description:"Negate the condition or group"`
+	And        []WhereCondition `json:"and" description:"Conditions that must all match (instead of column/operator)"`
+	Or         []WhereCondition `json:"or" description:"Conditions of which at least one must match (instead of column/operator)"`
+}
+
+// filterOperators maps each PostgREST operator to the kind of value it takes
+var filterOperators = map[string]string{
+	"eq": "scalar", "neq": "scalar", "gt": "scalar", "gte": "scalar", "lt": "scalar", "lte": "scalar",
+	"like": "pattern", "ilike": "pattern", "match": "scalar", "imatch": "scalar",
+	"is": "is", "isdistinct": "nullable", "in": "list",
+	"cs": "container", "cd": "container", "ov": "container",
+	"sl": "range", "sr": "range", "nxl": "range", "nxr": "range", "adj": "range",
+	"fts": "text", "plfts": "text", "phfts": "text", "wfts": "text",
+}
+
+// quantifiable lists the operators that accept the any/all modifier
+var quantifiable = map[string]bool{
+	"eq": true, "neq": true, "gt": true, "gte": true, "lt": true, "lte": true,
+	"like": true, "ilike": true, "match": true, "imatch": true,
+}
+
+var (
+	filterColumnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(->>?[A-Za-z0-9_$-]+)*(::[A-Za-z_][A-Za-z0-9_ ]*(\[\])?)?$`)
+	textConfigPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+)
+
+// applyWhere adds the where conditions to a PostgREST request. Top-level
+// comparisons become column parameters and groups become logic trees; all
+// of them must match. path prefixes the parameters of an embedded
+// relation, e.g. "author." filters the embedded author rows.
+func applyWhere(query *supabase.PostgrestRequest, path string, where []WhereCondition) error {
+	for _, condition := range where {
+		if condition.isGroup() {
+			operator, conditions, err := condition.group()
+			if err != nil {
+				return err
+			}
+			query.Group(path+operator, conditions...)
+			continue
+		}
+
+		operator, value, err := condition.compile(false)
+		if err != nil {
+			return err
+		}
+		if condition.Not {
+			operator = "not." + operator
+		}
+		query.Filter(path+condition.Column, operator, value)
+	}
+	return nil
+}
+
+func (wc WhereCondition) isGroup() bool {
+	return len(wc.And) > 0 || len(wc.Or) > 0
+}
+
+// group compiles a group into its logic tree operator and members
+func (wc WhereCondition) group() (string, []string, error) {
+	if len(wc.And) > 0 && len(wc.Or) > 0 {
+		return "", nil, fmt.Errorf("a condition cannot have both and and or")
+	}
+	if wc.Column != "" || wc.Operator != "" {
+		return "", nil, fmt.Errorf("a condition cannot have both a column and an and/or group")
+	}
+
+	operator, members := "and", wc.And
+	if len(wc.Or) > 0 {
+		operator, members = "or", wc.Or
+	}
+	if wc.Not {
+		operator = "not." + operator
+	}
+
+	conditions := make([]string, 0, len(members))
+	for _, member := range members {
+		condition, err := member.tree()
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return operator, conditions, nil
+}
+
+// tree compiles a condition as a logic tree member, e.g. age.gt.18 or
+// not.or(a.is.null,b.is.null)
+func (wc WhereCondition) tree() (string, error) {
+	if wc.isGroup() {
+		operator, conditions, err := wc.group()
+		if err != nil {
+			return "", err
+		}
+		return operator + "(" + strings.Join(conditions, ",") + ")", nil
+	}
+
+	operator, value, err := wc.compile(true)
+	if err != nil {
+		return "", err
+	}
+	if wc.Not {
+		return "not." + wc.Column + "." + operator + "." + value, nil
+	}
+	return wc.Column + "." + operator + "." + value, nil
+}
+
+// compile validates a comparison and returns its operator (with modifiers)
+// and encoded value. inTree selects the quoting used inside logic trees.
+func (wc WhereCondition) compile(inTree bool) (string, string, error) {
+	if wc.Column == "" {
+		return "", "", fmt.Errorf("where condition is missing a column")
+	}
+	if !filterColumnPattern.MatchString(wc.Column) {
+		return "", "", fmt.Errorf("invalid column %q in where condition", wc.Column)
+	}
+
+	kind, ok := filterOperators[wc.Operator]
+	if !ok {
+		return "", "", fmt.Errorf("unknown operator %q for column %s", wc.Operator, wc.Column)
+	}
+
+	operator := wc.Operator
+	if wc.Quantifier != "" {
+		if wc.Quantifier != "any" && wc.Quantifier != "all" {
+			return "", "", fmt.Errorf("invalid quantifier %q for column %s; must be any or all", wc.Quantifier, wc.Column)
+		}
+		if !quantifiable[wc.Operator] {
+			return "", "", fmt.Errorf("operator %s does not accept a quantifier", wc.Operator)
+		}
+
+		values, ok := asList(wc.Value)
+		if !ok {
+			return "", "", fmt.Errorf("operator %s(%s) requires an array value for column %s", wc.Operator, wc.Quantifier, wc.Column)
+		}
+		if kind == "pattern" {
+			for i, v := range values {
+				values[i] = likePattern(v)
+			}
+		}
+		literal, err := arrayLiteral(values)
+		if err != nil {
+			return "", "", fmt.Errorf("column %s: %v", wc.Column, err)
+		}
+		return operator + "(" + wc.Quantifier + ")", quoteTree(literal, inTree), nil
+	}
+
+	switch kind {
+	case "scalar", "pattern", "nullable":
+		if wc.Value == nil && kind != "nullable" {
+			return "", "", fmt.Errorf("operator %s cannot compare column %s with null; use is", wc.Operator, wc.Column)
+		}
+		value, err := scalarValue(wc.Value)
+		if err != nil {
+			return "", "", fmt.Errorf("column %s: %v", wc.Column, err)
+		}
+		if kind == "pattern" {
+			value = likePattern(value)
+		}
+		return operator, quoteTree(value, inTree), nil
+
+	case "is":
+		value, err := scalarValue(wc.Value)
+		if err != nil {
+			return "", "", fmt.Errorf("column %s: %v", wc.Column, err)
+		}
+		value = strings.ToLower(value)
+		switch value {
+		case "null", "true", "false", "unknown":
+			return operator, value, nil
+		}
+		return "", "", fmt.Errorf("operator is only accepts null, true, false or unknown for column %s", wc.Column)
+
+	case "list":
+		values, ok := asList(wc.Value)
+		if !ok {
+			return "", "", fmt.Errorf("operator in requires an array value for column %s", wc.Column)
+		}
+		items := make([]string, len(values))
+		for i, v := range values {
+			item, err := scalarValue(v)
+			if err != nil {
+				return "", "", fmt.Errorf("column %s: %v", wc.Column, err)
+			}
+			items[i] = quoteTree(item, true)
+		}
+		return operator, "(" + strings.Join(items, ",") + ")", nil
+
+	case "container":
+		var value string
+		switch v := wc.Value.(type) {
+		case string:
+			// Array or range literal, e.g. {a,b} or [1,10)
+			value = v
+		case map[string]interface{}:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return "", "", err
+			}
+			value = string(data)
+		default:
+			values, ok := asList(wc.Value)
+			if !ok {
+				return "", "", fmt.Errorf("operator %s requires an array, object or literal value for column %s", wc.Operator, wc.Column)
+			}
+			literal, err := arrayLiteral(values)
+			if err != nil {
+				return "", "", fmt.Errorf("column %s: %v", wc.Column, err)
+			}
+			value = literal
+		}
+		return operator, quoteTree(value, inTree), nil
+
+	case "range":
+		var value string
+		switch v := wc.Value.(type) {
+		case string:
+			value = v
+		default:
+			bounds, ok := asList(wc.Value)
+			if !ok || len(bounds) != 2 {
+				return "", "", fmt.Errorf("operator %s requires a range literal or [lower, upper] for column %s", wc.Operator, wc.Column)
+			}
+			lower, err := rangeBound(bounds[0])
+			if err != nil {
+				return "", "", fmt.Errorf("column %s: %v", wc.Column, err)
+			}
+			upper, err := rangeBound(bounds[1])
+			if err != nil {
+				return "", "", fmt.Errorf("column %s: %v", wc.Column, err)
+			}
+			value = "[" + lower + "," + upper + ")"
+		}
+		return operator, quoteTree(value, inTree), nil
+
+	case "text":
+		value, ok := wc.Value.(string)
+		if !ok || value == "" {
+			return "", "", fmt.Errorf("operator %s requires a search string for column %s", wc.Operator, wc.Column)
+		}
+		if wc.Config != "" {
+			if !textConfigPattern.MatchString(wc.Config) {
+				return "", "", fmt.Errorf("invalid text search configuration %q", wc.Config)
+			}
+			operator += "(" + wc.Config + ")"
+		}
+		return operator, quoteTree(value, inTree), nil
+	}
+
+	return "", "", fmt.Errorf("unknown operator %q for column %s", wc.Operator, wc.Column)
+}
+
+// scalarValue encodes a single JSON value for a filter. Numbers keep their
+// exact form, objects are sent as JSON and arrays are rejected.
+func scalarValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case []interface{}:
+		return "", fmt.Errorf("array values are only accepted by in, cs, cd, ov and any/all comparisons")
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+// filterValue formats a value for the PostgREST query string
+func filterValue(value interface{}) string {
+	s, err := scalarValue(value)
+	if err != nil {
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	return s
+}
+
+// asList returns the elements of an array value
+func asList(value interface{}) ([]interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	if list, ok := value.([]interface{}); ok {
+		return append([]interface{}{}, list...), true
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// arrayLiteral builds a Postgres array literal such as {a,"b c",NULL}
+func arrayLiteral(values []interface{}) (string, error) {
+	items := make([]string, len(values))
+	for i, v := range values {
+		if v == nil {
+			items[i] = "NULL"
+			continue
+		}
+		item, err := scalarValue(v)
+		if err != nil {
+			return "", err
+		}
+		if item == "" || strings.EqualFold(item, "null") || strings.ContainsAny(item, "{}\",\\ \t\n") {
+			item = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(item) + `"`
+		}
+		items[i] = item
+	}
+	return "{" + strings.Join(items, ",") + "}", nil
+}
+
+// rangeBound encodes one bound of a range; null means unbounded
+func rangeBound(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	bound, err := scalarValue(value)
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(bound, "[](),\"\\ ") {
+		bound = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(bound) + `"`
+	}
+	return bound, nil
+}
+
+// likePattern converts SQL % wildcards to the * accepted in URLs
+func likePattern(value interface{}) string {
+	return strings.ReplaceAll(filterValue(value), "%", "*")
+}
+
+// quoteTree quotes a value inside a logic tree or in list when it contains
+// characters reserved by the PostgREST grammar (, . : ( ) and quotes)
+func quoteTree(value string, inTree bool) string {
+	if !inTree {
+		return value
+	}
+	if value == "" || strings.ContainsAny(value, ",.:()\"\\ ") {
+		value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+	}
+	return value
+}
+
+// treeValue formats a value inside a PostgREST logic tree
+func treeValue(value interface{}) string {
+	return quoteTree(filterValue(value), true)
+}
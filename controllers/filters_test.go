@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// decodeCondition decodes a condition the way bindJSON does
+func decodeCondition(t *testing.T, raw string) WhereCondition {
+	t.Helper()
+	var condition WhereCondition
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&condition); err != nil {
+		t.Fatalf("%s: %v", raw, err)
+	}
+	return condition
+}
+
+func TestWhereConditionTree(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		want      string
+	}{
+		{"plain value", `{"column": "title", "operator": "eq", "value": "hello"}`, `title.eq.hello`},
+		{"comma", `{"column": "title", "operator": "eq", "value": "a,b"}`, `title.eq."a,b"`},
+		{"parentheses", `{"column": "title", "operator": "eq", "value": "f(x)"}`, `title.eq."f(x)"`},
+		{"dot", `{"column": "host", "operator": "eq", "value": "example.com"}`, `host.eq."example.com"`},
+		{"colon", `{"column": "created_at", "operator": "lt", "value": "2024-01-01T10:00:00"}`, `created_at.lt."2024-01-01T10:00:00"`},
+		{"quote and backslash", `{"column": "title", "operator": "eq", "value": "say \"hi\" \\o/"}`, `title.eq."say \"hi\" \\o/"`},
+		{"operator lookalike", `{"column": "title", "operator": "eq", "value": "x),id.gt.0,or(id.eq.1"}`, `title.eq."x),id.gt.0,or(id.eq.1"`},
+		{"empty string", `{"column": "title", "operator": "eq", "value": ""}`, `title.eq.""`},
+		{"bigint", `{"column": "id", "operator": "gt", "value": 9007199254740993}`, `id.gt.9007199254740993`},
+		{"decimal", `{"column": "price", "operator": "gt", "value": 1.5}`, `price.gt."1.5"`},
+		{"like", `{"column": "title", "operator": "ilike", "value": "%a.b%"}`, `title.ilike."*a.b*"`},
+		{"in list", `{"column": "tag", "operator": "in", "value": ["a", "b,c", "d.e", "(f)"]}`, `tag.in.(a,"b,c","d.e","(f)")`},
+		{"any", `{"column": "tag", "operator": "eq", "quantifier": "any", "value": ["a", "b c"]}`, `tag.eq(any)."{a,\"b c\"}"`},
+		{"contains", `{"column": "tags", "operator": "cs", "value": ["x", "y"]}`, `tags.cs."{x,y}"`},
+		{"range", `{"column": "during", "operator": "adj", "value": [1, 10]}`, `during.adj."[1,10)"`},
+		{"full-text", `{"column": "body", "operator": "fts", "config": "english", "value": "cat:*"}`, `body.fts(english)."cat:*"`},
+		{"is", `{"column": "deleted_at", "operator": "is", "value": null}`, `deleted_at.is.null`},
+		{"negated", `{"column": "title", "operator": "eq", "value": "a.b", "not": true}`, `not.title.eq."a.b"`},
+		{"nested group", `{"or": [{"column": "a", "operator": "eq", "value": "1,2"}, {"and": [{"column": "b", "operator": "is", "value": null}], "not": true}]}`, `or(a.eq."1,2",not.and(b.is.null))`},
+	}
+
+	for _, tt := range tests {
+		got, err := decodeCondition(t, tt.condition).tree()
+		if err != nil {
+			t.Errorf("%s: tree() = %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: tree() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWhereConditionCompileOutsideTree(t *testing.T) {
+	// Column parameters are not parsed as logic trees, so their values are
+	// sent as they are
+	condition := WhereCondition{Column: "title", Operator: "eq", Value: "a,b.(c):d"}
+	operator, value, err := condition.compile(false)
+	if err != nil || operator != "eq" || value != "a,b.(c):d" {
+		t.Errorf("compile(false) = %s, %s, %v, want eq and the value as is", operator, value, err)
+	}
+}
+
+func TestWhereConditionErrors(t *testing.T) {
+	tests := []string{
+		`{"column": "title"}`,
+		`{"column": "title; drop", "operator": "eq", "value": "x"}`,
+		`{"column": "title", "operator": "eq", "value": null}`,
+		`{"column": "title", "operator": "eq", "value": [1, 2]}`,
+		`{"column": "title", "operator": "is", "value": "maybe"}`,
+		`{"column": "title", "operator": "in", "value": "a"}`,
+		`{"column": "title", "operator": "gt", "quantifier": "some", "value": [1]}`,
+		`{"column": "title", "operator": "in", "quantifier": "any", "value": [1]}`,
+		`{"column": "body", "operator": "fts", "config": "english;", "value": "cat"}`,
+		`{"column": "during", "operator": "adj", "value": [1]}`,
+		`{"and": [{"column": "a", "operator": "eq", "value": 1}], "or": [{"column": "b", "operator": "eq", "value": 1}]}`,
+		`{"column": "a", "and": [{"column": "b", "operator": "eq", "value": 1}]}`,
+		`{"or": [{"column": "a", "operator": "nope", "value": 1}]}`,
+	}
+
+	for _, raw := range tests {
+		if got, err := decodeCondition(t, raw).tree(); err == nil {
+			t.Errorf("tree(%s) = %s, want an error", raw, got)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/gin-gonic/gin"
+)
+
+// marker appears in every hostile name; it must never reach the SQL outside
+// a quoted identifier or string literal
+const marker = "pwned"
+
+// hostileNames try to break out of the quoting of identifiers and literals
+var hostileNames = []string{
+	`pwned"; DROP TABLE users; --`,
+	`pwned'; DROP TABLE users; --`,
+	`pwned\'; DROP TABLE users; --`,
+	`pwned"."users`,
+	`U&"pwned\0022; DROP TABLE users; --"`,
+	`U&'pwned\0027'`,
+	`$$; DROP TABLE pwned; $$`,
+	`$x$pwned$x$`,
+	`pwned */ DROP TABLE users; /*`,
+}
+
+// recordingExecutor records the statements it is given. Queries reading a
+// policy or a bucket get a row back, so that handlers reach their DDL.
+type recordingExecutor struct {
+	bucket     string
+	statements []string
+}
+
+func (e *recordingExecutor) Query(ctx context.Context, sql string, args ...interface{}) (*database.Result, error) {
+	e.statements = append(e.statements, sql)
+
+	result := &database.Result{Rows: []map[string]interface{}{}}
+	switch {
+	case strings.Contains(sql, "FROM pg_policy p"):
+		result.Rows = append(result.Rows, map[string]interface{}{
+			"type":             "PERMISSIVE",
+			"command":          "SELECT",
+			"roles":            []interface{}{"authenticated"},
+			"definition":       bucketScopeMarker(e.bucket),
+			"check_expression": "",
+		})
+	case strings.Contains(sql, "FROM storage.buckets"):
+		result.Rows = append(result.Rows, map[string]interface{}{"id": e.bucket, "name": e.bucket, "public": false})
+	}
+	return result, nil
+}
+
+func (e *recordingExecutor) QueryReadOnly(ctx context.Context, sql string, args ...interface{}) (*database.Result, error) {
+	return e.Query(ctx, sql, args...)
+}
+
+func (e *recordingExecutor) Mode() string { return "postgres" }
+
+func (e *recordingExecutor) Close() {}
+
+// callHandler runs a handler with a JSON body and returns the status
+func callHandler(handler gin.HandlerFunc, body interface{}) (int, string) {
+	gin.SetMode(gin.TestMode)
+	data, _ := json.Marshal(body)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler(c)
+	return recorder.Code, recorder.Body.String()
+}
+
+// exposedText returns the parts of a statement outside quoted identifiers
+// and string literals. The bodies of dollar-quoted strings and the strings
+// run with EXECUTE are SQL themselves, so they are scanned in turn.
+func exposedText(t *testing.T, sql string) string {
+	var exposed strings.Builder
+	for i := 0; i < len(sql); {
+		switch {
+		case sql[i] == '"':
+			end := closingQuote(sql, i, '"', false)
+			if end < 0 {
+				t.Errorf("unterminated identifier in %q", sql)
+				return exposed.String() + sql[i:]
+			}
+			i = end
+		case sql[i] == '\'' || (sql[i] == 'E' && i+1 < len(sql) && sql[i+1] == '\''):
+			escapes := sql[i] == 'E'
+			start := i
+			if escapes {
+				start++
+			}
+			end := closingQuote(sql, start, '\'', escapes)
+			if end < 0 {
+				t.Errorf("unterminated literal in %q", sql)
+				return exposed.String() + sql[i:]
+			}
+			if strings.HasSuffix(strings.ToUpper(strings.TrimSpace(exposed.String())), "EXECUTE") {
+				exposed.WriteString(exposedText(t, unquoteLiteral(sql[start:end], escapes)))
+			}
+			i = end
+		case sql[i] == '$':
+			tagEnd := strings.IndexByte(sql[i+1:], '$')
+			if tagEnd < 0 || strings.Trim(sql[i+1:i+1+tagEnd], "abcdefghijklmnopqrstuvwxyz_") != "" {
+				exposed.WriteByte(sql[i])
+				i++
+				continue
+			}
+			tag := sql[i : i+tagEnd+2]
+			body := sql[i+len(tag):]
+			closing := strings.Index(body, tag)
+			if closing < 0 {
+				t.Errorf("unterminated dollar quote in %q", sql)
+				return exposed.String() + sql[i:]
+			}
+			exposed.WriteString(exposedText(t, body[:closing]))
+			i += len(tag) + closing + len(tag)
+		default:
+			exposed.WriteByte(sql[i])
+			i++
+		}
+	}
+	return exposed.String()
+}
+
+// closingQuote returns the index just past the quoted token at i, or -1
+func closingQuote(sql string, i int, quote byte, escapes bool) int {
+	for j := i + 1; j < len(sql); j++ {
+		switch {
+		case escapes && sql[j] == '\\':
+			j++
+		case sql[j] == quote && j+1 < len(sql) && sql[j+1] == quote:
+			j++
+		case sql[j] == quote:
+			return j + 1
+		}
+	}
+	return -1
+}
+
+func unquoteLiteral(literal string, escapes bool) string {
+	value := strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+	if escapes {
+		value = strings.ReplaceAll(value, `\\`, `\`)
+	}
+	return value
+}
+
+// checkStatements fails when the marker of a hostile name escaped its quotes
+func checkStatements(t *testing.T, name string, statements []string) {
+	t.Helper()
+	if len(statements) == 0 {
+		t.Errorf("%q: no statement was run", name)
+	}
+	for _, sql := range statements {
+		if strings.Contains(exposedText(t, sql), marker) {
+			t.Errorf("%q escaped its quotes in:\n%s", name, sql)
+		}
+	}
+}
+
+func TestHostileNames(t *testing.T) {
+	type endpoint struct {
+		name    string
+		handler func(db database.Executor) gin.HandlerFunc
+		body    func(name string) interface{}
+	}
+
+	databases := func(db database.Executor) *DatabaseController {
+		return NewDatabaseController(nil, db, config.SQLConfig{}, nil)
+	}
+	tables := func(db database.Executor) *TableController {
+		return NewTableController(nil, db, nil)
+	}
+	storage := func(db database.Executor) *StorageController {
+		return NewStorageController(nil, db, config.StorageConfig{})
+	}
+
+	endpoints := []endpoint{
+		{"create_schema", func(db database.Executor) gin.HandlerFunc { return databases(db).CreateSchema },
+			func(name string) interface{} { return gin.H{"name": name} }},
+		{"delete_schema", func(db database.Executor) gin.HandlerFunc { return databases(db).DeleteSchema },
+			func(name string) interface{} { return gin.H{"name": name, "cascade": true} }},
+		{"create_table", func(db database.Executor) gin.HandlerFunc { return tables(db).CreateTable },
+			func(name string) interface{} {
+				return gin.H{"schema": name, "name": name, "enable_rls": true, "columns": []gin.H{
+					{"name": name, "type": "text"},
+					{"name": "owner", "type": "uuid", "references": gin.H{"table": name, "column": name}},
+				}}
+			}},
+		{"alter_table", func(db database.Executor) gin.HandlerFunc { return tables(db).AlterTable },
+			func(name string) interface{} {
+				return gin.H{"schema": name, "name": name, "new_name": name, "drop_columns": []string{name},
+					"add_columns": []gin.H{{"name": name, "type": "integer", "default_value": "0"}}}
+			}},
+		{"drop_table", func(db database.Executor) gin.HandlerFunc { return tables(db).DropTable },
+			func(name string) interface{} { return gin.H{"schema": name, "name": name} }},
+		{"create_rls_policy", func(db database.Executor) gin.HandlerFunc { return databases(db).CreateRLSPolicy },
+			func(name string) interface{} {
+				return gin.H{"schema": name, "table": name, "name": name, "operation": "ALL",
+					"definition": "true", "check": "true", "roles": []string{name}}
+			}},
+		{"update_rls_policy", func(db database.Executor) gin.HandlerFunc { return databases(db).UpdateRLSPolicy },
+			func(name string) interface{} {
+				return gin.H{"schema": name, "table": name, "name": name, "new_name": name + "2", "roles": []string{name}}
+			}},
+		{"update_rls_policy recreate", func(db database.Executor) gin.HandlerFunc { return databases(db).UpdateRLSPolicy },
+			func(name string) interface{} {
+				return gin.H{"schema": name, "table": name, "name": name, "operation": "DELETE", "roles": []string{name}}
+			}},
+		{"delete_rls_policy", func(db database.Executor) gin.HandlerFunc { return databases(db).DeleteRLSPolicy },
+			func(name string) interface{} { return gin.H{"schema": name, "table": name, "name": name} }},
+		{"create_bucket_policy", func(db database.Executor) gin.HandlerFunc { return storage(db).CreateBucketPolicy },
+			func(name string) interface{} {
+				return gin.H{"bucket_id": name, "name": name, "operation": "ALL", "definition": "true", "check": "true", "roles": []string{name}}
+			}},
+		{"create_bucket_policy template", func(db database.Executor) gin.HandlerFunc { return storage(db).CreateBucketPolicy },
+			func(name string) interface{} { return gin.H{"bucket_id": name, "template": "user_folder"} }},
+		{"update_bucket_policy", func(db database.Executor) gin.HandlerFunc { return storage(db).UpdateBucketPolicy },
+			func(name string) interface{} {
+				return gin.H{"bucket_id": name, "name": name, "new_name": name + "2", "operation": "ALL", "definition": "true", "roles": []string{name}}
+			}},
+		{"delete_bucket_policy", func(db database.Executor) gin.HandlerFunc { return storage(db).DeleteBucketPolicy },
+			func(name string) interface{} { return gin.H{"bucket_id": name, "name": name} }},
+	}
+
+	for _, ep := range endpoints {
+		for _, name := range hostileNames {
+			db := &recordingExecutor{bucket: name}
+			status, body := callHandler(ep.handler(db), ep.body(name))
+			if status != http.StatusOK {
+				t.Errorf("%s with %q: status %d: %s", ep.name, name, status, body)
+				continue
+			}
+			checkStatements(t, ep.name+" "+name, db.statements)
+		}
+	}
+}
+
+func TestHostileExpressionsRejected(t *testing.T) {
+	expressions := []string{
+		"true) WITH CHECK (true); DROP TABLE pwned; --",
+		"true); DROP TABLE pwned; --",
+		"true /* pwned */",
+		"true -- pwned",
+		"'pwned",
+	}
+
+	for _, expr := range expressions {
+		db := &recordingExecutor{}
+		controller := NewDatabaseController(nil, db, config.SQLConfig{}, nil)
+		status, _ := callHandler(controller.CreateRLSPolicy, gin.H{
+			"table": "posts", "name": "p", "operation": "SELECT", "definition": expr,
+		})
+		if status != http.StatusBadRequest || len(db.statements) != 0 {
+			t.Errorf("definition %q: status %d, %d statements run; want 400 and none", expr, status, len(db.statements))
+		}
+	}
+}
+
+func TestHostileTypesAndDefaultsRejected(t *testing.T) {
+	columns := []gin.H{
+		{"name": "a", "type": "text; DROP TABLE pwned"},
+		{"name": "a", "type": `U&"\0074ext"`},
+		{"name": "a", "type": "text", "default_value": "'x'); DROP TABLE pwned; --"},
+		{"name": "a", "type": "text", "default_value": "$$pwned$$ || pg_sleep(1)"},
+		{"name": "a", "type": "text", "default_value": `U&"\0070g_sleep"(1)`},
+	}
+
+	for _, column := range columns {
+		db := &recordingExecutor{}
+		controller := NewTableController(nil, db, nil)
+		status, _ := callHandler(controller.CreateTable, gin.H{"name": "t", "columns": []gin.H{column}})
+		if status != http.StatusBadRequest || len(db.statements) != 0 {
+			t.Errorf("column %v: status %d, %d statements run; want 400 and none", column, status, len(db.statements))
+		}
+	}
+}
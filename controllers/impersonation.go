@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/auth"
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
+)
+
+// Impersonation runs a call as an end user instead of the service role, so
+// that row level security applies
+type Impersonation struct {
+	Token  string                 `json:"token" description:"Access token of the user to forward (instead of user_id and role)"`
+	UserID string                 `json:"user_id" description:"Id of the user to mint a short-lived token for (auth.uid() in policies)"`
+	Role   string                 `json:"role" jsonschema:"enum=anon|authenticated" description:"Role of the minted token (optional, defaults to authenticated with user_id and anon without)"`
+	Claims map[string]interface{} `json:"claims" description:"Extra claims of the minted token, such as email; app_metadata and mcp cannot be set (optional)"`
+}
+
+// impersonationTTL is the lifetime of minted tokens, which only need to
+// outlive a single call
+const impersonationTTL = 5 * time.Minute
+
+// errImpersonationDisabled is returned when tokens cannot be checked or
+// minted
+var errImpersonationDisabled = errors.New("impersonation requires SUPABASE_JWT_SECRET")
+
+var userIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// reservedClaims are set by the minted token itself, or grant MCP
+// permissions (app_metadata, mcp) and so could not be chosen by the caller
+var reservedClaims = map[string]bool{
+	"sub": true, "role": true, "iss": true, "aud": true, "exp": true, "iat": true, "nbf": true,
+	"app_metadata": true, "mcp": true,
+}
+
+// Impersonator checks forwarded user tokens and mints new ones with the
+// Supabase JWT secret, shaped like those issued by GoTrue. Minted tokens
+// carry auth.ImpersonationIssuer, so they never authenticate an MCP client.
+type Impersonator struct {
+	secret   []byte
+	audience string
+}
+
+// NewImpersonator creates an impersonator from the Supabase JWT secret and
+// the OAuth audience
+func NewImpersonator(cfg *config.Config) *Impersonator {
+	return &Impersonator{
+		secret:   []byte(cfg.Supabase.JWTSecret),
+		audience: cfg.Auth.OAuthAudience,
+	}
+}
+
+// userToken returns the access token and the claims to run a call with
+func (im *Impersonator) userToken(imp *Impersonation) (string, utils.Claims, error) {
+	if len(im.secret) == 0 {
+		return "", nil, errImpersonationDisabled
+	}
+
+	if imp.Token != "" {
+		if imp.UserID != "" || imp.Role != "" || len(imp.Claims) > 0 {
+			return "", nil, badRequest("impersonate.token cannot be combined with user_id, role or claims")
+		}
+		claims, err := utils.VerifyJWT(im.secret, imp.Token)
+		if err != nil {
+			return "", nil, badRequest("impersonate.token: %v", err)
+		}
+		if role := claims.String("role"); role != "anon" && role != "authenticated" {
+			return "", nil, badRequest("impersonate.token has role %q; only anon and authenticated tokens can be used", role)
+		}
+		return imp.Token, claims, nil
+	}
+
+	claims, err := im.userClaims(imp)
+	if err != nil {
+		return "", nil, badRequest("impersonate: %v", err)
+	}
+	token, err := utils.SignJWT(im.secret, claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// userClaims builds the claims of a minted token for a user id and role
+func (im *Impersonator) userClaims(imp *Impersonation) (utils.Claims, error) {
+	role := imp.Role
+	if role == "" {
+		role = "anon"
+		if imp.UserID != "" {
+			role = "authenticated"
+		}
+	}
+	switch {
+	case role == "authenticated" && imp.UserID == "":
+		return nil, fmt.Errorf("user_id is required for the authenticated role")
+	case role == "anon" && imp.UserID != "":
+		return nil, fmt.Errorf("user_id cannot be used with the anon role")
+	case imp.UserID != "" && !userIDPattern.MatchString(imp.UserID):
+		return nil, fmt.Errorf("user_id must be a UUID")
+	}
+
+	now := time.Now()
+	claims := utils.Claims{}
+	for name, value := range imp.Claims {
+		if reservedClaims[name] {
+			return nil, fmt.Errorf("claims cannot set %s", name)
+		}
+		claims[name] = value
+	}
+	claims["role"] = role
+	claims["iss"] = auth.ImpersonationIssuer
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(impersonationTTL).Unix()
+	if role == "authenticated" {
+		claims["sub"] = imp.UserID
+		claims["aud"] = im.audience
+	}
+
+	return claims, nil
+}
+
+// identity returns the database identity matching the claims
+func identity(claims utils.Claims) *database.Identity {
+	return &database.Identity{Role: claims.String("role"), Claims: claims}
+}
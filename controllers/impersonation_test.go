@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/auth"
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
+)
+
+func TestImpersonatorMintedTokens(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Supabase.JWTSecret = "super-secret-jwt-token-with-at-least-32-characters"
+	cfg.Auth.Required = true
+	cfg.Auth.OAuthIssuer = "http://supabase.test/auth/v1"
+	cfg.Auth.OAuthAudience = "authenticated"
+	cfg.Auth.KeyIssuers = []string{"supabase", auth.ImpersonationIssuer}
+	cfg.Auth.RolePermissions = `{"authenticated": {"tools": ["list_tables"]}, "admin": {"tools": null}}`
+	impersonator := NewImpersonator(cfg)
+
+	const userID = "6f1c2a1e-3b0d-4f5e-9a77-2c1d0e8b4a10"
+	for _, claims := range []map[string]interface{}{
+		{"app_metadata": map[string]interface{}{"mcp_role": "admin"}},
+		{"mcp": map[string]interface{}{"tools": []string{"execute_sql_write"}}},
+		{"iss": cfg.Auth.OAuthIssuer},
+		{"role": "service_role"},
+	} {
+		if _, _, err := impersonator.userToken(&Impersonation{UserID: userID, Claims: claims}); err == nil {
+			t.Errorf("userToken with claims %v succeeded, want an error", claims)
+		}
+	}
+
+	token, claims, err := impersonator.userToken(&Impersonation{UserID: userID, Claims: map[string]interface{}{"email": "user@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.String("iss") != auth.ImpersonationIssuer || claims.String("sub") != userID || claims.String("email") != "user@example.com" {
+		t.Errorf("minted claims = %v", claims)
+	}
+	if _, err := utils.VerifyJWT([]byte(cfg.Supabase.JWTSecret), token); err != nil {
+		t.Errorf("minted token does not verify with the JWT secret: %v", err)
+	}
+
+	// Even with its issuer listed, a minted token is no MCP credential
+	authenticator, err := auth.NewAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal, err := authenticator.Authenticate(token); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("Authenticate(minted token) = %v, %v, want ErrInvalidCredentials", principal, err)
+	}
+}
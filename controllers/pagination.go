@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+)
+
+// OrderBy represents one column of a query ordering
+type OrderBy struct {
+	Column    string `json:"column" jsonschema:"required" description:"Column to order by"`
+	Direction string `json:"direction" jsonschema:"enum=asc|desc,default=asc" description:"Sort direction (optional, defaults to asc)"`
+	Nulls     string `json:"nulls" jsonschema:"enum=first|last" description:"Place NULLs first or last (optional, defaults to last for asc and first for desc, as in Postgres)"`
+}
+
+func (o OrderBy) descending() bool {
+	return o.Direction == "desc"
+}
+
+// nullsFirst applies the Postgres default when Nulls is not set
+func (o OrderBy) nullsFirst() bool {
+	if o.Nulls == "" {
+		return o.descending()
+	}
+	return o.Nulls == "first"
+}
+
+// orderParam builds the PostgREST order parameter, e.g. a.asc.nullslast,b.desc
+func orderParam(order []OrderBy) (string, error) {
+	parts := make([]string, 0, len(order))
+	for _, o := range order {
+		if o.Column == "" {
+			return "", fmt.Errorf("order is missing a column")
+		}
+		switch o.Direction {
+		case "", "asc", "desc":
+		default:
+			return "", fmt.Errorf("invalid direction %q for column %s; must be asc or desc", o.Direction, o.Column)
+		}
+		switch o.Nulls {
+		case "", "first", "last":
+		default:
+			return "", fmt.Errorf("invalid nulls %q for column %s; must be first or last", o.Nulls, o.Column)
+		}
+
+		part := o.Column + ".asc"
+		if o.descending() {
+			part = o.Column + ".desc"
+		}
+		if o.nullsFirst() {
+			part += ".nullsfirst"
+		} else {
+			part += ".nullslast"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// pageCursor is the decoded form of next_cursor. Ordered queries continue
+// after the values of the last row (keyset pagination); unordered ones, or
+// those whose rows do not include the order columns, continue at an offset.
+type pageCursor struct {
+	Order  string        `json:"o,omitempty"`
+	After  []interface{} `json:"a,omitempty"`
+	Offset int           `json:"n,omitempty"`
+}
+
+func (pc *pageCursor) encode() string {
+	data, _ := json.Marshal(pc)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	// The values of the last row keep their exact form, as in the response
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var pc pageCursor
+	if err := decoder.Decode(&pc); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &pc, nil
+}
+
+// nextCursor returns the cursor for the page after rows, or nil when rows
+// is the last page
+func nextCursor(rows []map[string]interface{}, limit, offset int, order []OrderBy, orderKey string) *string {
+	if len(rows) == 0 || len(rows) < limit {
+		return nil
+	}
+
+	next := &pageCursor{Offset: offset + len(rows)}
+	if len(order) > 0 {
+		last := rows[len(rows)-1]
+		after := make([]interface{}, 0, len(order))
+		for _, o := range order {
+			value, ok := last[o.Column]
+			if !ok {
+				after = nil
+				break
+			}
+			after = append(after, value)
+		}
+		if after != nil {
+			next = &pageCursor{Order: orderKey, After: after}
+		}
+	}
+
+	cursor := next.encode()
+	return &cursor
+}
+
+// applyKeyset adds the filter selecting the rows that sort after the cursor
+// values. For order (a, b) this is a > x OR (a = x AND b > y), with the
+// comparisons flipped for descending columns and NULLs placed as ordered.
+func applyKeyset(query *supabase.PostgrestRequest, order []OrderBy, after []interface{}) error {
+	if len(after) != len(order) {
+		return fmt.Errorf("cursor does not match the order")
+	}
+
+	var branches []string
+	var equal []string
+	for i, o := range order {
+		value := after[i]
+
+		var greater string
+		switch {
+		case value == nil && o.nullsFirst():
+			greater = o.Column + ".not.is.null"
+		case value == nil:
+			// NULLs sort last, so nothing comes after a NULL in this column
+		case o.descending():
+			greater = o.Column + ".lt." + treeValue(value)
+		default:
+			greater = o.Column + ".gt." + treeValue(value)
+		}
+		if greater != "" && value != nil && !o.nullsFirst() {
+			greater = "or(" + greater + "," + o.Column + ".is.null)"
+		}
+
+		if greater != "" {
+			branches = append(branches, and(append(append([]string{}, equal...), greater)))
+		}
+
+		if value == nil {
+			equal = append(equal, o.Column+".is.null")
+		} else {
+			equal = append(equal, o.Column+".eq."+treeValue(value))
+		}
+	}
+
+	if len(branches) == 0 {
+		// The cursor points at the last possible row
+		query.Group("and", order[0].Column+".is.null", order[0].Column+".not.is.null")
+		return nil
+	}
+
+	query.Group("or", branches...)
+	return nil
+}
+
+func and(conditions []string) string {
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return "and(" + strings.Join(conditions, ",") + ")"
+}
+
+// parseContentRange returns the total from a Content-Range header such as
+// 0-24/3573, or nil when the total is unknown
+func parseContentRange(header string) *int64 {
+	i := strings.LastIndex(header, "/")
+	if i < 0 {
+		return nil
+	}
+	total, err := strconv.ParseInt(header[i+1:], 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &total
+}
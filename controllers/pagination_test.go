@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+)
+
+func TestQueryTableKeepsBigintIDs(t *testing.T) {
+	// 2^53 + 1, which float64 rounds to 9007199254740992
+	const id = "9007199254740993"
+
+	var filters []string
+	postgrest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters = append(filters, r.URL.Query().Get("id")+" "+r.URL.Query().Get("or"))
+		w.Header().Set("Content-Range", "0-0/2")
+		w.Write([]byte(`[{"id": ` + id + `, "title": "first"}]`))
+	}))
+	defer postgrest.Close()
+
+	controller := NewTableController(supabase.CreateClientExtended(postgrest.URL, "service-key"), nil, nil)
+
+	status, body := callHandler(controller.QueryTable, json.RawMessage(`{
+		"table": "posts",
+		"where": [{"column": "id", "operator": "gte", "value": `+id+`}],
+		"order": [{"column": "id"}],
+		"limit": 1
+	}`))
+	if status != http.StatusOK {
+		t.Fatalf("query_table: status %d, %s", status, body)
+	}
+	if !strings.Contains(body, `"id":`+id) {
+		t.Errorf("query_table rows lost the exact id: %s", body)
+	}
+	if filters[0] != "gte."+id+" " {
+		t.Errorf("where filter = %q, want gte.%s", filters[0], id)
+	}
+
+	var page struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := json.Unmarshal([]byte(body), &page); err != nil || page.NextCursor == "" {
+		t.Fatalf("query_table returned no next_cursor: %s", body)
+	}
+
+	status, body = callHandler(controller.QueryTable, map[string]interface{}{
+		"table":  "posts",
+		"order":  []map[string]string{{"column": "id"}},
+		"limit":  1,
+		"cursor": page.NextCursor,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("query_table with the cursor: status %d, %s", status, body)
+	}
+	if want := " (or(id.gt." + id + ",id.is.null))"; filters[1] != want {
+		t.Errorf("keyset filter = %q, want %q", filters[1], want)
+	}
+}
@@ -0,0 +1,440 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/auth"
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/gin-gonic/gin"
+)
+
+// RLSPersona represents an end user to test a policy as
+type RLSPersona struct {
+	Name   string                 `json:"name" description:"Label of the persona in the report (optional, defaults to the user id or role)"`
+	Role   string                 `json:"role" jsonschema:"enum=anon|authenticated" description:"Database role (optional, defaults to authenticated with user_id and anon without)"`
+	UserID string                 `json:"user_id" description:"User id returned by auth.uid() (required for authenticated)"`
+	Claims map[string]interface{} `json:"claims" description:"Extra JWT claims read by auth.jwt(), such as email; app_metadata and mcp cannot be set (optional)"`
+}
+
+// TestRLSPolicyRequest represents the request body for testing an RLS policy
+type TestRLSPolicyRequest struct {
+	Schema     string                   `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table      string                   `json:"table" jsonschema:"required" description:"Table name"`
+	Name       string                   `json:"name" jsonschema:"required" description:"Policy name; an existing policy with this name is replaced during the test"`
+	Operation  string                   `json:"operation" jsonschema:"required,enum=SELECT|INSERT|UPDATE|DELETE|ALL" description:"Operation type that the policy applies to"`
+	Definition string                   `json:"definition" jsonschema:"required" description:"Policy definition (using expression syntax)"`
+	Check      string                   `json:"check" description:"Optional check expression for INSERT/UPDATE operations"`
+	Role       string                   `json:"role" description:"Optional role name (defaults to public when no roles are given)"`
+	Roles      []string                 `json:"roles" description:"Role names the policy applies to, in addition to role (optional)"`
+	Type       string                   `json:"type" jsonschema:"enum=PERMISSIVE|RESTRICTIVE,default=PERMISSIVE" description:"Whether the policy grants access (PERMISSIVE) or further restricts it (RESTRICTIVE) (optional, defaults to PERMISSIVE)"`
+	Personas   []RLSPersona             `json:"personas" jsonschema:"required,minItems=1" description:"Users to test the policy as"`
+	Rows       []map[string]interface{} `json:"rows" description:"Sample rows added to the table before the personas run, bypassing RLS (optional)"`
+	Insert     []map[string]interface{} `json:"insert" description:"Rows each persona tries to insert (optional)"`
+	Filter     string                   `json:"filter" description:"SQL expression limiting the rows probed, e.g. id > 100; subqueries are not allowed (optional, defaults to every row)"`
+	Probes     []string                 `json:"probes" description:"SELECT queries each persona also runs, e.g. SELECT * FROM public.posts JOIN public.authors ON ... (optional)"`
+	Limit      int                      `json:"limit" jsonschema:"default=20,minimum=1,maximum=100" description:"Maximum number of rows listed per probe (optional, defaults to 20)"`
+}
+
+// RLSProbe reports the rows a persona could read or change
+type RLSProbe struct {
+	Query string                   `json:"query,omitempty"`
+	Count int64                    `json:"count"`
+	Rows  []map[string]interface{} `json:"rows"`
+	Error string                   `json:"error,omitempty"`
+	Code  string                   `json:"code,omitempty"`
+}
+
+// RLSInsertProbe reports whether a persona could insert a row
+type RLSInsertProbe struct {
+	Row     int    `json:"row"`
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+}
+
+// RLSPersonaReport reports what a persona can do with the policy in place
+type RLSPersonaReport struct {
+	Name   string           `json:"name"`
+	Role   string           `json:"role"`
+	UserID string           `json:"user_id,omitempty"`
+	Select RLSProbe         `json:"select"`
+	Insert []RLSInsertProbe `json:"insert"`
+	Update RLSProbe         `json:"update"`
+	Delete RLSProbe         `json:"delete"`
+	Probes []RLSProbe       `json:"probes"`
+}
+
+// probeSQL counts the rows returned by a statement and lists the first ones
+const probeSQL = `WITH affected AS (%s)
+SELECT (SELECT count(*) FROM affected) AS count,
+	COALESCE((SELECT json_agg(r) FROM (SELECT * FROM affected LIMIT %d) r), '[]'::json) AS rows`
+
+// TestRLSPolicy creates a policy in a transaction, probes the table as each
+// persona and rolls everything back. Updates and deletes return the rows
+// they touch, so like PostgREST requests with return=representation the
+// rows must also be visible to SELECT.
+func (dc *DatabaseController) TestRLSPolicy(c *gin.Context) {
+	var req TestRLSPolicyRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sandboxer, ok := dc.db.(database.Sandboxer)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Testing policies requires a direct database connection. Set PG_CONNECTION_STRING."})
+		return
+	}
+
+	if req.Table == "" || req.Name == "" || req.Operation == "" || req.Definition == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
+		return
+	}
+	if len(req.Personas) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one persona is required"})
+		return
+	}
+
+	schema := req.Schema
+	if schema == "" {
+		schema = "public"
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	policy, err := newPolicySpec(req.Name, req.Operation, req.Type, req.Role, req.Roles, req.Definition, req.Check)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := "true"
+	if req.Filter != "" {
+		if err := database.ValidateFilter(req.Filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("filter: %v", err)})
+			return
+		}
+		filter = "(" + req.Filter + ")"
+	}
+	for i, probe := range req.Probes {
+		if err := validateProbe(probe); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("probes[%d]: %v", i, err)})
+			return
+		}
+	}
+
+	identities := make([]*database.Identity, len(req.Personas))
+	for i, persona := range req.Personas {
+		claims, err := dc.impersonator.userClaims(&Impersonation{UserID: persona.UserID, Role: persona.Role, Claims: persona.Claims})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("personas[%d]: %v", i, err)})
+			return
+		}
+		identities[i] = identity(claims)
+	}
+
+	table := database.QualifiedName(schema, req.Table)
+	policySQL := createPolicySQL(table, policy)
+
+	var (
+		stage    string
+		replaced bool
+		total    int64
+		reports  = make([]RLSPersonaReport, len(req.Personas))
+		warnings = []string{}
+	)
+
+	ctx := c.Request.Context()
+	err = sandboxer.Sandbox(ctx, func(sandbox *database.Sandbox) error {
+		stage = "table"
+		result, err := sandbox.Exec(ctx, `
+			SELECT
+				c.relrowsecurity AS rls_enabled,
+				EXISTS (SELECT 1 FROM pg_policy p WHERE p.polrelid = c.oid AND p.polname = $2) AS policy_exists,
+				(
+					SELECT a.attname
+					FROM pg_attribute a
+					WHERE a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+						AND a.attgenerated = '' AND a.attidentity <> 'a'
+					ORDER BY a.attnum
+					LIMIT 1
+				) AS update_column
+			FROM pg_class c
+			WHERE c.oid = to_regclass($1)
+		`, table, req.Name)
+		if err != nil {
+			return err
+		}
+		var info []struct {
+			RLSEnabled   bool    `json:"rls_enabled"`
+			PolicyExists bool    `json:"policy_exists"`
+			UpdateColumn *string `json:"update_column"`
+		}
+		if err := result.Decode(&info); err != nil {
+			return err
+		}
+		if len(info) == 0 {
+			return errTableNotFound
+		}
+
+		if !info[0].RLSEnabled {
+			if _, err := sandbox.Exec(ctx, "ALTER TABLE "+table+" ENABLE ROW LEVEL SECURITY"); err != nil {
+				return err
+			}
+			warnings = append(warnings, fmt.Sprintf("Row level security is not enabled on %s; it was enabled for this test only, and the policy has no effect until it is enabled", table))
+		}
+
+		stage = "policy"
+		if info[0].PolicyExists {
+			if _, err := sandbox.Exec(ctx, fmt.Sprintf("DROP POLICY %s ON %s", database.QuoteIdent(req.Name), table)); err != nil {
+				return err
+			}
+			replaced = true
+		}
+		if _, err := sandbox.Exec(ctx, policySQL); err != nil {
+			return err
+		}
+
+		// The caller's schema restrictions also cover what the expressions
+		// and probes refer to
+		stage = "references"
+		queries := []string{fmt.Sprintf("SELECT 1 FROM %s WHERE %s AND (%s)", table, filter, policy.Definition)}
+		if policy.Check != "" {
+			queries = append(queries, fmt.Sprintf("SELECT 1 FROM %s WHERE (%s)", table, policy.Check))
+		}
+		queries = append(queries, req.Probes...)
+		for _, query := range queries {
+			if err := checkReferences(ctx, sandbox, query); err != nil {
+				return err
+			}
+		}
+
+		for i, row := range req.Rows {
+			stage = fmt.Sprintf("rows[%d]", i)
+			sql, args, err := insertRowSQL(table, row)
+			if err != nil {
+				return err
+			}
+			if _, err := sandbox.Exec(ctx, sql, args...); err != nil {
+				return err
+			}
+		}
+
+		// The filter is the caller's own SQL, so the rows are counted as
+		// service_role, which bypasses RLS but is not a superuser
+		stage = "filter"
+		if err := sandbox.SetIdentity(ctx, &database.Identity{Role: "service_role", Claims: map[string]interface{}{"role": "service_role"}}); err != nil {
+			return err
+		}
+		result, err = sandbox.Exec(ctx, fmt.Sprintf("SELECT count(*) AS count FROM %s WHERE %s", table, filter))
+		if err != nil {
+			return err
+		}
+		if err := sandbox.SetIdentity(ctx, nil); err != nil {
+			return err
+		}
+		var counts []struct {
+			Count int64 `json:"count"`
+		}
+		if err := result.Decode(&counts); err != nil {
+			return err
+		}
+		if len(counts) != 1 {
+			return fmt.Errorf("filter did not return a row count")
+		}
+		total = counts[0].Count
+
+		stage = "personas"
+		for i, persona := range req.Personas {
+			if err := sandbox.SetIdentity(ctx, identities[i]); err != nil {
+				return err
+			}
+
+			report := RLSPersonaReport{
+				Name:   persona.Name,
+				Role:   identities[i].Role,
+				UserID: persona.UserID,
+				Insert: []RLSInsertProbe{},
+				Probes: []RLSProbe{},
+			}
+			if report.Name == "" {
+				report.Name = report.Role
+				if persona.UserID != "" {
+					report.Name = persona.UserID
+				}
+			}
+
+			report.Select = probeRows(ctx, sandbox, fmt.Sprintf("SELECT * FROM %s WHERE %s", table, filter), req.Limit)
+			for j, row := range req.Insert {
+				report.Insert = append(report.Insert, probeInsert(ctx, sandbox, table, j, row))
+			}
+			if info[0].UpdateColumn != nil {
+				column := database.QuoteIdent(*info[0].UpdateColumn)
+				report.Update = probeRows(ctx, sandbox, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s RETURNING *", table, column, column, filter), req.Limit)
+			} else {
+				report.Update = RLSProbe{Rows: []map[string]interface{}{}, Error: "table has no column that can be updated"}
+			}
+			report.Delete = probeRows(ctx, sandbox, fmt.Sprintf("DELETE FROM %s WHERE %s RETURNING *", table, filter), req.Limit)
+			for _, query := range req.Probes {
+				probe := probeRows(ctx, sandbox, query, req.Limit)
+				probe.Query = query
+				report.Probes = append(report.Probes, probe)
+			}
+
+			reports[i] = report
+			if err := sandbox.SetIdentity(ctx, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errTableNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Table %s not found", table)})
+		return
+	}
+	if errors.Is(err, errSchemaNotAllowed) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "stage": stage})
+		return
+	}
+	if err != nil {
+		body := sqlError(err)
+		body["stage"] = stage
+		c.JSON(errorStatus(err), body)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"table":       table,
+		"policy":      policySQL,
+		"replaced":    replaced,
+		"total_rows":  total,
+		"personas":    reports,
+		"warnings":    warnings,
+		"rolled_back": true,
+	})
+}
+
+var (
+	// errTableNotFound is returned when the table to test does not exist
+	errTableNotFound = errors.New("table not found")
+	// errSchemaNotAllowed is returned when an expression or probe refers to
+	// a schema the caller may not use
+	errSchemaNotAllowed = errors.New("not allowed")
+)
+
+// helperFunctions are the functions policies commonly call, which may be
+// used whatever schemas the caller is limited to
+var helperFunctions = map[string]bool{
+	"auth.uid": true, "auth.jwt": true, "auth.role": true, "auth.email": true,
+	"storage.foldername": true, "storage.filename": true, "storage.extension": true,
+}
+
+// validateProbe checks that a probe is a single read-only query
+func validateProbe(query string) error {
+	if err := database.CheckReadOnly(query); err != nil {
+		return err
+	}
+	if err := database.ValidateExpression(query); err != nil {
+		return fmt.Errorf("probes must be a single query without comments")
+	}
+	return nil
+}
+
+// checkReferences applies the schema restrictions of the caller to the
+// relations and functions a query refers to, as resolved by Postgres.
+// Built-in functions and the helpers in helperFunctions are always allowed.
+func checkReferences(ctx context.Context, sandbox *database.Sandbox, query string) error {
+	principal := auth.FromContext(ctx)
+	if principal == nil || principal.Schemas == nil {
+		return nil
+	}
+
+	references, err := sandbox.References(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, ref := range references {
+		if principal.AllowSchema(ref.Schema) || ref.Kind == "function" && helperFunctions[ref.Schema+"."+ref.Name] {
+			continue
+		}
+		return fmt.Errorf("%w to use %s %s.%s: access is limited to schemas %s", errSchemaNotAllowed, ref.Kind, ref.Schema, ref.Name, strings.Join(principal.Schemas, ", "))
+	}
+	return nil
+}
+
+// insertRowSQL builds an INSERT of one row given as a JSON object. Columns
+// the row leaves out get their defaults.
+func insertRowSQL(table string, row map[string]interface{}) (string, []interface{}, error) {
+	if len(row) == 0 {
+		return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", table), nil, nil
+	}
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for i, column := range columns {
+		columns[i] = database.QuoteIdent(column)
+	}
+	list := strings.Join(columns, ", ")
+
+	sql := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM json_populate_record(NULL::%s, $1::json)", table, list, list, table)
+	return sql, []interface{}{string(data)}, nil
+}
+
+// probeRows runs a statement as the current persona, undoes it and reports
+// the rows it returned
+func probeRows(ctx context.Context, sandbox *database.Sandbox, sql string, limit int) RLSProbe {
+	probe := RLSProbe{Rows: []map[string]interface{}{}}
+
+	result, err := sandbox.Try(ctx, fmt.Sprintf(probeSQL, sql, limit))
+	if err == nil {
+		var rows []RLSProbe
+		if err = result.Decode(&rows); err == nil && len(rows) == 1 {
+			probe.Count = rows[0].Count
+			probe.Rows = rows[0].Rows
+		}
+	}
+	if err != nil {
+		probe.Error, probe.Code = probeError(err)
+	}
+	return probe
+}
+
+// probeInsert tries to insert a row as the current persona and undoes it
+func probeInsert(ctx context.Context, sandbox *database.Sandbox, table string, index int, row map[string]interface{}) RLSInsertProbe {
+	probe := RLSInsertProbe{Row: index}
+
+	sql, args, err := insertRowSQL(table, row)
+	if err == nil {
+		_, err = sandbox.Try(ctx, sql, args...)
+	}
+	if err != nil {
+		probe.Error, probe.Code = probeError(err)
+		return probe
+	}
+	probe.Allowed = true
+	return probe
+}
+
+// probeError describes a failed probe with its SQLSTATE, if any
+func probeError(err error) (string, string) {
+	var dbErr *database.Error
+	if errors.As(err, &dbErr) {
+		return dbErr.Message, dbErr.Code
+	}
+	return err.Error(), ""
+}
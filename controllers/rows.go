@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+	"github.com/gin-gonic/gin"
+)
+
+// InsertRowsRequest represents the request body for inserting rows
+type InsertRowsRequest struct {
+	Schema string                   `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table  string                   `json:"table" jsonschema:"required" description:"Name of the table"`
+	Rows   []map[string]interface{} `json:"rows" jsonschema:"required,minItems=1" description:"Rows to insert, as objects keyed by column name"`
+	Select string                   `json:"select" jsonschema:"default=*" description:"Columns to return for the inserted rows (optional, defaults to *)"`
+}
+
+// UpsertRowsRequest represents the request body for upserting rows
+type UpsertRowsRequest struct {
+	Schema           string                   `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table            string                   `json:"table" jsonschema:"required" description:"Name of the table"`
+	Rows             []map[string]interface{} `json:"rows" jsonschema:"required,minItems=1" description:"Rows to insert or update, as objects keyed by column name"`
+	OnConflict       []string                 `json:"on_conflict" description:"Columns of the unique constraint that detects conflicts (optional, defaults to the primary key)"`
+	IgnoreDuplicates bool                     `json:"ignore_duplicates" description:"Keep existing rows instead of updating them on conflict (optional, defaults to false)"`
+	Select           string                   `json:"select" jsonschema:"default=*" description:"Columns to return for the affected rows (optional, defaults to *)"`
+}
+
+// UpdateRowsRequest represents the request body for updating rows
+type UpdateRowsRequest struct {
+	Schema string                 `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table  string                 `json:"table" jsonschema:"required" description:"Name of the table"`
+	Values map[string]interface{} `json:"values" jsonschema:"required" description:"New column values"`
+	Where  []WhereCondition       `json:"where" description:"Conditions selecting the rows to update"`
+	Force  bool                   `json:"force" description:"Allow updating every row when no where condition is given (optional, defaults to false)"`
+	Select string                 `json:"select" jsonschema:"default=*" description:"Columns to return for the updated rows (optional, defaults to *)"`
+}
+
+// DeleteRowsRequest represents the request body for deleting rows
+type DeleteRowsRequest struct {
+	Schema string           `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table  string           `json:"table" jsonschema:"required" description:"Name of the table"`
+	Where  []WhereCondition `json:"where" description:"Conditions selecting the rows to delete"`
+	Force  bool             `json:"force" description:"Allow deleting every row when no where condition is given (optional, defaults to false)"`
+	Select string           `json:"select" jsonschema:"default=*" description:"Columns to return for the deleted rows (optional, defaults to *)"`
+}
+
+// InsertRows inserts rows into a table
+func (tc *TableController) InsertRows(c *gin.Context) {
+	var req InsertRowsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Table == "" || len(req.Rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Table name and at least one row are required"})
+		return
+	}
+
+	query := tc.rowsRequest(req.Schema, req.Table, req.Select)
+	withColumns(query, req.Rows)
+	tc.executeRows(c, query.Insert(req.Rows))
+}
+
+// UpsertRows inserts rows, updating the existing ones that conflict
+func (tc *TableController) UpsertRows(c *gin.Context) {
+	var req UpsertRowsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Table == "" || len(req.Rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Table name and at least one row are required"})
+		return
+	}
+
+	query := tc.rowsRequest(req.Schema, req.Table, req.Select)
+	withColumns(query, req.Rows)
+	tc.executeRows(c, query.Upsert(req.Rows, req.OnConflict, req.IgnoreDuplicates))
+}
+
+// UpdateRows updates the rows matching the where conditions
+func (tc *TableController) UpdateRows(c *gin.Context) {
+	var req UpdateRowsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Table == "" || len(req.Values) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Table name and values are required"})
+		return
+	}
+
+	if len(req.Where) == 0 && !req.Force {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refusing to update every row without a where condition. Set force to true to update the whole table."})
+		return
+	}
+
+	query := tc.rowsRequest(req.Schema, req.Table, req.Select)
+	if err := applyWhere(query, "", req.Where); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tc.executeRows(c, query.Update(req.Values))
+}
+
+// DeleteRows deletes the rows matching the where conditions
+func (tc *TableController) DeleteRows(c *gin.Context) {
+	var req DeleteRowsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Table == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Table name is required"})
+		return
+	}
+
+	if len(req.Where) == 0 && !req.Force {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refusing to delete every row without a where condition. Set force to true to empty the whole table."})
+		return
+	}
+
+	query := tc.rowsRequest(req.Schema, req.Table, req.Select)
+	if err := applyWhere(query, "", req.Where); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tc.executeRows(c, query.Delete())
+}
+
+// rowsRequest starts a PostgREST request that returns the affected rows
+func (tc *TableController) rowsRequest(schema, table, columns string) *supabase.PostgrestRequest {
+	if schema == "" {
+		schema = "public"
+	}
+	if columns == "" {
+		columns = "*"
+	}
+
+	return tc.supabase.Rest(table).
+		Schema(schema).
+		Select(columns).
+		Prefer("return=representation")
+}
+
+// executeRows runs a write request and responds with the affected rows
+func (tc *TableController) executeRows(c *gin.Context, query *supabase.PostgrestRequest) {
+	rows := []map[string]interface{}{}
+	if _, err := query.Execute(c.Request.Context(), &rows); err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"affected_rows": len(rows),
+		"rows":          rows,
+	})
+}
+
+// withColumns lists the union of the row keys when the rows do not all set
+// the same columns, so PostgREST fills the missing ones with their defaults
+// instead of NULL
+func withColumns(query *supabase.PostgrestRequest, rows []map[string]interface{}) {
+	columns := map[string]bool{}
+	uniform := true
+	for i, row := range rows {
+		if i > 0 && len(row) != len(columns) {
+			uniform = false
+		}
+		for column := range row {
+			if i > 0 && !columns[column] {
+				uniform = false
+			}
+			columns[column] = true
+		}
+	}
+	if uniform {
+		return
+	}
+
+	names := make([]string, 0, len(columns))
+	for column := range columns {
+		names = append(names, column)
+	}
+	sort.Strings(names)
+
+	query.Param("columns", strings.Join(names, ","))
+	query.Prefer("missing=default")
+}
@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RPCArgument represents an argument of a database function
+type RPCArgument struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Mode       string `json:"mode"`
+	HasDefault bool   `json:"has_default"`
+}
+
+// RPCFunction represents a database function callable through PostgREST
+type RPCFunction struct {
+	Name            string        `json:"name"`
+	Arguments       []RPCArgument `json:"arguments"`
+	ReturnType      string        `json:"return_type"`
+	ReturnsSet      bool          `json:"returns_set"`
+	Volatility      string        `json:"volatility"`
+	SecurityDefiner bool          `json:"security_definer"`
+	Description     *string       `json:"description"`
+}
+
+// ListRPCFunctionsRequest represents the request body for listing functions
+type ListRPCFunctionsRequest struct {
+	Schema   string `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Function string `json:"function" description:"Only list the overloads of this function (optional)"`
+}
+
+// CallRPCRequest represents the request body for calling a function
+type CallRPCRequest struct {
+	Schema      string                 `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Function    string                 `json:"function" jsonschema:"required" description:"Name of the function to call"`
+	Args        map[string]interface{} `json:"args" description:"Arguments by name (optional)"`
+	Impersonate *Impersonation         `json:"impersonate" description:"Call the function as an end user, with row level security (optional, defaults to the service role)"`
+}
+
+// reservedFunctions cannot be called with call_rpc because they would
+// bypass the checks of the SQL tools
+var reservedFunctions = map[string]string{
+	"execute_sql": "use execute_query or execute_sql_write instead",
+}
+
+// ListRPCFunctions lists the functions of a schema with their signatures
+func (dc *DatabaseController) ListRPCFunctions(c *gin.Context) {
+	var req ListRPCFunctionsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Schema == "" {
+		req.Schema = "public"
+	}
+
+	functions, err := dc.loadFunctions(c.Request.Context(), req.Schema, req.Function)
+	if err != nil {
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, functions)
+}
+
+// CallRPC validates the arguments against the function signature and
+// calls it through PostgREST: immutable and stable functions with GET,
+// the others with POST
+func (dc *DatabaseController) CallRPC(c *gin.Context) {
+	var req CallRPCRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Function == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Function name is required"})
+		return
+	}
+	if req.Schema == "" {
+		req.Schema = "public"
+	}
+	if reason, reserved := reservedFunctions[req.Function]; reserved {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Function %s cannot be called with call_rpc; %s", req.Function, reason)})
+		return
+	}
+
+	functions, err := dc.loadFunctions(c.Request.Context(), req.Schema, req.Function)
+	if err != nil {
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
+	if len(functions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Function %s.%s not found", req.Schema, req.Function)})
+		return
+	}
+
+	function, err := matchFunction(functions, req.Args)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// GET cannot express NULL arguments, so those calls use POST as well
+	query := dc.supabase.RPC(req.Function).Schema(req.Schema)
+	if req.Impersonate != nil {
+		token, _, err := dc.impersonator.userToken(req.Impersonate)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		query.AsUser(token)
+	}
+	method := http.MethodPost
+	if function.Volatility == "volatile" || hasNullArg(req.Args) {
+		args := req.Args
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		query.Call(args)
+	} else {
+		method = http.MethodGet
+		for name, value := range req.Args {
+			query.Param(name, rpcParam(value))
+		}
+	}
+
+	var result interface{}
+	if _, err := query.Execute(c.Request.Context(), &result); err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"function": req.Function,
+		"method":   method,
+		"result":   result,
+	})
+}
+
+func hasNullArg(args map[string]interface{}) bool {
+	for _, value := range args {
+		if value == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFunctions reads the signatures of the functions of a schema from
+// pg_proc, optionally only those with the given name
+func (dc *DatabaseController) loadFunctions(ctx context.Context, schema, name string) ([]RPCFunction, error) {
+	query := `
+		SELECT
+			p.proname AS name,
+			COALESCE((
+				SELECT json_agg(json_build_object(
+					'name', COALESCE(p.proargnames[a.n], ''),
+					'type', format_type(a.type, NULL),
+					'mode', CASE COALESCE(p.proargmodes[a.n], 'i')
+						WHEN 'i' THEN 'in' WHEN 'o' THEN 'out' WHEN 'b' THEN 'inout'
+						WHEN 'v' THEN 'variadic' ELSE 'table' END
+				) ORDER BY a.n)
+				FROM unnest(COALESCE(p.proallargtypes, p.proargtypes::oid[])) WITH ORDINALITY AS a(type, n)
+			), '[]'::json) AS arguments,
+			pg_get_function_result(p.oid) AS return_type,
+			p.proretset AS returns_set,
+			CASE p.provolatile WHEN 'i' THEN 'immutable' WHEN 's' THEN 'stable' ELSE 'volatile' END AS volatility,
+			p.prosecdef AS security_definer,
+			obj_description(p.oid, 'pg_proc') AS description,
+			p.pronargdefaults AS default_count
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = $1
+			AND p.prokind = 'f'
+			AND p.prorettype NOT IN ('trigger'::regtype, 'event_trigger'::regtype)
+			AND ($2 = '' OR p.proname = $2)
+		ORDER BY p.proname, p.oid
+	`
+
+	result, err := dc.db.Query(ctx, query, schema, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		RPCFunction
+		DefaultCount int `json:"default_count"`
+	}
+	if err := result.Decode(&rows); err != nil {
+		return nil, err
+	}
+
+	functions := make([]RPCFunction, len(rows))
+	for i, row := range rows {
+		function := row.RPCFunction
+
+		// The last pronargdefaults input arguments have defaults
+		remaining := row.DefaultCount
+		for j := len(function.Arguments) - 1; j >= 0 && remaining > 0; j-- {
+			if function.Arguments[j].isInput() {
+				function.Arguments[j].HasDefault = true
+				remaining--
+			}
+		}
+		functions[i] = function
+	}
+	return functions, nil
+}
+
+func (a RPCArgument) isInput() bool {
+	return a.Mode == "in" || a.Mode == "inout" || a.Mode == "variadic"
+}
+
+// signature formats a function as name(arg type, ...)
+func (f RPCFunction) signature() string {
+	var args []string
+	for _, arg := range f.Arguments {
+		if !arg.isInput() {
+			continue
+		}
+		s := strings.TrimSpace(arg.Name + " " + arg.Type)
+		if arg.HasDefault {
+			s += " DEFAULT"
+		}
+		args = append(args, s)
+	}
+	return f.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// checkArgs validates named arguments against the function's input
+// arguments
+func (f RPCFunction) checkArgs(args map[string]interface{}) error {
+	inputs := map[string]RPCArgument{}
+	for _, arg := range f.Arguments {
+		if !arg.isInput() {
+			continue
+		}
+		if arg.Name == "" {
+			return fmt.Errorf("%s has unnamed arguments and cannot be called through PostgREST", f.signature())
+		}
+		inputs[arg.Name] = arg
+	}
+
+	var unknown []string
+	for name, value := range args {
+		arg, ok := inputs[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if err := checkArgType(arg, value); err != nil {
+			return err
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown arguments %s for %s", strings.Join(unknown, ", "), f.signature())
+	}
+
+	for _, arg := range f.Arguments {
+		if !arg.isInput() || arg.HasDefault {
+			continue
+		}
+		if _, ok := args[arg.Name]; !ok {
+			return fmt.Errorf("missing argument %s for %s", arg.Name, f.signature())
+		}
+	}
+	return nil
+}
+
+// matchFunction picks the overload that accepts the arguments
+func matchFunction(functions []RPCFunction, args map[string]interface{}) (*RPCFunction, error) {
+	var matches []RPCFunction
+	var lastErr error
+	for _, function := range functions {
+		if err := function.checkArgs(args); err != nil {
+			lastErr = err
+			continue
+		}
+		matches = append(matches, function)
+	}
+
+	switch {
+	case len(matches) == 1:
+		return &matches[0], nil
+	case len(matches) == 0 && len(functions) == 1:
+		return nil, lastErr
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no overload accepts these arguments; available: %s", signatures(functions))
+	default:
+		return nil, fmt.Errorf("several overloads accept these arguments: %s", signatures(matches))
+	}
+}
+
+func signatures(functions []RPCFunction) string {
+	list := make([]string, len(functions))
+	for i, function := range functions {
+		list[i] = function.signature()
+	}
+	return strings.Join(list, "; ")
+}
+
+// checkArgType checks that a JSON value fits the argument's type
+func checkArgType(arg RPCArgument, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	typ := arg.Type
+	if strings.HasSuffix(typ, "[]") || arg.Mode == "variadic" {
+		if _, ok := asList(value); !ok {
+			return fmt.Errorf("argument %s must be an array (%s)", arg.Name, typ)
+		}
+		return nil
+	}
+
+	switch {
+	case typ == "json" || typ == "jsonb":
+		return nil
+	case typ == "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("argument %s must be a boolean", arg.Name)
+		}
+	case typ == "smallint" || typ == "integer" || typ == "bigint":
+		if !isNumber(value, true) {
+			return fmt.Errorf("argument %s must be an integer (%s)", arg.Name, typ)
+		}
+	case typ == "real" || typ == "double precision" || strings.HasPrefix(typ, "numeric"):
+		if !isNumber(value, false) {
+			return fmt.Errorf("argument %s must be a number (%s)", arg.Name, typ)
+		}
+	default:
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return fmt.Errorf("argument %s must be a scalar value (%s)", arg.Name, typ)
+		}
+	}
+	return nil
+}
+
+// isNumber accepts JSON numbers and numeric strings
+func isNumber(value interface{}, integer bool) bool {
+	var s string
+	switch v := value.(type) {
+	case float64:
+		return !integer || v == float64(int64(v))
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return false
+	}
+
+	if integer {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// rpcParam encodes an argument for the query string of a GET call
+func rpcParam(value interface{}) string {
+	if values, ok := value.([]interface{}); ok {
+		if literal, err := arrayLiteral(values); err == nil {
+			return literal
+		}
+	}
+	return filterValue(value)
+}
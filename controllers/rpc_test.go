@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// rpcArgs decodes arguments the way bindJSON does
+func rpcArgs(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var args map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&args); err != nil {
+		t.Fatalf("%s: %v", raw, err)
+	}
+	return args
+}
+
+func TestMatchFunction(t *testing.T) {
+	in := func(name, typ string) RPCArgument { return RPCArgument{Name: name, Type: typ, Mode: "in"} }
+	withDefault := func(arg RPCArgument) RPCArgument { arg.HasDefault = true; return arg }
+
+	// search(query text, max integer DEFAULT) and search(query text, tags text[])
+	search := []RPCFunction{
+		{Name: "search", ReturnType: "1", Arguments: []RPCArgument{in("query", "text"), withDefault(in("max", "integer"))}},
+		{Name: "search", ReturnType: "2", Arguments: []RPCArgument{in("query", "text"), in("tags", "text[]")}},
+	}
+	// total(VARIADIC nums integer[]) and stats(id bigint, OUT n integer)
+	total := []RPCFunction{{Name: "total", Arguments: []RPCArgument{{Name: "nums", Type: "integer[]", Mode: "variadic"}}}}
+	stats := []RPCFunction{{Name: "stats", Arguments: []RPCArgument{in("id", "bigint"), {Name: "n", Type: "integer", Mode: "out"}}}}
+	settings := []RPCFunction{{Name: "settings", Arguments: []RPCArgument{in("flag", "boolean"), withDefault(in("data", "jsonb")), withDefault(in("ratio", "numeric(5,2)"))}}}
+	unnamed := []RPCFunction{{Name: "legacy", Arguments: []RPCArgument{in("", "text")}}}
+	// ambiguous(a text, b text DEFAULT) and ambiguous(a text, c text DEFAULT)
+	ambiguous := []RPCFunction{
+		{Name: "ambiguous", Arguments: []RPCArgument{in("a", "text"), withDefault(in("b", "text"))}},
+		{Name: "ambiguous", Arguments: []RPCArgument{in("a", "text"), withDefault(in("c", "text"))}},
+	}
+
+	tests := []struct {
+		name      string
+		functions []RPCFunction
+		args      string
+		want      string
+		err       string
+	}{
+		{"default omitted", search, `{"query": "cats"}`, "1", ""},
+		{"default given", search, `{"query": "cats", "max": 10}`, "1", ""},
+		{"integer as string", search, `{"query": "cats", "max": "10"}`, "1", ""},
+		{"array overload", search, `{"query": "cats", "tags": ["a"]}`, "2", ""},
+		{"null argument", search, `{"query": null}`, "1", ""},
+		{"missing argument", search, `{}`, "", "no overload accepts these arguments; available: search(query text, max integer DEFAULT); search(query text, tags text[])"},
+		{"wrong type", search, `{"query": "cats", "max": 1.5}`, "", "no overload accepts"},
+		{"unknown argument", search, `{"query": "cats", "limit": 1}`, "", "no overload accepts"},
+		{"variadic array", total, `{"nums": [1, 2, 3]}`, "", ""},
+		{"variadic scalar", total, `{"nums": 1}`, "", "argument nums must be an array (integer[])"},
+		{"out arguments are not inputs", stats, `{"id": 9007199254740993}`, "", ""},
+		{"out argument given", stats, `{"id": 1, "n": 2}`, "", "unknown arguments n for stats(id bigint)"},
+		{"bigint must be an integer", stats, `{"id": "x"}`, "", "argument id must be an integer (bigint)"},
+		{"single function reports its error", settings, `{}`, "", "missing argument flag for settings(flag boolean, data jsonb DEFAULT, ratio numeric(5,2) DEFAULT)"},
+		{"boolean", settings, `{"flag": "yes"}`, "", "argument flag must be a boolean"},
+		{"json accepts anything", settings, `{"flag": true, "data": [1, {"a": 2}]}`, "", ""},
+		{"numeric", settings, `{"flag": true, "ratio": 1.25}`, "", ""},
+		{"numeric from a string", settings, `{"flag": true, "ratio": "abc"}`, "", "argument ratio must be a number (numeric(5,2))"},
+		{"unnamed arguments", unnamed, `{}`, "", "legacy(text) has unnamed arguments and cannot be called through PostgREST"},
+		{"ambiguous defaults", ambiguous, `{"a": "x"}`, "", "several overloads accept these arguments"},
+		{"ambiguity resolved", ambiguous, `{"a": "x", "c": "y"}`, "", ""},
+		{"text rejects objects", ambiguous, `{"a": {"x": 1}}`, "", "no overload accepts"},
+	}
+
+	for _, tt := range tests {
+		function, err := matchFunction(tt.functions, rpcArgs(t, tt.args))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: matchFunction() = %v, want an error containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: matchFunction() = %v", tt.name, err)
+			continue
+		}
+		if tt.want != "" && function.ReturnType != tt.want {
+			t.Errorf("%s: matched overload %s, want %s", tt.name, function.signature(), tt.want)
+		}
+	}
+}
+
+func TestRPCParam(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`"plain"`, "plain"},
+		{`"a,b"`, "a,b"},
+		{`9007199254740993`, "9007199254740993"},
+		{`true`, "true"},
+		{`null`, "null"},
+		{`["a", "b c", null, "x,y"]`, `{a,"b c",NULL,"x,y"}`},
+		{`[[1, 2]]`, `[[1,2]]`},
+		{`{"a": 1}`, `{"a":1}`},
+	}
+
+	for _, tt := range tests {
+		var value interface{}
+		decoder := json.NewDecoder(strings.NewReader(tt.value))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			t.Fatal(err)
+		}
+		if got := rpcParam(value); got != tt.want {
+			t.Errorf("rpcParam(%s) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
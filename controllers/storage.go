@@ -2,12 +2,16 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
 	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
 	"github.com/gin-gonic/gin"
 )
@@ -15,60 +19,64 @@ import (
 // StorageController handles storage-related operations
 type StorageController struct {
 	supabase *supabase.SupabaseClientExtended
+	db       database.Executor
+	cfg      config.StorageConfig
+	uploads  *uploadSessions
 }
 
 // NewStorageController creates a new storage controller
-func NewStorageController(client *supabase.SupabaseClientExtended) *StorageController {
+func NewStorageController(client *supabase.SupabaseClientExtended, db database.Executor, cfg config.StorageConfig) *StorageController {
 	return &StorageController{
 		supabase: client,
+		db:       db,
+		cfg:      cfg,
+		uploads:  newUploadSessions(cfg.UploadSessionTTL),
 	}
 }
 
 // GetBucketsRequest represents the request body for getting storage buckets
 type GetBucketsRequest struct {
-	ID string `json:"id"`
+	ID string `json:"id" description:"Bucket ID (optional, if not provided returns all buckets)"`
 }
 
 // GetBuckets gets storage buckets
 func (sc *StorageController) GetBuckets(c *gin.Context) {
 	var req GetBucketsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	query := `SELECT * FROM storage.buckets`
+	query := database.NewQuery(`SELECT * FROM storage.buckets`)
 
 	if req.ID != "" {
-		query += fmt.Sprintf(` WHERE id = '%s'`, req.ID)
+		query.Write(` WHERE id = `).Arg(req.ID)
 	}
 
-	var result []map[string]interface{}
-	err := sc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": query,
-	}, &result)
+	result, err := sc.db.Query(c.Request.Context(), query.SQL(), query.Args()...)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, result.Rows)
 }
 
 // CreateBucketRequest represents the request body for creating a storage bucket
 type CreateBucketRequest struct {
-	ID               string   `json:"id"`
-	Name             string   `json:"name"`
-	Public           bool     `json:"public"`
-	FileSizeLimit    *int64   `json:"file_size_limit"`
-	AllowedMimeTypes []string `json:"allowed_mime_types"`
+	ID               string   `json:"id" jsonschema:"required" description:"Bucket ID"`
+	Name             string   `json:"name" description:"Bucket name (optional, defaults to ID)"`
+	Public           bool     `json:"public" description:"Whether the bucket is public (optional, defaults to false)"`
+	FileSizeLimit    *int64   `json:"file_size_limit" jsonschema:"minimum=0" description:"File size limit in bytes (optional)"`
+	AllowedMimeTypes []string `json:"allowed_mime_types" description:"Allowed MIME types (optional)"`
+	UseSQL           bool     `json:"use_sql" description:"Insert into storage.buckets directly instead of calling the Storage API, skipping its validation (optional, only as a fallback when the Storage API is unavailable)"`
 }
 
 // CreateBucket creates a new storage bucket
 func (sc *StorageController) CreateBucket(c *gin.Context) {
 	var req CreateBucketRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -78,57 +86,55 @@ func (sc *StorageController) CreateBucket(c *gin.Context) {
 		return
 	}
 
-	// Try using Supabase API first
-	// Note: The current supabase-go client doesn't support storage operations directly
-	// We'll implement the SQL fallback for now
-
 	// Set default name if not provided
 	if req.Name == "" {
 		req.Name = req.ID
 	}
 
-	// Convert allowed mime types to JSON if provided
-	var allowedMimeTypesJSON string
-	if len(req.AllowedMimeTypes) > 0 {
-		mimeTypesBytes, err := json.Marshal(req.AllowedMimeTypes)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid MIME types format"})
-			return
-		}
-		allowedMimeTypesJSON = string(mimeTypesBytes)
+	if req.UseSQL {
+		sc.createBucketSQL(c, req)
+		return
 	}
 
-	// Build SQL query
-	fileSizeLimitStr := "NULL"
-	if req.FileSizeLimit != nil {
-		fileSizeLimitStr = fmt.Sprintf("%d", *req.FileSizeLimit)
+	err := sc.supabase.StorageAPI().CreateBucket(c.Request.Context(), req.ID, req.Name, supabase.BucketOptions{
+		Public:           &req.Public,
+		FileSizeLimit:    req.FileSizeLimit,
+		AllowedMimeTypes: req.AllowedMimeTypes,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
 	}
 
-	allowedMimeTypesStr := "NULL"
-	if allowedMimeTypesJSON != "" {
-		allowedMimeTypesStr = fmt.Sprintf("'%s'::jsonb", allowedMimeTypesJSON)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Bucket '%s' created successfully", req.ID),
+		"method":  "storage_api",
+	})
+}
+
+// createBucketSQL creates a bucket by inserting into storage.buckets
+func (sc *StorageController) createBucketSQL(c *gin.Context, req CreateBucketRequest) {
+	// allowed_mime_types is a text[] column; NULL means no restriction
+	var allowedMimeTypes interface{}
+	if len(req.AllowedMimeTypes) > 0 {
+		allowedMimeTypes = req.AllowedMimeTypes
+	}
+
+	var fileSizeLimit interface{}
+	if req.FileSizeLimit != nil {
+		fileSizeLimit = *req.FileSizeLimit
 	}
 
-	sql := fmt.Sprintf(`
+	sql := `
 		INSERT INTO storage.buckets (id, name, public, file_size_limit, allowed_mime_types, created_at, updated_at)
-		VALUES (
-			'%s',
-			'%s',
-			%t,
-			%s,
-			%s,
-			NOW(),
-			NOW()
-		)
-	`, req.ID, req.Name, req.Public, fileSizeLimitStr, allowedMimeTypesStr)
-
-	var result interface{}
-	err := sc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+	`
+
+	_, err := sc.db.Query(c.Request.Context(), sql, req.ID, req.Name, req.Public, fileSizeLimit, allowedMimeTypes)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
@@ -141,16 +147,26 @@ func (sc *StorageController) CreateBucket(c *gin.Context) {
 
 // UpdateBucketRequest represents the request body for updating a storage bucket
 type UpdateBucketRequest struct {
-	ID               string   `json:"id"`
-	Public           *bool    `json:"public"`
-	FileSizeLimit    *int64   `json:"file_size_limit"`
-	AllowedMimeTypes []string `json:"allowed_mime_types"`
+	ID               string   `json:"id" jsonschema:"required" description:"Bucket ID"`
+	Public           *bool    `json:"public" description:"Whether the bucket is public (optional)"`
+	FileSizeLimit    *int64   `json:"file_size_limit" jsonschema:"minimum=0" description:"File size limit in bytes (optional, null removes the limit)"`
+	AllowedMimeTypes []string `json:"allowed_mime_types" description:"Allowed MIME types (optional, an empty list removes the restriction)"`
+	UseSQL           bool     `json:"use_sql" description:"Update storage.buckets directly instead of calling the Storage API, skipping its validation (optional, only as a fallback when the Storage API is unavailable)"`
 }
 
 // UpdateBucket updates a storage bucket
 func (sc *StorageController) UpdateBucket(c *gin.Context) {
+	// The body is kept to tell an explicit null file_size_limit, which
+	// removes the limit, from an omitted one
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
 	var req UpdateBucketRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -160,67 +176,111 @@ func (sc *StorageController) UpdateBucket(c *gin.Context) {
 		return
 	}
 
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(body, &fields)
+	opts := supabase.BucketOptions{
+		Public:             req.Public,
+		FileSizeLimit:      req.FileSizeLimit,
+		ClearFileSizeLimit: req.FileSizeLimit == nil && string(bytes.TrimSpace(fields["file_size_limit"])) == "null",
+		AllowedMimeTypes:   req.AllowedMimeTypes,
+	}
+
+	if req.UseSQL {
+		sc.updateBucketSQL(c, req.ID, opts)
+		return
+	}
+
+	if err := sc.supabase.StorageAPI().UpdateBucket(c.Request.Context(), req.ID, opts); err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Bucket '%s' updated successfully", req.ID),
+		"method":  "storage_api",
+	})
+}
+
+// updateBucketSQL updates a bucket in storage.buckets
+func (sc *StorageController) updateBucketSQL(c *gin.Context, id string, opts supabase.BucketOptions) {
 	// Build SQL query
-	sql := `UPDATE storage.buckets SET updated_at = NOW()`
+	sql := database.NewQuery(`UPDATE storage.buckets SET updated_at = NOW()`)
 
-	if req.Public != nil {
-		sql += fmt.Sprintf(`, public = %t`, *req.Public)
+	if opts.Public != nil {
+		sql.Write(`, public = `).Arg(*opts.Public)
 	}
 
-	if req.FileSizeLimit != nil {
-		sql += fmt.Sprintf(`, file_size_limit = %d`, *req.FileSizeLimit)
-	} else {
-		// If explicitly set to nil in the request
-		// Check if the request body contains file_size_limit
-		bodyBytes, _ := c.GetRawData()
-		if len(bodyBytes) > 0 && strings.Contains(string(bodyBytes), "file_size_limit") {
-			sql += `, file_size_limit = NULL`
-		}
-		// Reset the request body for further processing
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	}
-
-	if req.AllowedMimeTypes != nil {
-		if len(req.AllowedMimeTypes) > 0 {
-			mimeTypesBytes, err := json.Marshal(req.AllowedMimeTypes)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid MIME types format"})
-				return
-			}
-			sql += fmt.Sprintf(`, allowed_mime_types = '%s'::jsonb`, string(mimeTypesBytes))
+	if opts.FileSizeLimit != nil {
+		sql.Write(`, file_size_limit = `).Arg(*opts.FileSizeLimit)
+	} else if opts.ClearFileSizeLimit {
+		sql.Write(`, file_size_limit = NULL`)
+	}
+
+	if opts.AllowedMimeTypes != nil {
+		if len(opts.AllowedMimeTypes) > 0 {
+			sql.Write(`, allowed_mime_types = `).Arg(opts.AllowedMimeTypes)
 		} else {
-			sql += `, allowed_mime_types = NULL`
+			sql.Write(`, allowed_mime_types = NULL`)
 		}
 	}
 
-	sql += fmt.Sprintf(` WHERE id = '%s'`, req.ID)
+	sql.Write(` WHERE id = `).Arg(id)
 
-	var result interface{}
-	err := sc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	_, err := sc.db.Query(c.Request.Context(), sql.SQL(), sql.Args()...)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": fmt.Sprintf("Bucket '%s' updated successfully", req.ID),
+		"message": fmt.Sprintf("Bucket '%s' updated successfully", id),
 		"method":  "sql",
 	})
 }
 
+// EmptyBucketRequest represents the request body for emptying a storage bucket
+type EmptyBucketRequest struct {
+	ID string `json:"id" jsonschema:"required" description:"Bucket ID"`
+}
+
+// EmptyBucket deletes every object in a storage bucket
+func (sc *StorageController) EmptyBucket(c *gin.Context) {
+	var req EmptyBucketRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID is required"})
+		return
+	}
+
+	if err := sc.supabase.StorageAPI().EmptyBucket(c.Request.Context(), req.ID); err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Bucket '%s' emptied; large buckets are emptied in the background", req.ID),
+	})
+}
+
 // DeleteBucketRequest represents the request body for deleting a storage bucket
 type DeleteBucketRequest struct {
-	ID string `json:"id"`
+	ID         string `json:"id" jsonschema:"required" description:"Bucket ID"`
+	EmptyFirst bool   `json:"empty_first" description:"Delete the objects in the bucket first; a bucket that still has objects cannot be deleted (optional, defaults to false)"`
+	UseSQL     bool   `json:"use_sql" description:"Delete from storage.buckets directly instead of calling the Storage API (optional, only as a fallback when the Storage API is unavailable; cannot be combined with empty_first)"`
 }
 
 // DeleteBucket deletes a storage bucket
 func (sc *StorageController) DeleteBucket(c *gin.Context) {
 	var req DeleteBucketRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -230,34 +290,161 @@ func (sc *StorageController) DeleteBucket(c *gin.Context) {
 		return
 	}
 
-	sql := fmt.Sprintf(`DELETE FROM storage.buckets WHERE id = '%s'`, req.ID)
+	if req.UseSQL {
+		if req.EmptyFirst {
+			// Deleting rows from storage.objects would leave the files behind
+			// in the storage backend
+			c.JSON(http.StatusBadRequest, gin.H{"error": "empty_first requires the Storage API and cannot be combined with use_sql"})
+			return
+		}
+		sc.deleteBucketSQL(c, req.ID)
+		return
+	}
 
-	var result interface{}
-	err := sc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	storage := sc.supabase.StorageAPI()
+	if req.EmptyFirst {
+		if err := storage.EmptyBucket(c.Request.Context(), req.ID); err != nil {
+			c.JSON(errorStatus(err), apiError(err))
+			return
+		}
+		if err := sc.waitForEmptyBucket(c.Request.Context(), req.ID); err != nil {
+			c.JSON(errorStatus(err), apiError(err))
+			return
+		}
+	}
 
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := storage.DeleteBucket(c.Request.Context(), req.ID); err != nil {
+		c.JSON(errorStatus(err), apiError(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": fmt.Sprintf("Bucket '%s' deleted successfully", req.ID),
+		"method":  "storage_api",
+	})
+}
+
+// deleteBucketSQL deletes a bucket from storage.buckets
+func (sc *StorageController) deleteBucketSQL(c *gin.Context, id string) {
+	sql := `DELETE FROM storage.buckets WHERE id = $1`
+
+	_, err := sc.db.Query(c.Request.Context(), sql, id)
+
+	if err != nil {
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Bucket '%s' deleted successfully", id),
 		"method":  "sql",
 	})
 }
 
+// emptyBucketTimeout bounds the wait for a bucket to be emptied before it
+// is deleted
+const emptyBucketTimeout = 30 * time.Second
+
+// waitForEmptyBucket waits until a bucket has no objects left, since the
+// Storage API may empty it in the background
+func (sc *StorageController) waitForEmptyBucket(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, emptyBucketTimeout)
+	defer cancel()
+
+	for delay := 100 * time.Millisecond; ; delay *= 2 {
+		objects, err := sc.supabase.StorageAPI().ListObjects(ctx, id, supabase.ListObjectsOptions{Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(objects) == 0 {
+			return nil
+		}
+
+		if delay > 2*time.Second {
+			delay = 2 * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("bucket '%s' is still being emptied: %w", id, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Storage access is controlled by RLS policies on storage.objects. The
+// bucket policy tools scope each policy to a bucket by adding a bucket_id
+// condition to its expressions, and recognize the policies of a bucket by
+// that condition.
+
+// storagePolicyTemplate describes a common storage policy
+type storagePolicyTemplate struct {
+	Name      string
+	Operation string
+	Roles     []string
+	// Condition is added to the bucket condition; empty for none
+	Condition string
+}
+
+// storagePolicyTemplates holds the templates offered by create_bucket_policy
+var storagePolicyTemplates = map[string]storagePolicyTemplate{
+	"public_read":          {Name: "public read", Operation: "SELECT", Roles: []string{"public"}},
+	"authenticated_upload": {Name: "authenticated upload", Operation: "INSERT", Roles: []string{"authenticated"}},
+	"owner_only":           {Name: "owner only", Operation: "ALL", Roles: []string{"authenticated"}, Condition: "owner_id = (SELECT auth.uid()::text)"},
+	"user_folder":          {Name: "user folder", Operation: "ALL", Roles: []string{"authenticated"}, Condition: "(storage.foldername(name))[1] = (SELECT auth.uid()::text)"},
+}
+
+// storageObjectsTable returns the quoted name of storage.objects
+func storageObjectsTable() string {
+	return database.QualifiedName("storage", "objects")
+}
+
+// bucketScope adds the bucket condition to a policy expression
+func bucketScope(bucket, expr string) string {
+	condition := "bucket_id = " + database.QuoteLiteral(bucket)
+	if expr == "" {
+		return condition
+	}
+	return fmt.Sprintf("%s AND (%s)", condition, expr)
+}
+
+// bucketScopeMarker is the bucket condition as PostgreSQL prints it back
+// in pg_policies
+func bucketScopeMarker(bucket string) string {
+	return fmt.Sprintf("bucket_id = %s::text", database.QuoteLiteral(bucket))
+}
+
+// policyInBucket reports whether a policy on storage.objects is scoped to
+// a bucket
+func policyInBucket(policy *policySpec, bucket string) bool {
+	marker := bucketScopeMarker(bucket)
+	return strings.Contains(policy.Definition, marker) || strings.Contains(policy.Check, marker)
+}
+
+// findBucket reads a bucket from storage.buckets, or returns nil if it does
+// not exist
+func (sc *StorageController) findBucket(ctx context.Context, id string) (map[string]interface{}, error) {
+	result, err := sc.db.Query(ctx, `SELECT id, name, public FROM storage.buckets WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Rows) == 0 {
+		return nil, nil
+	}
+	return result.Rows[0], nil
+}
+
 // GetBucketPoliciesRequest represents the request body for getting bucket policies
 type GetBucketPoliciesRequest struct {
-	BucketID string `json:"bucket_id"`
+	BucketID string `json:"bucket_id" jsonschema:"required" description:"Bucket ID"`
 }
 
-// GetBucketPolicies gets policies for a storage bucket
+// GetBucketPolicies gets the RLS policies on storage.objects that apply to
+// a bucket
 func (sc *StorageController) GetBucketPolicies(c *gin.Context) {
 	var req GetBucketPoliciesRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -267,166 +454,213 @@ func (sc *StorageController) GetBucketPolicies(c *gin.Context) {
 		return
 	}
 
-	query := fmt.Sprintf(`
-		SELECT 
+	query := `
+		SELECT
 			policyname AS name,
-			CASE operation
-				WHEN 10 THEN 'SELECT'
-				WHEN 20 THEN 'INSERT'
-				WHEN 40 THEN 'UPDATE'
-				WHEN 80 THEN 'DELETE'
-				ELSE 'UNKNOWN'
-			END AS operation,
-			definition,
-			role
-		FROM storage.policies
-		WHERE name = '%s'
-	`, req.BucketID)
-
-	var result []map[string]interface{}
-	err := sc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": query,
-	}, &result)
+			cmd AS operation,
+			permissive AS type,
+			roles,
+			qual AS definition,
+			with_check AS check_expression
+		FROM pg_policies
+		WHERE schemaname = 'storage' AND tablename = 'objects'
+			AND strpos(COALESCE(qual, '') || ' ' || COALESCE(with_check, ''), $1) > 0
+		ORDER BY policyname
+	`
+
+	result, err := sc.db.Query(c.Request.Context(), query, bucketScopeMarker(req.BucketID))
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, result.Rows)
 }
 
 // CreateBucketPolicyRequest represents the request body for creating a bucket policy
 type CreateBucketPolicyRequest struct {
-	BucketID   string `json:"bucket_id"`
-	Name       string `json:"name"`
-	Operation  string `json:"operation"`
-	Definition string `json:"definition"`
-	Role       string `json:"role"`
+	BucketID   string   `json:"bucket_id" jsonschema:"required" description:"Bucket ID"`
+	Name       string   `json:"name" description:"Policy name (required without a template; defaults to the bucket ID and the template name)"`
+	Template   string   `json:"template" jsonschema:"enum=public_read|authenticated_upload|owner_only|user_folder" description:"Common policy to create: public_read lets anyone download, authenticated_upload lets signed-in users upload, owner_only limits users to the objects they uploaded, user_folder limits users to the folder named after their user ID (optional, use either template or definition)"`
+	Operation  string   `json:"operation" jsonschema:"enum=SELECT|INSERT|UPDATE|DELETE|ALL" description:"Operation type (required without a template; overrides the template's)"`
+	Definition string   `json:"definition" description:"Policy expression on the columns of storage.objects, e.g. owner_id = auth.uid()::text; the bucket condition is added to it (optional, use either template or definition)"`
+	Check      string   `json:"check" description:"Check expression for INSERT/UPDATE operations; the bucket condition is added to it (optional)"`
+	Role       string   `json:"role" description:"Role name (optional, defaults to the template's roles or authenticated)"`
+	Roles      []string `json:"roles" description:"Role names the policy applies to (optional)"`
 }
 
-// CreateBucketPolicy creates a policy for a storage bucket
+// CreateBucketPolicy creates an RLS policy on storage.objects scoped to a
+// bucket
 func (sc *StorageController) CreateBucketPolicy(c *gin.Context) {
 	var req CreateBucketPolicyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if req.BucketID == "" || req.Name == "" || req.Operation == "" || req.Definition == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
+	if req.BucketID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID is required"})
+		return
+	}
+	if (req.Template == "") == (req.Definition == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of template and definition is required"})
 		return
 	}
 
-	// Set default role if not provided
-	if req.Role == "" {
-		req.Role = "authenticated"
+	operation := req.Operation
+	condition := req.Definition
+	roles := []string{"authenticated"}
+	if req.Template != "" {
+		template, ok := storagePolicyTemplates[req.Template]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown template '%s'", req.Template)})
+			return
+		}
+		if operation == "" {
+			operation = template.Operation
+		}
+		if req.Name == "" {
+			req.Name = fmt.Sprintf("%s %s", req.BucketID, template.Name)
+		}
+		condition = template.Condition
+		roles = template.Roles
+	}
+	if req.Name == "" || operation == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and operation are required without a template"})
+		return
+	}
+	if req.Role != "" || len(req.Roles) > 0 {
+		roles = nil
 	}
 
-	// Map operation string to internal code
-	opMap := map[string]int{
-		"SELECT": 10,
-		"INSERT": 20,
-		"UPDATE": 40,
-		"DELETE": 80,
+	if req.Definition != "" {
+		if err := database.ValidateExpression(req.Definition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("definition: %v", err)})
+			return
+		}
+	}
+	check := ""
+	if req.Check != "" {
+		if err := database.ValidateExpression(req.Check); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("check: %v", err)})
+			return
+		}
+		check = bucketScope(req.BucketID, req.Check)
 	}
 
-	opCode, ok := opMap[req.Operation]
-	if !ok {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid operation. Must be SELECT, INSERT, UPDATE, or DELETE"})
+	policy, err := newPolicySpec(req.Name, operation, "", req.Role, append(roles, req.Roles...), bucketScope(req.BucketID, condition), check)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Escape single quotes in definition
-	definition := strings.ReplaceAll(req.Definition, "'", "''")
+	bucket, err := sc.findBucket(c.Request.Context(), req.BucketID)
+	if err != nil {
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
+	if bucket == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Bucket '%s' not found", req.BucketID)})
+		return
+	}
 
-	sql := fmt.Sprintf(`
-		INSERT INTO storage.policies (name, bucket_id, operation, definition, role, created_at)
-		VALUES (
-			'%s',
-			'%s',
-			%d,
-			'%s',
-			'%s',
-			NOW()
-		)
-	`, req.Name, req.BucketID, opCode, definition, req.Role)
+	sql := createPolicySQL(storageObjectsTable(), policy)
 
-	var result interface{}
-	err := sc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	_, err = sc.db.Query(c.Request.Context(), sql)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": fmt.Sprintf("Policy '%s' for bucket '%s' created successfully", req.Name, req.BucketID),
+		"sql":     sql,
 	})
 }
 
 // UpdateBucketPolicyRequest represents the request body for updating a bucket policy
 type UpdateBucketPolicyRequest struct {
-	BucketID   string `json:"bucket_id"`
-	Name       string `json:"name"`
-	Definition string `json:"definition"`
+	BucketID   string   `json:"bucket_id" jsonschema:"required" description:"Bucket ID"`
+	Name       string   `json:"name" jsonschema:"required" description:"Policy name"`
+	NewName    string   `json:"new_name" description:"New policy name (optional)"`
+	Operation  string   `json:"operation" jsonschema:"enum=SELECT|INSERT|UPDATE|DELETE|ALL" description:"Operation type (optional, unchanged by default)"`
+	Roles      []string `json:"roles" description:"Role names the policy applies to (optional, unchanged by default)"`
+	Definition string   `json:"definition" description:"Policy expression; the bucket condition is added to it (optional, unchanged by default)"`
+	Check      string   `json:"check" description:"Check expression for INSERT/UPDATE operations; the bucket condition is added to it (optional, unchanged by default)"`
 }
 
-// UpdateBucketPolicy updates a policy for a storage bucket
+// UpdateBucketPolicy updates an RLS policy of a bucket in place, keeping
+// it scoped to the bucket
 func (sc *StorageController) UpdateBucketPolicy(c *gin.Context) {
 	var req UpdateBucketPolicyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if req.BucketID == "" || req.Name == "" || req.Definition == "" {
+	if req.BucketID == "" || req.Name == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
 		return
 	}
 
-	// Escape single quotes in definition
-	definition := strings.ReplaceAll(req.Definition, "'", "''")
-
-	sql := fmt.Sprintf(`
-		UPDATE storage.policies
-		SET 
-			definition = '%s',
-			updated_at = NOW()
-		WHERE 
-			bucket_id = '%s' AND 
-			name = '%s'
-	`, definition, req.BucketID, req.Name)
+	changes := policyChanges{NewName: req.NewName, Operation: req.Operation, Roles: req.Roles}
+	if req.Definition != "" {
+		if err := database.ValidateExpression(req.Definition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("definition: %v", err)})
+			return
+		}
+		changes.Definition = bucketScope(req.BucketID, req.Definition)
+	}
+	if req.Check != "" {
+		if err := database.ValidateExpression(req.Check); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("check: %v", err)})
+			return
+		}
+		changes.Check = bucketScope(req.BucketID, req.Check)
+	}
 
-	var result interface{}
-	err := sc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	current, ok := sc.loadBucketPolicy(c, req.BucketID, req.Name)
+	if !ok {
+		return
+	}
 
+	statements, recreate, err := updatePolicySQL(storageObjectsTable(), current, changes)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if len(statements) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+		return
+	}
+
+	_, err = sc.db.Query(c.Request.Context(), database.Atomic(statements...))
+
+	if err != nil {
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("Policy '%s' for bucket '%s' updated successfully", req.Name, req.BucketID),
+		"success":   true,
+		"recreated": recreate,
+		"message":   fmt.Sprintf("Policy '%s' for bucket '%s' updated successfully", policyName(req.Name, req.NewName), req.BucketID),
 	})
 }
 
 // DeleteBucketPolicyRequest represents the request body for deleting a bucket policy
 type DeleteBucketPolicyRequest struct {
-	BucketID string `json:"bucket_id"`
-	Name     string `json:"name"`
+	BucketID string `json:"bucket_id" jsonschema:"required" description:"Bucket ID"`
+	Name     string `json:"name" jsonschema:"required" description:"Policy name"`
 }
 
-// DeleteBucketPolicy deletes a policy for a storage bucket
+// DeleteBucketPolicy drops an RLS policy of a bucket
 func (sc *StorageController) DeleteBucketPolicy(c *gin.Context) {
 	var req DeleteBucketPolicyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -436,20 +670,16 @@ func (sc *StorageController) DeleteBucketPolicy(c *gin.Context) {
 		return
 	}
 
-	sql := fmt.Sprintf(`
-		DELETE FROM storage.policies
-		WHERE 
-			bucket_id = '%s' AND 
-			name = '%s'
-	`, req.BucketID, req.Name)
+	if _, ok := sc.loadBucketPolicy(c, req.BucketID, req.Name); !ok {
+		return
+	}
 
-	var result interface{}
-	err := sc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	sql := fmt.Sprintf(`DROP POLICY %s ON %s`, database.QuoteIdent(req.Name), storageObjectsTable())
+
+	_, err := sc.db.Query(c.Request.Context(), sql)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
@@ -458,3 +688,19 @@ func (sc *StorageController) DeleteBucketPolicy(c *gin.Context) {
 		"message": fmt.Sprintf("Policy '%s' for bucket '%s' deleted successfully", req.Name, req.BucketID),
 	})
 }
+
+// loadBucketPolicy reads a policy on storage.objects and checks that it is
+// scoped to the bucket. It writes the error response and returns false
+// when the policy cannot be used.
+func (sc *StorageController) loadBucketPolicy(c *gin.Context, bucket, name string) (*policySpec, bool) {
+	policy, err := loadPolicy(c.Request.Context(), sc.db, storageObjectsTable(), name)
+	if err != nil {
+		c.JSON(errorStatus(err), sqlError(err))
+		return nil, false
+	}
+	if policy == nil || !policyInBucket(policy, bucket) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Policy '%s' not found for bucket '%s'", name, bucket)})
+		return nil, false
+	}
+	return policy, true
+}
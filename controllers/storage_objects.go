@@ -0,0 +1,336 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+	"github.com/gin-gonic/gin"
+)
+
+// ListObjectsRequest represents the request body for listing storage objects
+type ListObjectsRequest struct {
+	Bucket    string `json:"bucket" jsonschema:"required" description:"Bucket ID"`
+	Prefix    string `json:"prefix" description:"Folder to list, e.g. avatars/2024 (optional, defaults to the bucket root)"`
+	Search    string `json:"search" description:"Only list names containing this text (optional)"`
+	Limit     int    `json:"limit" jsonschema:"default=100,minimum=1,maximum=1000" description:"Maximum number of entries to return (optional, defaults to 100)"`
+	Offset    int    `json:"offset" jsonschema:"minimum=0" description:"Number of entries to skip (optional)"`
+	SortBy    string `json:"sort_by" jsonschema:"enum=name|created_at|updated_at|last_accessed_at,default=name" description:"Column to sort by (optional, defaults to name)"`
+	SortOrder string `json:"sort_order" jsonschema:"enum=asc|desc,default=asc" description:"Sort order (optional, defaults to asc)"`
+}
+
+// ListObjects lists the files and folders under a prefix of a bucket
+func (sc *StorageController) ListObjects(c *gin.Context) {
+	var req ListObjectsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID is required"})
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+
+	objects, err := sc.supabase.StorageAPI().ListObjects(c.Request.Context(), req.Bucket, supabase.ListObjectsOptions{
+		Prefix:    strings.Trim(req.Prefix, "/"),
+		Search:    req.Search,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+		SortBy:    req.SortBy,
+		SortOrder: req.SortOrder,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	var nextOffset *int
+	if len(objects) == req.Limit {
+		next := req.Offset + req.Limit
+		nextOffset = &next
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"objects":     objects,
+		"next_offset": nextOffset,
+	})
+}
+
+// UploadObjectRequest represents the request body for uploading a storage object
+type UploadObjectRequest struct {
+	Bucket        string `json:"bucket" jsonschema:"required" description:"Bucket ID"`
+	Path          string `json:"path" jsonschema:"required" description:"Object path inside the bucket, e.g. docs/readme.md"`
+	Content       string `json:"content" description:"Content as text (use either content or content_base64)"`
+	ContentBase64 string `json:"content_base64" description:"Content encoded as base64, for binary files (use either content or content_base64)"`
+	ContentType   string `json:"content_type" description:"MIME type (optional, guessed from the extension)"`
+	CacheControl  string `json:"cache_control" description:"Cache-Control header, e.g. max-age=3600 (optional)"`
+	Upsert        bool   `json:"upsert" description:"Replace the object if it exists (optional, defaults to false)"`
+}
+
+// decodedSize returns the number of bytes encoded decodes to, without
+// decoding it; it is exact for valid padded base64
+func decodedSize(encoded string) int64 {
+	size := int64(base64.StdEncoding.DecodedLen(len(encoded)))
+	for i := len(encoded) - 1; i >= 0 && i >= len(encoded)-2 && encoded[i] == '='; i-- {
+		size--
+	}
+	return size
+}
+
+// UploadObject uploads a file given as text or base64
+func (sc *StorageController) UploadObject(c *gin.Context) {
+	var req UploadObjectRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID and path are required"})
+		return
+	}
+	if (req.Content == "") == (req.ContentBase64 == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of content and content_base64 is required"})
+		return
+	}
+
+	// The size is checked before decoding, so that oversized content is
+	// not copied again
+	size := int64(len(req.Content))
+	if req.ContentBase64 != "" {
+		size = decodedSize(req.ContentBase64)
+	}
+	if size > sc.cfg.MaxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Content is %d bytes; the upload limit is %d (STORAGE_MAX_UPLOAD_SIZE)", size, sc.cfg.MaxUploadSize)})
+		return
+	}
+
+	data := []byte(req.Content)
+	if req.ContentBase64 != "" {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(req.ContentBase64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("content_base64 is not valid base64: %v", err)})
+			return
+		}
+	}
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = guessContentType(req.Path, req.ContentBase64 == "")
+	}
+
+	key, err := sc.supabase.StorageAPI().Upload(c.Request.Context(), req.Bucket, req.Path, data, supabase.UploadOptions{
+		ContentType:  contentType,
+		CacheControl: req.CacheControl,
+		Upsert:       req.Upsert,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"key":          key,
+		"size":         len(data),
+		"content_type": contentType,
+	})
+}
+
+// DownloadObjectRequest represents the request body for downloading a storage object
+type DownloadObjectRequest struct {
+	Bucket   string `json:"bucket" jsonschema:"required" description:"Bucket ID"`
+	Path     string `json:"path" jsonschema:"required" description:"Object path inside the bucket"`
+	Encoding string `json:"encoding" jsonschema:"enum=auto|text|base64,default=auto" description:"auto returns text MIME types inline and everything else as base64 (optional, defaults to auto)"`
+	MaxBytes int64  `json:"max_bytes" jsonschema:"minimum=1" description:"Refuse objects larger than this (optional, defaults to and cannot exceed STORAGE_MAX_DOWNLOAD_SIZE)"`
+}
+
+// DownloadObject downloads a file as inline text or base64
+func (sc *StorageController) DownloadObject(c *gin.Context) {
+	var req DownloadObjectRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID and path are required"})
+		return
+	}
+
+	limit := sc.cfg.MaxDownloadSize
+	if req.MaxBytes > 0 && req.MaxBytes < limit {
+		limit = req.MaxBytes
+	}
+
+	object, err := sc.supabase.StorageAPI().Download(c.Request.Context(), req.Bucket, req.Path, limit)
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	encoding := req.Encoding
+	if encoding == "" || encoding == "auto" {
+		encoding = "base64"
+		if isTextContentType(object.ContentType) && utf8.Valid(object.Data) {
+			encoding = "text"
+		}
+	}
+
+	var content string
+	switch encoding {
+	case "text":
+		if !utf8.Valid(object.Data) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Object is not valid UTF-8 text; download it as base64"})
+			return
+		}
+		content = string(object.Data)
+	case "base64":
+		content = base64.StdEncoding.EncodeToString(object.Data)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "encoding must be auto, text or base64"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"path":         req.Path,
+		"content_type": object.ContentType,
+		"size":         len(object.Data),
+		"encoding":     encoding,
+		"content":      content,
+	})
+}
+
+// TransferObjectRequest represents the request body for moving or copying a storage object
+type TransferObjectRequest struct {
+	Bucket            string `json:"bucket" jsonschema:"required" description:"Bucket ID of the source object"`
+	From              string `json:"from" jsonschema:"required" description:"Path of the source object"`
+	To                string `json:"to" jsonschema:"required" description:"Destination path"`
+	DestinationBucket string `json:"destination_bucket" description:"Bucket ID of the destination (optional, defaults to the source bucket)"`
+}
+
+// MoveObject moves or renames a file
+func (sc *StorageController) MoveObject(c *gin.Context) {
+	var req TransferObjectRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || req.From == "" || req.To == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
+		return
+	}
+
+	if err := sc.supabase.StorageAPI().Move(c.Request.Context(), req.Bucket, req.From, req.To, req.DestinationBucket); err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Object '%s' moved to '%s'", req.From, req.To),
+	})
+}
+
+// CopyObject copies a file
+func (sc *StorageController) CopyObject(c *gin.Context) {
+	var req TransferObjectRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || req.From == "" || req.To == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required parameters"})
+		return
+	}
+
+	key, err := sc.supabase.StorageAPI().Copy(c.Request.Context(), req.Bucket, req.From, req.To, req.DestinationBucket)
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"key":     key,
+		"message": fmt.Sprintf("Object '%s' copied to '%s'", req.From, req.To),
+	})
+}
+
+// DeleteObjectsRequest represents the request body for deleting storage objects
+type DeleteObjectsRequest struct {
+	Bucket string   `json:"bucket" jsonschema:"required" description:"Bucket ID"`
+	Paths  []string `json:"paths" jsonschema:"required,minItems=1" description:"Paths of the objects to delete"`
+}
+
+// DeleteObjects deletes several files at once
+func (sc *StorageController) DeleteObjects(c *gin.Context) {
+	var req DeleteObjectsRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || len(req.Paths) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID and paths are required"})
+		return
+	}
+
+	removed, err := sc.supabase.StorageAPI().Remove(c.Request.Context(), req.Bucket, req.Paths)
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	deleted := make([]string, 0, len(removed))
+	for _, object := range removed {
+		deleted = append(deleted, object.Name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"deleted": deleted,
+		"count":   len(deleted),
+	})
+}
+
+// guessContentType picks a MIME type from the file extension
+func guessContentType(path string, text bool) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		if contentType := mime.TypeByExtension(path[i:]); contentType != "" {
+			return contentType
+		}
+	}
+	if text {
+		return "text/plain; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// isTextContentType reports whether a MIME type holds text that can be
+// returned inline
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-yaml",
+		"application/yaml", "application/sql", "application/graphql", "image/svg+xml":
+		return true
+	}
+	return false
+}
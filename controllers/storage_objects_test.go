@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUploadSizeCheckedBeforeDecoding(t *testing.T) {
+	uploads := 0
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploads++
+		w.Write([]byte(`{"Key": "docs/file.bin"}`))
+	}))
+	defer storage.Close()
+
+	controller := NewStorageController(supabase.CreateClientExtended(storage.URL, "service-key"), nil, config.StorageConfig{
+		MaxUploadSize:    10,
+		UploadSessionTTL: time.Hour,
+	})
+	encode := func(size int) string {
+		return base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", size)))
+	}
+
+	tests := []struct {
+		body gin.H
+		want int
+	}{
+		{gin.H{"content_base64": encode(10)}, http.StatusOK},
+		{gin.H{"content_base64": encode(9)}, http.StatusOK},
+		{gin.H{"content_base64": encode(11)}, http.StatusRequestEntityTooLarge},
+		{gin.H{"content": strings.Repeat("x", 11)}, http.StatusRequestEntityTooLarge},
+		// Too large is reported before the content is found to be invalid
+		{gin.H{"content_base64": strings.Repeat("!", 1<<10)}, http.StatusRequestEntityTooLarge},
+		{gin.H{"content_base64": "!!!!"}, http.StatusBadRequest},
+	}
+
+	for i, tt := range tests {
+		tt.body["bucket"] = "docs"
+		tt.body["path"] = "file.bin"
+		before := uploads
+		if status, response := callJSON(t, controller.UploadObject, tt.body); status != tt.want {
+			t.Errorf("upload_object case %d: status %d, %v, want %d", i, status, response, tt.want)
+		}
+		if tt.want != http.StatusOK && uploads != before {
+			t.Errorf("upload_object case %d sent a refused upload to Storage", i)
+		}
+	}
+}
+
+func TestDecodedSize(t *testing.T) {
+	for size := 0; size < 8; size++ {
+		encoded := base64.StdEncoding.EncodeToString(make([]byte, size))
+		if got := decodedSize(encoded); got != int64(size) {
+			t.Errorf("decodedSize(%q) = %d, want %d", encoded, got, size)
+		}
+	}
+}
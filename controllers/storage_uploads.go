@@ -0,0 +1,545 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadSessions bounds the resumable uploads in progress at once, since
+// each one may buffer up to a chunk in memory. Completed uploads do not
+// count towards it.
+const maxUploadSessions = 32
+
+var (
+	// errUploadDirDisabled is returned for uploads from local files when
+	// STORAGE_UPLOAD_DIR is not set
+	errUploadDirDisabled = errors.New("uploads from local files are disabled; set STORAGE_UPLOAD_DIR to the directory they may be read from")
+	// errTooManyUploads is returned when maxUploadSessions are in progress
+	errTooManyUploads = fmt.Errorf("%d resumable uploads are already in progress; finish or cancel one first", maxUploadSessions)
+)
+
+// uploadSession is a resumable upload in progress. Chunks received from
+// the client are buffered until they fill a chunk of the size the Storage
+// API requires; uploads from a local file are read straight from it.
+type uploadSession struct {
+	id         string
+	bucket     string
+	path       string
+	url        string
+	size       int64
+	sourceFile string
+
+	// mu serializes the calls working on the upload
+	mu sync.Mutex
+	// uploaded is how many bytes the Storage API has acknowledged, and
+	// pending holds the bytes received after them
+	uploaded int64
+	pending  []byte
+}
+
+// received returns how many bytes of the file the server holds
+func (u *uploadSession) received() int64 {
+	return u.uploaded + int64(len(u.pending))
+}
+
+// advance records that the Storage API has received offset bytes
+func (u *uploadSession) advance(offset int64) {
+	if offset <= u.uploaded {
+		return
+	}
+	sent := offset - u.uploaded
+	if sent > int64(len(u.pending)) {
+		sent = int64(len(u.pending))
+	}
+	u.pending = u.pending[sent:]
+	u.uploaded = offset
+}
+
+// resync reads the offset of the upload back from the Storage API after a
+// failed chunk, so that the next attempt resumes from the right place. It
+// returns the error of the chunk.
+func (u *uploadSession) resync(ctx context.Context, storage *supabase.StorageAPI, err error) error {
+	if offset, headErr := storage.ResumableUploadOffset(ctx, u.url); headErr == nil {
+		u.advance(offset)
+	}
+	return err
+}
+
+// flush sends the buffered bytes that fill whole chunks, and the rest once
+// the whole file has been received
+func (u *uploadSession) flush(ctx context.Context, storage *supabase.StorageAPI) error {
+	for len(u.pending) >= supabase.ResumableChunkSize || (len(u.pending) > 0 && u.received() == u.size) {
+		n := len(u.pending)
+		if n > supabase.ResumableChunkSize {
+			n = supabase.ResumableChunkSize
+		}
+		offset, err := storage.UploadResumableChunk(ctx, u.url, u.uploaded, u.pending[:n])
+		if err != nil {
+			return u.resync(ctx, storage, err)
+		}
+		u.advance(offset)
+	}
+	return nil
+}
+
+// sendFile sends the source file from the acknowledged offset until it is
+// complete or, when maxBytes is positive, about maxBytes have been sent
+func (u *uploadSession) sendFile(ctx context.Context, storage *supabase.StorageAPI, maxBytes int64) error {
+	// Errors from the file are not wrapped, since *fs.PathError would pass
+	// for a network error in errorStatus; they concern the file the caller
+	// chose
+	file, err := os.Open(u.sourceFile)
+	if err != nil {
+		return badRequest("source_file: %v", err)
+	}
+	defer file.Close()
+
+	chunk := make([]byte, supabase.ResumableChunkSize)
+	start := u.uploaded
+	for u.uploaded < u.size && (maxBytes <= 0 || u.uploaded-start < maxBytes) {
+		n, err := file.ReadAt(chunk, u.uploaded)
+		if err != nil && err != io.EOF {
+			return badRequest("source_file: %v", err)
+		}
+		if n == 0 || (n < len(chunk) && u.uploaded+int64(n) < u.size) {
+			return badRequest("%s is shorter than when the upload started", u.sourceFile)
+		}
+
+		offset, err := storage.UploadResumableChunk(ctx, u.url, u.uploaded, chunk[:n])
+		if err != nil {
+			return u.resync(ctx, storage, err)
+		}
+		u.advance(offset)
+	}
+	return nil
+}
+
+// progress describes the state of the upload
+func (u *uploadSession) progress(expiresAt time.Time) gin.H {
+	return gin.H{
+		"upload_id":  u.id,
+		"bucket":     u.bucket,
+		"path":       u.path,
+		"size":       u.size,
+		"received":   u.received(),
+		"uploaded":   u.uploaded,
+		"percent":    math.Floor(float64(u.uploaded)*1000/float64(u.size)) / 10,
+		"complete":   u.uploaded == u.size,
+		"expires_at": expiresAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// uploadSessions holds the resumable uploads in progress. Sessions expire
+// after ttl without activity; the upload itself stays on the Storage API
+// until it expires there.
+type uploadSessions struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*uploadSession
+	lastUsed map[string]time.Time
+	// complete holds the IDs of the sessions whose upload has finished;
+	// they are kept for get_upload_status and cancel_upload until they
+	// expire
+	complete map[string]bool
+}
+
+// newUploadSessions creates an empty session store
+func newUploadSessions(ttl time.Duration) *uploadSessions {
+	return &uploadSessions{
+		ttl:      ttl,
+		sessions: make(map[string]*uploadSession),
+		lastUsed: make(map[string]time.Time),
+		complete: make(map[string]bool),
+	}
+}
+
+// add stores a new session under a random ID and returns when it expires
+func (s *uploadSessions) add(session *uploadSession) (time.Time, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return time.Time{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+	if len(s.sessions)-len(s.complete) >= maxUploadSessions {
+		return time.Time{}, errTooManyUploads
+	}
+
+	session.id = hex.EncodeToString(id)
+	s.sessions[session.id] = session
+	s.lastUsed[session.id] = now
+	return now.Add(s.ttl), nil
+}
+
+// get returns a session and extends its lifetime, or nil if it does not
+// exist or has expired
+func (s *uploadSessions) get(id string) (*uploadSession, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+	session := s.sessions[id]
+	if session == nil {
+		return nil, time.Time{}
+	}
+	s.lastUsed[id] = now
+	return session, now.Add(s.ttl)
+}
+
+// finish records that the upload of a session is complete, so that it no
+// longer counts towards maxUploadSessions
+func (s *uploadSessions) finish(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[id] != nil {
+		s.complete[id] = true
+	}
+}
+
+// remove forgets a session
+func (s *uploadSessions) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	delete(s.lastUsed, id)
+	delete(s.complete, id)
+}
+
+// expire drops the sessions idle for longer than ttl; s.mu must be held
+func (s *uploadSessions) expire(now time.Time) {
+	for id, lastUsed := range s.lastUsed {
+		if now.Sub(lastUsed) > s.ttl {
+			delete(s.sessions, id)
+			delete(s.lastUsed, id)
+			delete(s.complete, id)
+		}
+	}
+}
+
+// uploadSourcePath resolves a file under STORAGE_UPLOAD_DIR, refusing
+// paths that lead out of it
+func (sc *StorageController) uploadSourcePath(name string) (string, os.FileInfo, error) {
+	if sc.cfg.UploadDir == "" {
+		return "", nil, errUploadDirDisabled
+	}
+	root, err := filepath.EvalSymlinks(sc.cfg.UploadDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("STORAGE_UPLOAD_DIR: %v", err)
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		return "", nil, badRequest("source_file '%s' does not exist", name)
+	}
+	if err != nil {
+		return "", nil, badRequest("source_file: %v", err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", nil, badRequest("source_file must be inside STORAGE_UPLOAD_DIR")
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, badRequest("source_file: %v", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", nil, badRequest("source_file is not a regular file")
+	}
+	return resolved, info, nil
+}
+
+// StartResumableUploadRequest represents the request body for starting a resumable upload
+type StartResumableUploadRequest struct {
+	Bucket       string `json:"bucket" jsonschema:"required" description:"Bucket ID"`
+	Path         string `json:"path" jsonschema:"required" description:"Object path inside the bucket"`
+	Size         int64  `json:"size" jsonschema:"minimum=1" description:"Size of the file in bytes (required unless source_file is given)"`
+	SourceFile   string `json:"source_file" description:"File on the server host to upload, relative to STORAGE_UPLOAD_DIR (optional; without it the content is sent with upload_chunk)"`
+	ContentType  string `json:"content_type" description:"MIME type (optional, guessed from the extension)"`
+	CacheControl string `json:"cache_control" description:"Cache-Control header, e.g. max-age=3600 (optional)"`
+	Upsert       bool   `json:"upsert" description:"Replace the object if it exists (optional, defaults to false)"`
+}
+
+// StartResumableUpload starts a TUS upload and a session tracking it
+func (sc *StorageController) StartResumableUpload(c *gin.Context) {
+	var req StartResumableUploadRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID and path are required"})
+		return
+	}
+
+	session := &uploadSession{bucket: req.Bucket, path: req.Path, size: req.Size}
+	if req.SourceFile != "" {
+		path, info, err := sc.uploadSourcePath(req.SourceFile)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if req.Size > 0 && req.Size != info.Size() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("size is %d but %s has %d bytes", req.Size, req.SourceFile, info.Size())})
+			return
+		}
+		session.sourceFile = path
+		session.size = info.Size()
+	}
+	if session.size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size is required, and must be positive, unless source_file is given"})
+		return
+	}
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = guessContentType(req.Path, false)
+	}
+
+	uploadURL, err := sc.supabase.StorageAPI().CreateResumableUpload(c.Request.Context(), req.Bucket, req.Path, session.size, supabase.UploadOptions{
+		ContentType:  contentType,
+		CacheControl: req.CacheControl,
+		Upsert:       req.Upsert,
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+	session.url = uploadURL
+
+	expiresAt, err := sc.uploads.add(session)
+	if err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := session.progress(expiresAt)
+	response["content_type"] = contentType
+	if session.sourceFile != "" {
+		response["next"] = "Call continue_file_upload with the upload_id until complete is true"
+	} else {
+		response["max_chunk_size"] = sc.cfg.MaxUploadSize
+		response["next"] = "Send the content in order with upload_chunk, each chunk as base64 and at most max_chunk_size bytes"
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UploadChunkRequest represents the request body for sending a chunk of a resumable upload
+type UploadChunkRequest struct {
+	UploadID      string `json:"upload_id" jsonschema:"required" description:"ID returned by start_resumable_upload"`
+	ContentBase64 string `json:"content_base64" jsonschema:"required" description:"Next part of the file, encoded as base64"`
+	Offset        *int64 `json:"offset" jsonschema:"minimum=0" description:"Position of this chunk in the file; a chunk that does not start where the received bytes end is rejected with the position to resume from (optional)"`
+}
+
+// UploadChunk adds a chunk to a resumable upload. Chunks of any size are
+// accepted; they are sent on to the Storage API in chunks of the size it
+// requires.
+func (sc *StorageController) UploadChunk(c *gin.Context) {
+	var req UploadChunkRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, expiresAt := sc.uploads.get(req.UploadID)
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Upload '%s' not found or expired", req.UploadID)})
+		return
+	}
+	if session.sourceFile != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This upload reads a local file; use continue_file_upload"})
+		return
+	}
+
+	if size := decodedSize(req.ContentBase64); size > sc.cfg.MaxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Chunk is %d bytes; the limit is %d (STORAGE_MAX_UPLOAD_SIZE)", size, sc.cfg.MaxUploadSize)})
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(req.ContentBase64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("content_base64 is not valid base64: %v", err)})
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if req.Offset != nil && *req.Offset != session.received() {
+		response := session.progress(expiresAt)
+		response["error"] = fmt.Sprintf("Chunk starts at %d but %d bytes have been received; resume from offset %d", *req.Offset, session.received(), session.received())
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	if session.received()+int64(len(data)) > session.size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Chunk ends at %d, past the size of the file (%d bytes)", session.received()+int64(len(data)), session.size)})
+		return
+	}
+
+	session.pending = append(session.pending, data...)
+	if err := session.flush(c.Request.Context(), sc.supabase.StorageAPI()); err != nil {
+		// The chunk stays buffered; the next call retries sending it
+		response := apiError(err)
+		response["progress"] = session.progress(expiresAt)
+		c.JSON(errorStatus(err), response)
+		return
+	}
+	if session.uploaded == session.size {
+		sc.uploads.finish(session.id)
+	}
+
+	c.JSON(http.StatusOK, session.progress(expiresAt))
+}
+
+// ContinueFileUploadRequest represents the request body for continuing a resumable upload from a local file
+type ContinueFileUploadRequest struct {
+	UploadID string `json:"upload_id" jsonschema:"required" description:"ID returned by start_resumable_upload"`
+	MaxBytes int64  `json:"max_bytes" jsonschema:"minimum=1" description:"Stop after sending about this many bytes, to report progress along the way (optional, defaults to sending the whole file)"`
+}
+
+// ContinueFileUpload sends a local file from where its upload stopped
+func (sc *StorageController) ContinueFileUpload(c *gin.Context) {
+	var req ContinueFileUploadRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, expiresAt := sc.uploads.get(req.UploadID)
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Upload '%s' not found or expired", req.UploadID)})
+		return
+	}
+	if session.sourceFile == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This upload has no source file; send its content with upload_chunk"})
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	storage := sc.supabase.StorageAPI()
+
+	// A previous call may have been interrupted after the Storage API
+	// received a chunk
+	offset, err := storage.ResumableUploadOffset(c.Request.Context(), session.url)
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+	session.advance(offset)
+
+	if err := session.sendFile(c.Request.Context(), storage, req.MaxBytes); err != nil {
+		response := apiError(err)
+		response["progress"] = session.progress(expiresAt)
+		c.JSON(errorStatus(err), response)
+		return
+	}
+	if session.uploaded == session.size {
+		sc.uploads.finish(session.id)
+	}
+
+	c.JSON(http.StatusOK, session.progress(expiresAt))
+}
+
+// ResumableUploadRequest represents the request body for operations on a resumable upload
+type ResumableUploadRequest struct {
+	UploadID string `json:"upload_id" jsonschema:"required" description:"ID returned by start_resumable_upload"`
+}
+
+// GetUploadStatus reports the progress of a resumable upload, as
+// acknowledged by the Storage API
+func (sc *StorageController) GetUploadStatus(c *gin.Context) {
+	var req ResumableUploadRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, expiresAt := sc.uploads.get(req.UploadID)
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Upload '%s' not found or expired", req.UploadID)})
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	offset, err := sc.supabase.StorageAPI().ResumableUploadOffset(c.Request.Context(), session.url)
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+	session.advance(offset)
+	if session.uploaded == session.size {
+		sc.uploads.finish(session.id)
+	}
+
+	c.JSON(http.StatusOK, session.progress(expiresAt))
+}
+
+// CancelUpload discards a resumable upload
+func (sc *StorageController) CancelUpload(c *gin.Context) {
+	var req ResumableUploadRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, _ := sc.uploads.get(req.UploadID)
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Upload '%s' not found or expired", req.UploadID)})
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.uploaded == session.size {
+		sc.uploads.remove(session.id)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": fmt.Sprintf("Upload of '%s' was already complete; the object is kept", session.path),
+		})
+		return
+	}
+
+	// An upload that expired on the Storage API is already gone
+	err := sc.supabase.StorageAPI().CancelResumableUpload(c.Request.Context(), session.url)
+	var apiErr *supabase.APIError
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound) {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+	sc.uploads.remove(session.id)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Upload of '%s' cancelled", session.path),
+	})
+}
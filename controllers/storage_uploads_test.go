@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+	"github.com/gin-gonic/gin"
+)
+
+// flakyProxy forwards requests to the TUS stub. When failNext is set, the
+// next PATCH reaches the stub but its response is replaced by a 502, as if
+// the gateway had lost it.
+type flakyProxy struct {
+	proxy    *httputil.ReverseProxy
+	failNext atomic.Bool
+}
+
+func (p *flakyProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPatch && p.failNext.CompareAndSwap(true, false) {
+		p.proxy.ServeHTTP(httptest.NewRecorder(), r)
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"message": "upstream connection reset"}`))
+		return
+	}
+	p.proxy.ServeHTTP(w, r)
+}
+
+func callJSON(t *testing.T, handler gin.HandlerFunc, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	status, raw := callHandler(handler, body)
+	var response map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &response); err != nil {
+		t.Fatalf("invalid response %s: %v", raw, err)
+	}
+	return status, response
+}
+
+func TestResumableUploadThroughStub(t *testing.T) {
+	dir := t.TempDir()
+	stub, err := supabase.StartTUSStub(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stub.Close()
+
+	target, _ := url.Parse(stub.URL)
+	flaky := &flakyProxy{proxy: httputil.NewSingleHostReverseProxy(&url.URL{Scheme: target.Scheme, Host: target.Host})}
+	proxy := httptest.NewServer(flaky)
+	defer proxy.Close()
+
+	client := supabase.CreateClientExtended(proxy.URL, "service-key",
+		supabase.WithTUSEndpoint(proxy.URL+target.Path), supabase.WithRetries(0))
+	controller := NewStorageController(client, nil, config.StorageConfig{
+		MaxUploadSize:    4 << 20,
+		UploadSessionTTL: time.Hour,
+	})
+
+	const size = 10<<20 + 100
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i * 7 % 251)
+	}
+	chunk := func(from, to int, offset int) gin.H {
+		return gin.H{"content_base64": base64.StdEncoding.EncodeToString(content[from:to]), "offset": offset}
+	}
+
+	status, started := callJSON(t, controller.StartResumableUpload, gin.H{"bucket": "docs", "path": "big/file.bin", "size": size})
+	if status != http.StatusOK {
+		t.Fatalf("start_resumable_upload: status %d, %v", status, started)
+	}
+	uploadID := started["upload_id"]
+	send := func(body gin.H) (int, map[string]interface{}) {
+		body["upload_id"] = uploadID
+		return callJSON(t, controller.UploadChunk, body)
+	}
+
+	// 4 MiB stays buffered: the Storage API takes 6 MiB chunks
+	status, progress := send(chunk(0, 4<<20, 0))
+	if status != http.StatusOK || progress["uploaded"] != 0.0 || progress["received"] != float64(4<<20) {
+		t.Fatalf("first chunk: status %d, %v", status, progress)
+	}
+
+	// Crossing 6 MiB sends a chunk; its response is lost, and the upload
+	// resyncs with the offset the stub reports
+	flaky.failNext.Store(true)
+	status, failed := send(chunk(4<<20, 8<<20, 4<<20))
+	if status != http.StatusBadGateway {
+		t.Fatalf("second chunk with a lost response: status %d, %v", status, failed)
+	}
+	progress, _ = failed["progress"].(map[string]interface{})
+	if progress["uploaded"] != float64(6<<20) || progress["received"] != float64(8<<20) {
+		t.Fatalf("progress after the failure: %v", progress)
+	}
+
+	// A chunk sent again from an old offset is refused with the offset to
+	// resume from
+	status, conflict := send(chunk(4<<20, 8<<20, 4<<20))
+	if status != http.StatusConflict || conflict["received"] != float64(8<<20) {
+		t.Fatalf("resent chunk: status %d, %v", status, conflict)
+	}
+
+	status, progress = callJSON(t, controller.GetUploadStatus, gin.H{"upload_id": uploadID})
+	if status != http.StatusOK || progress["uploaded"] != float64(6<<20) || progress["complete"] != false {
+		t.Fatalf("get_upload_status: status %d, %v", status, progress)
+	}
+
+	// The rest completes the upload
+	status, progress = send(chunk(8<<20, size, 8<<20))
+	if status != http.StatusOK || progress["complete"] != true || progress["uploaded"] != float64(size) {
+		t.Fatalf("last chunk: status %d, %v", status, progress)
+	}
+
+	uploaded, err := os.ReadFile(filepath.Join(dir, "docs", "big", "file.bin"))
+	if err != nil || !bytes.Equal(uploaded, content) {
+		t.Fatalf("uploaded file differs from the content sent (%d of %d bytes, %v)", len(uploaded), len(content), err)
+	}
+
+	// A finished upload accepts no more data
+	status, _ = send(gin.H{"content_base64": base64.StdEncoding.EncodeToString([]byte("x"))})
+	if status != http.StatusBadRequest {
+		t.Errorf("chunk after completion: status %d, want 400", status)
+	}
+
+	status, cancelled := callJSON(t, controller.CancelUpload, gin.H{"upload_id": uploadID})
+	if status != http.StatusOK {
+		t.Errorf("cancel_upload after completion: status %d, %v", status, cancelled)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs", "big", "file.bin")); err != nil {
+		t.Errorf("cancel_upload removed the finished object: %v", err)
+	}
+}
+
+func TestCompletedUploadsFreeTheirSession(t *testing.T) {
+	stub, err := supabase.StartTUSStub(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stub.Close()
+
+	target, _ := url.Parse(stub.URL)
+	client := supabase.CreateClientExtended(target.Scheme+"://"+target.Host, "service-key",
+		supabase.WithTUSEndpoint(stub.URL), supabase.WithRetries(0))
+	controller := NewStorageController(client, nil, config.StorageConfig{
+		MaxUploadSize:    1 << 20,
+		UploadSessionTTL: time.Hour,
+	})
+
+	content := base64.StdEncoding.EncodeToString([]byte("hello"))
+	for i := 0; i < 2*maxUploadSessions; i++ {
+		status, started := callJSON(t, controller.StartResumableUpload, gin.H{"bucket": "docs", "path": "notes/" + strconv.Itoa(i) + ".txt", "size": 5})
+		if status != http.StatusOK {
+			t.Fatalf("start_resumable_upload %d: status %d, %v", i+1, status, started)
+		}
+		status, progress := callJSON(t, controller.UploadChunk, gin.H{"upload_id": started["upload_id"], "content_base64": content})
+		if status != http.StatusOK || progress["complete"] != true {
+			t.Fatalf("upload_chunk %d: status %d, %v", i+1, status, progress)
+		}
+	}
+
+	// Uploads left incomplete still count
+	for i := 0; i < maxUploadSessions; i++ {
+		if status, started := callJSON(t, controller.StartResumableUpload, gin.H{"bucket": "docs", "path": "pending.txt", "size": 5}); status != http.StatusOK {
+			t.Fatalf("incomplete upload %d: status %d, %v", i+1, status, started)
+		}
+	}
+	if status, started := callJSON(t, controller.StartResumableUpload, gin.H{"bucket": "docs", "path": "pending.txt", "size": 5}); status != http.StatusTooManyRequests {
+		t.Errorf("upload past the limit: status %d, %v, want 429", status, started)
+	}
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+	"github.com/gin-gonic/gin"
+)
+
+// signedUploadTTL is how long the Storage API keeps signed upload URLs valid
+const signedUploadTTL = 7200
+
+// ImageTransform represents image transform options for storage URLs
+type ImageTransform struct {
+	Width   int    `json:"width" jsonschema:"minimum=1,maximum=2500" description:"Width in pixels (optional)"`
+	Height  int    `json:"height" jsonschema:"minimum=1,maximum=2500" description:"Height in pixels (optional)"`
+	Resize  string `json:"resize" jsonschema:"enum=cover|contain|fill" description:"How the image fits the width and height (optional, defaults to cover)"`
+	Quality int    `json:"quality" jsonschema:"minimum=20,maximum=100" description:"Quality of the output, 20 to 100 (optional, defaults to 80)"`
+	Format  string `json:"format" jsonschema:"enum=origin" description:"origin keeps the original format instead of converting to WebP (optional)"`
+}
+
+// transform converts the options to the Storage API form; nil means no
+// transform
+func (t *ImageTransform) transform() *supabase.ImageTransform {
+	if t == nil {
+		return nil
+	}
+	return &supabase.ImageTransform{
+		Width:   t.Width,
+		Height:  t.Height,
+		Resize:  t.Resize,
+		Quality: t.Quality,
+		Format:  t.Format,
+	}
+}
+
+// CreateSignedURLRequest represents the request body for creating a signed download URL
+type CreateSignedURLRequest struct {
+	Bucket       string          `json:"bucket" jsonschema:"required" description:"Bucket ID"`
+	Path         string          `json:"path" jsonschema:"required" description:"Object path inside the bucket"`
+	ExpiresIn    int             `json:"expires_in" jsonschema:"default=3600,minimum=1" description:"Seconds until the URL expires (optional, defaults to 3600)"`
+	Transform    *ImageTransform `json:"transform" description:"Resize or convert an image as it is served (optional, requires image transformation to be enabled)"`
+	Download     bool            `json:"download" description:"Make browsers save the file instead of displaying it (optional)"`
+	DownloadName string          `json:"download_name" description:"File name to save the download as (optional, implies download)"`
+}
+
+// CreateSignedURL creates a URL that grants temporary read access to an object
+func (sc *StorageController) CreateSignedURL(c *gin.Context) {
+	var req CreateSignedURLRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID and path are required"})
+		return
+	}
+	if req.ExpiresIn <= 0 {
+		req.ExpiresIn = 3600
+	}
+
+	ref, err := sc.supabase.StorageAPI().CreateSignedURL(c.Request.Context(), req.Bucket, req.Path, req.ExpiresIn, req.Transform.transform())
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	link, err := supabase.StorageURL(sc.cfg.PublicURL, ref)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Storage API returned an invalid URL: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"signed_url": withDownload(link, req.Download, req.DownloadName),
+		"expires_in": req.ExpiresIn,
+	})
+}
+
+// CreateSignedUploadURLRequest represents the request body for creating a signed upload URL
+type CreateSignedUploadURLRequest struct {
+	Bucket string `json:"bucket" jsonschema:"required" description:"Bucket ID"`
+	Path   string `json:"path" jsonschema:"required" description:"Object path the file will be uploaded to"`
+	Upsert bool   `json:"upsert" description:"Allow the upload to replace an existing object (optional, defaults to false)"`
+}
+
+// CreateSignedUploadURL creates a URL that a client can upload a file to
+// without credentials
+func (sc *StorageController) CreateSignedUploadURL(c *gin.Context) {
+	var req CreateSignedUploadURLRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID and path are required"})
+		return
+	}
+
+	upload, err := sc.supabase.StorageAPI().CreateSignedUploadURL(c.Request.Context(), req.Bucket, req.Path, req.Upsert)
+	if err != nil {
+		c.JSON(errorStatus(err), apiError(err))
+		return
+	}
+
+	link, err := supabase.StorageURL(sc.cfg.PublicURL, upload.URL)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Storage API returned an invalid URL: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"signed_url": link,
+		"token":      upload.Token,
+		"path":       req.Path,
+		"expires_in": signedUploadTTL,
+		"usage":      "PUT the file to signed_url with its Content-Type header; no other credentials are needed",
+	})
+}
+
+// GetPublicURLRequest represents the request body for getting the public URL of an object
+type GetPublicURLRequest struct {
+	Bucket       string          `json:"bucket" jsonschema:"required" description:"Bucket ID (the bucket must be public)"`
+	Path         string          `json:"path" jsonschema:"required" description:"Object path inside the bucket"`
+	Transform    *ImageTransform `json:"transform" description:"Resize or convert an image as it is served (optional, requires image transformation to be enabled)"`
+	Download     bool            `json:"download" description:"Make browsers save the file instead of displaying it (optional)"`
+	DownloadName string          `json:"download_name" description:"File name to save the download as (optional, implies download)"`
+}
+
+// GetPublicURL returns the permanent URL of an object in a public bucket
+func (sc *StorageController) GetPublicURL(c *gin.Context) {
+	var req GetPublicURLRequest
+	if err := bindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Bucket == "" || req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket ID and path are required"})
+		return
+	}
+
+	bucket, err := sc.findBucket(c.Request.Context(), req.Bucket)
+	if err != nil {
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
+	if bucket == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Bucket '%s' not found", req.Bucket)})
+		return
+	}
+	if public, _ := bucket["public"].(bool); !public {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Bucket '%s' is private; its objects have no public URL, use create_signed_url instead", req.Bucket)})
+		return
+	}
+
+	link := supabase.PublicObjectURL(sc.cfg.PublicURL, req.Bucket, req.Path, req.Transform.transform())
+
+	c.JSON(http.StatusOK, gin.H{
+		"public_url": withDownload(link, req.Download, req.DownloadName),
+	})
+}
+
+// withDownload adds the download parameter, which makes the Storage API
+// send the object as an attachment
+func withDownload(link string, download bool, name string) string {
+	if !download && name == "" {
+		return link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	query := u.Query()
+	query.Set("download", name)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
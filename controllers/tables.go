@@ -3,8 +3,10 @@ package controllers
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
 	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
 	"github.com/dirgocs/supabase-self-hosted-mcp/utils"
 	"github.com/gin-gonic/gin"
@@ -12,35 +14,41 @@ import (
 
 // TableController handles table-related operations
 type TableController struct {
-	supabase *supabase.SupabaseClientExtended
+	supabase     *supabase.SupabaseClientExtended
+	db           database.Executor
+	impersonator *Impersonator
 }
 
 // NewTableController creates a new table controller
-func NewTableController(client *supabase.SupabaseClientExtended) *TableController {
+func NewTableController(client *supabase.SupabaseClientExtended, db database.Executor, impersonator *Impersonator) *TableController {
 	return &TableController{
-		supabase: client,
+		supabase:     client,
+		db:           db,
+		impersonator: impersonator,
 	}
 }
 
-// WhereCondition represents a where condition for table queries
-type WhereCondition struct {
-	Column   string      `json:"column"`
-	Operator string      `json:"operator"`
-	Value    interface{} `json:"value"`
-}
-
 // QueryTableRequest represents the request body for querying a table
 type QueryTableRequest struct {
-	Schema string           `json:"schema"`
-	Table  string           `json:"table"`
-	Select string           `json:"select"`
-	Where  []WhereCondition `json:"where"`
+	Schema      string           `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
+	Table       string           `json:"table" jsonschema:"required" description:"Name of the table to query"`
+	Select      string           `json:"select" jsonschema:"default=*" description:"Comma-separated list of columns to select (optional, defaults to *)"`
+	Where       []WhereCondition `json:"where" description:"Array of where conditions (optional)"`
+	Embed       []Embed          `json:"embed" description:"Related tables to embed in each row through their foreign keys (optional)"`
+	Order       []OrderBy        `json:"order" description:"Columns to order by; end with a unique column for stable keyset pagination (optional)"`
+	Limit       int              `json:"limit" jsonschema:"default=100,minimum=1,maximum=1000" description:"Maximum number of rows to return (optional, defaults to 100)"`
+	Offset      int              `json:"offset" jsonschema:"minimum=0" description:"Number of rows to skip (optional, cannot be combined with cursor)"`
+	Cursor      string           `json:"cursor" description:"next_cursor from a previous call with the same filters and order; count then covers the remaining rows (optional)"`
+	Count       string           `json:"count" jsonschema:"enum=exact|planned|estimated|none,default=exact" description:"How to count the matching rows (optional, defaults to exact)"`
+	Impersonate *Impersonation   `json:"impersonate" description:"Run the query as an end user, with row level security (optional, defaults to the service role)"`
 }
 
-// QueryTable queries a specific table with filters
+// QueryTable queries a specific table with filters, ordering and pagination.
+// The response holds the rows, the total count of matching rows and a
+// cursor for the next page.
 func (tc *TableController) QueryTable(c *gin.Context) {
 	var req QueryTableRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -57,90 +65,116 @@ func (tc *TableController) QueryTable(c *gin.Context) {
 	if req.Select == "" {
 		req.Select = "*"
 	}
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+	if req.Count == "" {
+		req.Count = "exact"
+	}
 
 	// Build the query
-	query := tc.supabase.DB.From(req.Table).Select(req.Select)
-
-	// Apply where conditions
-	for _, condition := range req.Where {
-		switch condition.Operator {
-		case "eq":
-			if strValue, ok := condition.Value.(string); ok {
-				query.Eq(condition.Column, strValue)
-			} else {
-				query.Eq(condition.Column, fmt.Sprintf("%v", condition.Value))
-			}
-		case "neq":
-			if strValue, ok := condition.Value.(string); ok {
-				query.Neq(condition.Column, strValue)
-			} else {
-				query.Neq(condition.Column, fmt.Sprintf("%v", condition.Value))
-			}
-		case "gt":
-			if strValue, ok := condition.Value.(string); ok {
-				query.Gt(condition.Column, strValue)
-			} else {
-				query.Gt(condition.Column, fmt.Sprintf("%v", condition.Value))
-			}
-		case "gte":
-			if strValue, ok := condition.Value.(string); ok {
-				query.Gte(condition.Column, strValue)
-			} else {
-				query.Gte(condition.Column, fmt.Sprintf("%v", condition.Value))
-			}
-		case "lt":
-			if strValue, ok := condition.Value.(string); ok {
-				query.Lt(condition.Column, strValue)
-			} else {
-				query.Lt(condition.Column, fmt.Sprintf("%v", condition.Value))
-			}
-		case "lte":
-			if strValue, ok := condition.Value.(string); ok {
-				query.Lte(condition.Column, strValue)
-			} else {
-				query.Lte(condition.Column, fmt.Sprintf("%v", condition.Value))
-			}
-		case "like":
-			if strValue, ok := condition.Value.(string); ok {
-				query.Like(condition.Column, strValue)
-			} else {
-				query.Like(condition.Column, fmt.Sprintf("%v", condition.Value))
-			}
-		case "ilike":
-			if strValue, ok := condition.Value.(string); ok {
-				query.Ilike(condition.Column, strValue)
-			} else {
-				query.Ilike(condition.Column, fmt.Sprintf("%v", condition.Value))
+	query := tc.supabase.Rest(req.Table).Schema(req.Schema)
+	if req.Impersonate != nil {
+		token, _, err := tc.impersonator.userToken(req.Impersonate)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		query.AsUser(token)
+	}
+
+	if len(req.Embed) > 0 {
+		fks, err := loadForeignKeys(c.Request.Context(), tc.db, req.Schema)
+		if err != nil {
+			c.JSON(errorStatus(err), sqlError(err))
+			return
+		}
+
+		embeds, err := compileEmbeds(query, fks, req.Table, "", req.Embed)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.Select = strings.Join(append([]string{req.Select}, embeds...), ",")
+	}
+	query.Select(req.Select)
+
+	if err := applyWhere(query, "", req.Where); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderKey, err := orderParam(req.Order)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if orderKey != "" {
+		query.Param("order", orderKey)
+	}
+
+	if req.Cursor != "" {
+		if req.Offset != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset cannot be combined with cursor"})
+			return
+		}
+
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if cursor.After != nil {
+			if cursor.Order != orderKey {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "cursor was issued for a different order"})
+				return
 			}
-		case "is":
-			// Handle IS operator with proper type conversion
-			if condition.Value == nil {
-				// For IS NULL, use an empty string as a workaround
-				query.Is(condition.Column, "")
-			} else if strValue, ok := condition.Value.(string); ok {
-				query.Is(condition.Column, strValue)
-			} else {
-				query.Is(condition.Column, fmt.Sprintf("%v", condition.Value))
+			if err := applyKeyset(query, req.Order, cursor.After); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
 			}
-		default:
-			// Ignore unknown operators
+		} else {
+			req.Offset = cursor.Offset
 		}
 	}
 
-	var result []map[string]interface{}
-	err := query.Execute(&result)
+	query.Param("limit", strconv.Itoa(req.Limit))
+	if req.Offset > 0 {
+		query.Param("offset", strconv.Itoa(req.Offset))
+	}
 
+	switch req.Count {
+	case "exact", "planned", "estimated":
+		query.Prefer("count=" + req.Count)
+	case "none":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "count must be exact, planned, estimated or none"})
+		return
+	}
+
+	rows := []map[string]interface{}{}
+	resp, err := query.Execute(c.Request.Context(), &rows)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), apiError(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	var count *int64
+	if req.Count != "none" {
+		count = parseContentRange(resp.Header.Get("Content-Range"))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rows":        rows,
+		"count":       count,
+		"next_cursor": nextCursor(rows, req.Limit, req.Offset, req.Order, orderKey),
+	})
 }
 
 // GenerateTypesRequest represents the request body for generating types
 type GenerateTypesRequest struct {
-	Schema string `json:"schema"`
+	Schema string `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
 }
 
 // SchemaColumn represents a column in the database schema
@@ -159,7 +193,7 @@ type SchemaTable struct {
 // GenerateTypes generates TypeScript types for a schema
 func (tc *TableController) GenerateTypes(c *gin.Context) {
 	var req GenerateTypesRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -169,48 +203,37 @@ func (tc *TableController) GenerateTypes(c *gin.Context) {
 		req.Schema = "public"
 	}
 
-	// Try to get schema information using RPC
-	var tables []SchemaTable
-	err := tc.supabase.Functions().Invoke("get_schema_information", map[string]interface{}{
-		"p_schema": req.Schema,
-	}, &tables)
-
+	query := `
+		SELECT 
+			c.table_name,
+			json_agg(json_build_object(
+				'column_name', c.column_name,
+				'data_type', c.data_type,
+				'is_nullable', c.is_nullable
+			) ORDER BY c.ordinal_position) AS columns
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON (
+			t.table_schema = c.table_schema AND 
+			t.table_name = c.table_name
+		)
+		WHERE c.table_schema = $1 AND t.table_type = 'BASE TABLE'
+		GROUP BY c.table_name
+		ORDER BY c.table_name
+	`
+
+	result, err := tc.db.Query(c.Request.Context(), query, req.Schema)
 	if err != nil {
-		// If RPC fails, try direct query
-		var schemaData []map[string]interface{}
-		// Use the DB object to execute a SQL query
-		query := fmt.Sprintf(`SELECT tablename FROM pg_tables WHERE schemaname = '%s'`, req.Schema)
-		err = tc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-			"query": query,
-		}, &schemaData)
-
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":   err.Error(),
-				"message": "Unable to generate types. You may need to create a custom function to retrieve schema information in your Supabase instance.",
-			})
-			return
-		}
-
-		// Build simple types based on tables found
-		var typesOutput strings.Builder
-		typesOutput.WriteString(fmt.Sprintf("// TypeScript types for schema: %s\n\n", req.Schema))
-
-		for _, tableInfo := range schemaData {
-			tableName := tableInfo["tablename"].(string)
-
-			typesOutput.WriteString(fmt.Sprintf("export interface %s {\n", utils.ToPascalCase(tableName)))
-			typesOutput.WriteString("  // Add your column definitions here\n")
-			typesOutput.WriteString("  id: string; // Assuming primary key\n")
-			typesOutput.WriteString("  created_at?: string; // Common timestamp field\n")
-			typesOutput.WriteString("}\n\n")
-		}
+		c.JSON(errorStatus(err), sqlError(err))
+		return
+	}
 
-		c.JSON(http.StatusOK, gin.H{"types": typesOutput.String()})
+	var tables []SchemaTable
+	if err := result.Decode(&tables); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// If RPC was successful, build detailed types
+	// Build types from the column information
 	var typesOutput strings.Builder
 	typesOutput.WriteString(fmt.Sprintf("// TypeScript types for schema: %s\n\n", req.Schema))
 
@@ -256,13 +279,13 @@ func (tc *TableController) GenerateTypes(c *gin.Context) {
 
 // ListTablesRequest represents the request body for listing tables
 type ListTablesRequest struct {
-	Schema string `json:"schema"`
+	Schema string `json:"schema" jsonschema:"default=public" description:"Database schema (optional, defaults to public)"`
 }
 
 // ListTables lists tables in a schema
 func (tc *TableController) ListTables(c *gin.Context) {
 	var req ListTablesRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -272,83 +295,94 @@ func (tc *TableController) ListTables(c *gin.Context) {
 		req.Schema = "public"
 	}
 
-	// Try using RPC first
-	var tables []map[string]interface{}
-	err := tc.supabase.Functions().Invoke("list_tables", map[string]interface{}{
-		"p_schema": req.Schema,
-	}, &tables)
-
-	if err == nil && tables != nil {
-		c.JSON(http.StatusOK, tables)
-		return
-	}
-
-	// Alternative: direct query
-	query := fmt.Sprintf(`
+	query := `
 		SELECT tablename AS table_name 
 		FROM pg_tables 
-		WHERE schemaname = '%s'
-	`, req.Schema)
-
-	var queryResult []map[string]interface{}
-	err = tc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": query,
-	}, &queryResult)
+		WHERE schemaname = $1
+		ORDER BY tablename
+	`
 
+	result, err := tc.db.Query(c.Request.Context(), query, req.Schema)
 	if err != nil {
-		// If RPC also fails, try checking common tables
-		var commonTables []map[string]string
-		commonTableNames := []string{"profiles", "products", "users", "categories", "orders", "items"}
-
-		for _, tableName := range commonTableNames {
-			// Use a simple query to check if the table exists
-			query := fmt.Sprintf("SELECT * FROM %s LIMIT 1", tableName)
-			var result []map[string]interface{}
-			err := tc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-				"query": query,
-			}, &result)
-
-			if err == nil {
-				commonTables = append(commonTables, map[string]string{"table_name": tableName})
-			}
-		}
-
-		c.JSON(http.StatusOK, commonTables)
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, queryResult)
+	c.JSON(http.StatusOK, result.Rows)
 }
 
 // Column represents a column definition for table creation
 type Column struct {
-	Name         string     `json:"name"`
-	Type         string     `json:"type"`
-	Nullable     *bool      `json:"nullable"`
-	DefaultValue string     `json:"default_value"`
-	PrimaryKey   bool       `json:"primary_key"`
-	Unique       bool       `json:"unique"`
-	References   *Reference `json:"references"`
+	Name         string     `json:"name" jsonschema:"required" description:"Column name"`
+	Type         string     `json:"type" jsonschema:"required" description:"Column data type"`
+	Nullable     *bool      `json:"nullable" description:"Whether the column can be null (optional, defaults to true)"`
+	DefaultValue string     `json:"default_value" description:"Default value (optional)"`
+	PrimaryKey   bool       `json:"primary_key" description:"Whether the column is a primary key (optional, defaults to false)"`
+	Unique       bool       `json:"unique" description:"Whether the column value must be unique (optional, defaults to false)"`
+	References   *Reference `json:"references" description:"Foreign key reference (optional)"`
 }
 
 // Reference represents a foreign key reference
 type Reference struct {
-	Table  string `json:"table"`
-	Column string `json:"column"`
+	Table  string `json:"table" jsonschema:"required" description:"Referenced table"`
+	Column string `json:"column" jsonschema:"required" description:"Referenced column"`
+}
+
+// definition renders the column for CREATE TABLE or ADD COLUMN. Names are
+// quoted, while the type and default value are checked against the
+// allowlists in the database package since DDL cannot bind parameters.
+func (column Column) definition() (string, error) {
+	columnType, err := database.ValidateType(column.Type)
+	if err != nil {
+		return "", fmt.Errorf("column %q: %v", column.Name, err)
+	}
+
+	def := fmt.Sprintf("%s %s", database.QuoteIdent(column.Name), columnType)
+
+	// Not null
+	if column.Nullable != nil && !*column.Nullable {
+		def += " NOT NULL"
+	}
+
+	// Default value
+	if column.DefaultValue != "" {
+		defaultValue, err := database.ValidateDefault(column.DefaultValue)
+		if err != nil {
+			return "", fmt.Errorf("column %q: %v", column.Name, err)
+		}
+		def += " DEFAULT " + defaultValue
+	}
+
+	// Primary key
+	if column.PrimaryKey {
+		def += " PRIMARY KEY"
+	}
+
+	// Unique
+	if column.Unique {
+		def += " UNIQUE"
+	}
+
+	// References (foreign key)
+	if column.References != nil {
+		def += fmt.Sprintf(" REFERENCES %s (%s)", database.QuoteIdent(column.References.Table), database.QuoteIdent(column.References.Column))
+	}
+
+	return def, nil
 }
 
 // CreateTableRequest represents the request body for creating a table
 type CreateTableRequest struct {
-	Schema    string   `json:"schema"`
-	Name      string   `json:"name"`
-	Columns   []Column `json:"columns"`
-	EnableRLS bool     `json:"enable_rls"`
+	Schema    string   `json:"schema" jsonschema:"default=public" description:"Schema name (optional, defaults to public)"`
+	Name      string   `json:"name" jsonschema:"required" description:"Table name"`
+	Columns   []Column `json:"columns" jsonschema:"required,minItems=1" description:"Table columns"`
+	EnableRLS bool     `json:"enable_rls" description:"Whether to enable RLS on the table (optional, defaults to false)"`
 }
 
 // CreateTable creates a new table
 func (tc *TableController) CreateTable(c *gin.Context) {
 	var req CreateTableRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -363,7 +397,7 @@ func (tc *TableController) CreateTable(c *gin.Context) {
 		req.Schema = "public"
 	}
 
-	tableIdentifier := fmt.Sprintf(`"%s"."%s"`, req.Schema, req.Name)
+	tableIdentifier := database.QualifiedName(req.Schema, req.Name)
 
 	var sql strings.Builder
 	sql.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", tableIdentifier))
@@ -371,34 +405,13 @@ func (tc *TableController) CreateTable(c *gin.Context) {
 	// Add columns
 	var columnDefinitions []string
 	for _, column := range req.Columns {
-		def := fmt.Sprintf(`  "%s" %s`, column.Name, column.Type)
-
-		// Not null
-		if column.Nullable != nil && !*column.Nullable {
-			def += " NOT NULL"
-		}
-
-		// Default value
-		if column.DefaultValue != "" {
-			def += fmt.Sprintf(" DEFAULT %s", column.DefaultValue)
-		}
-
-		// Primary key
-		if column.PrimaryKey {
-			def += " PRIMARY KEY"
-		}
-
-		// Unique
-		if column.Unique {
-			def += " UNIQUE"
-		}
-
-		// References (foreign key)
-		if column.References != nil {
-			def += fmt.Sprintf(` REFERENCES "%s" ("%s")`, column.References.Table, column.References.Column)
+		def, err := column.definition()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		columnDefinitions = append(columnDefinitions, def)
+		columnDefinitions = append(columnDefinitions, "  "+def)
 	}
 
 	sql.WriteString(strings.Join(columnDefinitions, ",\n"))
@@ -409,13 +422,10 @@ func (tc *TableController) CreateTable(c *gin.Context) {
 		sql.WriteString(fmt.Sprintf(";\nALTER TABLE %s ENABLE ROW LEVEL SECURITY", tableIdentifier))
 	}
 
-	var result interface{}
-	err := tc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql.String(),
-	}, &result)
+	_, err := tc.db.Query(c.Request.Context(), sql.String())
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
@@ -427,12 +437,12 @@ func (tc *TableController) CreateTable(c *gin.Context) {
 
 // AlterTableRequest represents the request body for altering a table
 type AlterTableRequest struct {
-	Schema      string   `json:"schema"`
-	Name        string   `json:"name"`
-	NewName     string   `json:"new_name"`
-	AddColumns  []Column `json:"add_columns"`
-	DropColumns []string `json:"drop_columns"`
-	EnableRLS   *bool    `json:"enable_rls"`
+	Schema      string   `json:"schema" jsonschema:"default=public" description:"Schema name (optional, defaults to public)"`
+	Name        string   `json:"name" jsonschema:"required" description:"Table name"`
+	NewName     string   `json:"new_name" description:"New table name (optional)"`
+	AddColumns  []Column `json:"add_columns" description:"Columns to add (optional)"`
+	DropColumns []string `json:"drop_columns" description:"Columns to drop (optional)"`
+	EnableRLS   *bool    `json:"enable_rls" description:"Whether to enable RLS on the table (optional)"`
 }
 
 // OperationResult represents the result of a database operation
@@ -445,7 +455,7 @@ type OperationResult struct {
 // AlterTable alters an existing table
 func (tc *TableController) AlterTable(c *gin.Context) {
 	var req AlterTableRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -460,35 +470,31 @@ func (tc *TableController) AlterTable(c *gin.Context) {
 		req.Schema = "public"
 	}
 
-	tableIdentifier := fmt.Sprintf(`"%s"."%s"`, req.Schema, req.Name)
+	tableIdentifier := database.QualifiedName(req.Schema, req.Name)
 	var sqls []string
 
 	// Rename table
 	if req.NewName != "" {
-		sqls = append(sqls, fmt.Sprintf(`ALTER TABLE %s RENAME TO "%s"`, tableIdentifier, req.NewName))
+		sqls = append(sqls, fmt.Sprintf(`ALTER TABLE %s RENAME TO %s`, tableIdentifier, database.QuoteIdent(req.NewName)))
 	}
 
 	// Add columns
 	if len(req.AddColumns) > 0 {
 		for _, column := range req.AddColumns {
-			sql := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN "%s" %s`, tableIdentifier, column.Name, column.Type)
-
-			if column.Nullable != nil && !*column.Nullable {
-				sql += " NOT NULL"
+			def, err := column.definition()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
 			}
 
-			if column.DefaultValue != "" {
-				sql += fmt.Sprintf(" DEFAULT %s", column.DefaultValue)
-			}
-
-			sqls = append(sqls, sql)
+			sqls = append(sqls, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s`, tableIdentifier, def))
 		}
 	}
 
 	// Drop columns
 	if len(req.DropColumns) > 0 {
 		for _, columnName := range req.DropColumns {
-			sqls = append(sqls, fmt.Sprintf(`ALTER TABLE %s DROP COLUMN "%s"`, tableIdentifier, columnName))
+			sqls = append(sqls, fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, tableIdentifier, database.QuoteIdent(columnName)))
 		}
 	}
 
@@ -505,10 +511,7 @@ func (tc *TableController) AlterTable(c *gin.Context) {
 	var results []OperationResult
 
 	for _, sql := range sqls {
-		var result interface{}
-		err := tc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-			"query": sql,
-		}, &result)
+		_, err := tc.db.Query(c.Request.Context(), sql)
 
 		if err != nil {
 			results = append(results, OperationResult{
@@ -547,15 +550,15 @@ func (tc *TableController) AlterTable(c *gin.Context) {
 
 // DropTableRequest represents the request body for dropping a table
 type DropTableRequest struct {
-	Schema  string `json:"schema"`
-	Name    string `json:"name"`
-	Cascade bool   `json:"cascade"`
+	Schema  string `json:"schema" jsonschema:"default=public" description:"Schema name (optional, defaults to public)"`
+	Name    string `json:"name" jsonschema:"required" description:"Table name"`
+	Cascade bool   `json:"cascade" description:"Whether to cascade the deletion (optional, defaults to false)"`
 }
 
 // DropTable drops a table
 func (tc *TableController) DropTable(c *gin.Context) {
 	var req DropTableRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -570,20 +573,17 @@ func (tc *TableController) DropTable(c *gin.Context) {
 		req.Schema = "public"
 	}
 
-	tableIdentifier := fmt.Sprintf(`"%s"."%s"`, req.Schema, req.Name)
+	tableIdentifier := database.QualifiedName(req.Schema, req.Name)
 	sql := fmt.Sprintf(`DROP TABLE IF EXISTS %s`, tableIdentifier)
 
 	if req.Cascade {
 		sql += ` CASCADE`
 	}
 
-	var result interface{}
-	err := tc.supabase.Functions().Invoke("execute_sql", map[string]interface{}{
-		"query": sql,
-	}, &result)
+	_, err := tc.db.Query(c.Request.Context(), sql)
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), sqlError(err))
 		return
 	}
 
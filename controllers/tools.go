@@ -0,0 +1,361 @@
+package controllers
+
+import (
+	"log"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/dirgocs/supabase-self-hosted-mcp/mcp"
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+)
+
+// RegisterTools registers every controller operation with the tool registry.
+// The input schema of each tool is derived from its request struct.
+func RegisterTools(registry *mcp.Registry, cfg *config.Config, client *supabase.SupabaseClientExtended, db database.Executor) {
+	impersonator := NewImpersonator(cfg)
+	dbController := NewDatabaseController(client, db, cfg.SQL, impersonator)
+	tableController := NewTableController(client, db, impersonator)
+	storageController := NewStorageController(client, db, cfg.Storage)
+	edgeFunctionsController := NewEdgeFunctionsController(client, db)
+
+	tools := []mcp.Tool{
+		// Tables and queries
+		{
+			Name:        "query_table",
+			Description: "Query a specific table with schema selection, where clauses, ordering and pagination. Returns rows, the total count and a next_cursor for the following page",
+			Request:     QueryTableRequest{},
+			Handler:     tableController.QueryTable,
+		},
+		{
+			Name:        "insert_rows",
+			Description: "Insert one or more rows into a table and return them",
+			Request:     InsertRowsRequest{},
+			Handler:     tableController.InsertRows,
+		},
+		{
+			Name:        "upsert_rows",
+			Description: "Insert rows into a table, updating the rows that conflict on the on_conflict columns",
+			Request:     UpsertRowsRequest{},
+			Handler:     tableController.UpsertRows,
+		},
+		{
+			Name:        "update_rows",
+			Description: "Update the rows matching the where conditions and return them (updating every row requires force)",
+			Request:     UpdateRowsRequest{},
+			Handler:     tableController.UpdateRows,
+		},
+		{
+			Name:        "delete_rows",
+			Description: "Delete the rows matching the where conditions and return them (deleting every row requires force)",
+			Request:     DeleteRowsRequest{},
+			Handler:     tableController.DeleteRows,
+		},
+		{
+			Name:        "generate_types",
+			Description: "Generate TypeScript types for your Supabase database schema",
+			Request:     GenerateTypesRequest{},
+			Handler:     tableController.GenerateTypes,
+		},
+		{
+			Name:        "list_tables",
+			Description: "List all tables in a specific schema",
+			Request:     ListTablesRequest{},
+			Handler:     tableController.ListTables,
+		},
+		{
+			Name:        "execute_query",
+			Description: "Execute a read-only SQL query in a READ ONLY transaction",
+			Request:     ExecuteQueryRequest{},
+			Handler:     dbController.ExecuteQuery,
+			AnySchema:   true,
+		},
+
+		// Database functions
+		{
+			Name:        "list_rpc_functions",
+			Description: "List the database functions of a schema with their arguments, return type, volatility and security definer flag",
+			Request:     ListRPCFunctionsRequest{},
+			Handler:     dbController.ListRPCFunctions,
+		},
+		{
+			Name:        "call_rpc",
+			Description: "Call a database function through PostgREST after checking the arguments against its signature (GET for immutable and stable functions, POST otherwise)",
+			Request:     CallRPCRequest{},
+			Handler:     dbController.CallRPC,
+		},
+
+		// RLS policies
+		{
+			Name:        "get_rls_policies",
+			Description: "Get RLS policies for a table or all tables",
+			Request:     GetRLSPoliciesRequest{},
+			Handler:     dbController.GetRLSPolicies,
+		},
+		{
+			Name:        "create_rls_policy",
+			Description: "Create a new RLS policy",
+			Request:     CreateRLSPolicyRequest{},
+			Handler:     dbController.CreateRLSPolicy,
+		},
+		{
+			Name:        "update_rls_policy",
+			Description: "Update an existing RLS policy",
+			Request:     UpdateRLSPolicyRequest{},
+			Handler:     dbController.UpdateRLSPolicy,
+		},
+		{
+			Name:        "delete_rls_policy",
+			Description: "Delete an RLS policy",
+			Request:     DeleteRLSPolicyRequest{},
+			Handler:     dbController.DeleteRLSPolicy,
+		},
+		{
+			Name:        "test_rls_policy",
+			Description: "Try a proposed RLS policy without applying it: create it in a transaction, report which rows each persona (anon or an authenticated user) can select, insert, update and delete and what the given probe queries return, then roll back. Requires PG_CONNECTION_STRING",
+			Request:     TestRLSPolicyRequest{},
+			Handler:     dbController.TestRLSPolicy,
+		},
+
+		// Edge functions
+		{
+			Name:        "get_edge_functions",
+			Description: "Get all edge functions or a specific one",
+			Request:     GetEdgeFunctionsRequest{},
+			Handler:     edgeFunctionsController.GetEdgeFunctions,
+		},
+		{
+			Name:        "create_edge_function",
+			Description: "Create a new edge function",
+			Request:     CreateEdgeFunctionRequest{},
+			Handler:     edgeFunctionsController.CreateEdgeFunction,
+		},
+		{
+			Name:        "update_edge_function",
+			Description: "Update an existing edge function",
+			Request:     UpdateEdgeFunctionRequest{},
+			Handler:     edgeFunctionsController.UpdateEdgeFunction,
+		},
+		{
+			Name:        "delete_edge_function",
+			Description: "Delete an edge function",
+			Request:     DeleteEdgeFunctionRequest{},
+			Handler:     edgeFunctionsController.DeleteEdgeFunction,
+		},
+		{
+			Name:        "deploy_edge_function",
+			Description: "Deploy an edge function",
+			Request:     DeployEdgeFunctionRequest{},
+			Handler:     edgeFunctionsController.DeployEdgeFunction,
+		},
+
+		// Database schema
+		{
+			Name:        "get_database_schema",
+			Description: "Get database schema",
+			Request:     GetDatabaseSchemaRequest{},
+			Handler:     dbController.GetDatabaseSchema,
+		},
+		{
+			Name:           "create_schema",
+			Description:    "Create a new schema",
+			Request:        CreateSchemaRequest{},
+			Handler:        dbController.CreateSchema,
+			SchemaArgument: "name",
+		},
+		{
+			Name:           "delete_schema",
+			Description:    "Delete a schema",
+			Request:        DeleteSchemaRequest{},
+			Handler:        dbController.DeleteSchema,
+			SchemaArgument: "name",
+		},
+
+		// Tables
+		{
+			Name:        "create_table",
+			Description: "Create a new table",
+			Request:     CreateTableRequest{},
+			Handler:     tableController.CreateTable,
+		},
+		{
+			Name:        "alter_table",
+			Description: "Alter a table (add/drop columns, rename)",
+			Request:     AlterTableRequest{},
+			Handler:     tableController.AlterTable,
+		},
+		{
+			Name:        "drop_table",
+			Description: "Drop a table",
+			Request:     DropTableRequest{},
+			Handler:     tableController.DropTable,
+		},
+
+		// Storage buckets
+		{
+			Name:        "get_buckets",
+			Description: "Get all storage buckets or a specific one",
+			Request:     GetBucketsRequest{},
+			Handler:     storageController.GetBuckets,
+		},
+		{
+			Name:        "create_bucket",
+			Description: "Create a new storage bucket",
+			Request:     CreateBucketRequest{},
+			Handler:     storageController.CreateBucket,
+		},
+		{
+			Name:        "update_bucket",
+			Description: "Update a storage bucket",
+			Request:     UpdateBucketRequest{},
+			Handler:     storageController.UpdateBucket,
+		},
+		{
+			Name:        "empty_bucket",
+			Description: "Delete every object in a storage bucket",
+			Request:     EmptyBucketRequest{},
+			Handler:     storageController.EmptyBucket,
+		},
+		{
+			Name:        "delete_bucket",
+			Description: "Delete a storage bucket, optionally emptying it first",
+			Request:     DeleteBucketRequest{},
+			Handler:     storageController.DeleteBucket,
+		},
+
+		// Bucket policies
+		{
+			Name:        "get_bucket_policies",
+			Description: "Get the RLS policies on storage.objects that apply to a storage bucket",
+			Request:     GetBucketPoliciesRequest{},
+			Handler:     storageController.GetBucketPolicies,
+		},
+		{
+			Name:        "create_bucket_policy",
+			Description: "Create an RLS policy on storage.objects for a storage bucket, from a template (public read, authenticated upload, owner only, folder per user) or an expression",
+			Request:     CreateBucketPolicyRequest{},
+			Handler:     storageController.CreateBucketPolicy,
+		},
+		{
+			Name:        "update_bucket_policy",
+			Description: "Update an RLS policy of a storage bucket in place",
+			Request:     UpdateBucketPolicyRequest{},
+			Handler:     storageController.UpdateBucketPolicy,
+		},
+		{
+			Name:        "delete_bucket_policy",
+			Description: "Delete an RLS policy of a storage bucket",
+			Request:     DeleteBucketPolicyRequest{},
+			Handler:     storageController.DeleteBucketPolicy,
+		},
+
+		// Storage objects
+		{
+			Name:        "list_objects",
+			Description: "List the files and folders under a prefix of a storage bucket, with search and pagination",
+			Request:     ListObjectsRequest{},
+			Handler:     storageController.ListObjects,
+		},
+		{
+			Name:        "upload_object",
+			Description: "Upload a file to a storage bucket from text or base64 content",
+			Request:     UploadObjectRequest{},
+			Handler:     storageController.UploadObject,
+		},
+		{
+			Name:        "download_object",
+			Description: "Download a file from a storage bucket, as inline text for text files and base64 otherwise",
+			Request:     DownloadObjectRequest{},
+			Handler:     storageController.DownloadObject,
+		},
+		{
+			Name:        "move_object",
+			Description: "Move or rename a file, optionally into another bucket",
+			Request:     TransferObjectRequest{},
+			Handler:     storageController.MoveObject,
+		},
+		{
+			Name:        "copy_object",
+			Description: "Copy a file, optionally into another bucket",
+			Request:     TransferObjectRequest{},
+			Handler:     storageController.CopyObject,
+		},
+		{
+			Name:        "delete_objects",
+			Description: "Delete one or more files from a storage bucket",
+			Request:     DeleteObjectsRequest{},
+			Handler:     storageController.DeleteObjects,
+		},
+		{
+			Name:        "create_signed_url",
+			Description: "Create a link that grants temporary read access to a file, with optional image transforms",
+			Request:     CreateSignedURLRequest{},
+			Handler:     storageController.CreateSignedURL,
+		},
+		{
+			Name:        "create_signed_upload_url",
+			Description: "Create a link that a file can be uploaded to without credentials, valid for two hours",
+			Request:     CreateSignedUploadURLRequest{},
+			Handler:     storageController.CreateSignedUploadURL,
+		},
+		{
+			Name:        "get_public_url",
+			Description: "Get the permanent link to a file in a public bucket",
+			Request:     GetPublicURLRequest{},
+			Handler:     storageController.GetPublicURL,
+		},
+
+		// Resumable uploads
+		{
+			Name:        "start_resumable_upload",
+			Description: "Start a resumable upload of a large file, sent in chunks with upload_chunk or read from a file on the server with continue_file_upload",
+			Request:     StartResumableUploadRequest{},
+			Handler:     storageController.StartResumableUpload,
+		},
+		{
+			Name:        "upload_chunk",
+			Description: "Send the next part of a resumable upload as base64",
+			Request:     UploadChunkRequest{},
+			Handler:     storageController.UploadChunk,
+		},
+		{
+			Name:        "continue_file_upload",
+			Description: "Send a file on the server for a resumable upload, from where it stopped",
+			Request:     ContinueFileUploadRequest{},
+			Handler:     storageController.ContinueFileUpload,
+		},
+		{
+			Name:        "get_upload_status",
+			Description: "Get the progress of a resumable upload",
+			Request:     ResumableUploadRequest{},
+			Handler:     storageController.GetUploadStatus,
+		},
+		{
+			Name:        "cancel_upload",
+			Description: "Cancel a resumable upload and discard what was sent",
+			Request:     ResumableUploadRequest{},
+			Handler:     storageController.CancelUpload,
+		},
+	}
+
+	if config.InternalHost(cfg.Storage.PublicURL) {
+		log.Printf("Warning: storage links use %s, which is likely only reachable inside the Docker network; set SUPABASE_PUBLIC_URL to the address users reach Supabase at", cfg.Storage.PublicURL)
+	}
+
+	// Write access to the database is opt-in
+	if cfg.SQL.WriteEnabled {
+		if _, ok := db.(database.Transactor); !ok {
+			log.Printf("Warning: SQL_WRITE_ENABLED is set but execute_sql_write requires PG_CONNECTION_STRING; the tool will return an error")
+		}
+		tools = append(tools, mcp.Tool{
+			Name:        "execute_sql_write",
+			Description: "Execute DML or DDL statements in a transaction. Run with dry_run first to see affected rows and notices, then pass the returned confirm token to commit the same statement",
+			Request:     ExecuteSQLWriteRequest{},
+			Handler:     dbController.ExecuteSQLWrite,
+			AnySchema:   true,
+		})
+	}
+
+	for _, tool := range tools {
+		registry.Register(tool)
+	}
+}
@@ -0,0 +1,438 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DDL statements cannot take bound parameters, so the fragments that are
+// spliced into them (column types, default values and policy expressions)
+// are checked here before use.
+
+// knownTypes lists the column types accepted without a schema qualifier
+var knownTypes = map[string]bool{
+	"smallint": true, "integer": true, "int": true, "int2": true, "int4": true, "int8": true,
+	"bigint": true, "smallserial": true, "serial": true, "bigserial": true, "serial2": true,
+	"serial4": true, "serial8": true, "real": true, "float": true, "float4": true, "float8": true,
+	"double precision": true, "numeric": true, "decimal": true, "money": true,
+	"text": true, "varchar": true, "character varying": true, "char": true, "character": true,
+	"bpchar": true, "citext": true, "name": true,
+	"boolean": true, "bool": true,
+	"date": true, "time": true, "timetz": true, "timestamp": true, "timestamptz": true, "interval": true,
+	"uuid": true, "json": true, "jsonb": true, "xml": true, "bytea": true,
+	"bit": true, "bit varying": true, "varbit": true,
+	"inet": true, "cidr": true, "macaddr": true, "macaddr8": true,
+	"point": true, "line": true, "lseg": true, "box": true, "path": true, "polygon": true, "circle": true,
+	"tsvector": true, "tsquery": true, "oid": true, "regclass": true,
+	"int4range": true, "int8range": true, "numrange": true, "tsrange": true, "tstzrange": true, "daterange": true,
+	"vector": true, "halfvec": true, "sparsevec": true, "geometry": true, "geography": true,
+	"ltree": true, "hstore": true,
+}
+
+var (
+	plainIdentPattern     = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+	typeModifierPattern   = regexp.MustCompile(`\s*\(\s*\d+\s*(,\s*\d+\s*)?\)$`)
+	arraySuffixPattern    = regexp.MustCompile(`\s*\[\s*\d*\s*\]$`)
+	timeZoneSuffixPattern = regexp.MustCompile(`\s+with(out)?\s+time\s+zone$`)
+)
+
+// ValidateType checks a column type against the allowlist and returns its
+// normalised form. Built-in and common extension types are accepted as is;
+// other types (enums, domains) must be schema-qualified plain identifiers
+// such as public.mood.
+func ValidateType(typ string) (string, error) {
+	normalized := strings.Join(strings.Fields(strings.ToLower(typ)), " ")
+	if normalized == "" {
+		return "", fmt.Errorf("column type is required")
+	}
+
+	base := normalized
+	var suffix string
+
+	for {
+		loc := arraySuffixPattern.FindStringIndex(base)
+		if loc == nil {
+			break
+		}
+		suffix = "[]" + suffix
+		base = base[:loc[0]]
+	}
+
+	var timeZone string
+	if loc := timeZoneSuffixPattern.FindStringIndex(base); loc != nil {
+		timeZone = base[loc[0]:]
+		base = base[:loc[0]]
+	}
+
+	var modifier string
+	if loc := typeModifierPattern.FindStringIndex(base); loc != nil {
+		modifier = strings.ReplaceAll(base[loc[0]:], " ", "")
+		base = base[:loc[0]]
+	}
+
+	if timeZone != "" && base != "time" && base != "timestamp" {
+		return "", fmt.Errorf("invalid column type %q", typ)
+	}
+
+	if !knownTypes[base] {
+		parts := strings.Split(base, ".")
+		if len(parts) != 2 || !plainIdentPattern.MatchString(parts[0]) || !plainIdentPattern.MatchString(parts[1]) {
+			return "", fmt.Errorf("unsupported column type %q; custom types must be schema-qualified (e.g. public.my_enum)", typ)
+		}
+	}
+
+	return base + modifier + timeZone + suffix, nil
+}
+
+// defaultKeywords are the bare keywords accepted in a DEFAULT clause
+var defaultKeywords = map[string]bool{
+	"true": true, "false": true, "null": true,
+	"current_timestamp": true, "current_date": true, "current_time": true,
+	"localtime": true, "localtimestamp": true, "current_user": true, "session_user": true,
+}
+
+// defaultFunctions are the functions that may be called in a DEFAULT clause
+var defaultFunctions = map[string]bool{
+	"now": true, "clock_timestamp": true, "statement_timestamp": true, "transaction_timestamp": true,
+	"timezone": true, "gen_random_uuid": true, "uuid_generate_v1": true, "uuid_generate_v4": true,
+	"extensions.uuid_generate_v4": true, "gen_random_bytes": true, "extensions.gen_random_bytes": true,
+	"nextval": true, "random": true, "auth.uid": true, "auth.role": true, "auth.jwt": true,
+	"jsonb_build_object": true, "jsonb_build_array": true, "json_build_object": true, "json_build_array": true,
+	"to_jsonb": true, "array_fill": true, "lower": true, "upper": true,
+}
+
+// ValidateDefault checks a DEFAULT expression. Literals, typed literals,
+// casts, arithmetic, ARRAY[...] constructors, a few keywords and an
+// allowlist of functions are accepted.
+func ValidateDefault(expr string) (string, error) {
+	expr = strings.TrimSpace(expr)
+	tokens, err := tokenize(expr, false)
+	if err != nil {
+		return "", fmt.Errorf("invalid default value %q: %v", expr, err)
+	}
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("default value is empty")
+	}
+
+	p := &defaultParser{tokens: tokens}
+	if err := p.expression(); err != nil {
+		return "", fmt.Errorf("invalid default value %q: %v", expr, err)
+	}
+	if !p.done() {
+		return "", fmt.Errorf("invalid default value %q: unexpected %q", expr, p.peek().text)
+	}
+	return expr, nil
+}
+
+// ValidateExpression checks that a policy expression (USING or WITH CHECK)
+// stays inside its parentheses: quotes and brackets must balance, and
+// statement separators and comments are rejected. The expression itself
+// is still evaluated by Postgres with the policy's privileges.
+func ValidateExpression(expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		return fmt.Errorf("expression is empty")
+	}
+
+	tokens, err := tokenize(expr, false)
+	if err != nil {
+		return fmt.Errorf("invalid expression: %v", err)
+	}
+
+	depth := 0
+	for _, tok := range tokens {
+		switch tok.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("invalid expression: unbalanced parentheses")
+			}
+		case ";":
+			return fmt.Errorf("invalid expression: statement separators are not allowed")
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("invalid expression: unbalanced parentheses")
+	}
+	return nil
+}
+
+// regTypes are the object identifier types. A cast to one of them looks an
+// object up by a name that may only be built at run time, which leaves no
+// dependency for a reference check to see.
+var regTypes = map[string]bool{
+	"regclass": true, "regproc": true, "regprocedure": true, "regoper": true,
+	"regoperator": true, "regtype": true, "regnamespace": true, "regrole": true,
+	"regconfig": true, "regdictionary": true, "regcollation": true,
+}
+
+// isRelationReader reports whether a function reads relations or runs
+// queries named by its arguments, such as table_to_xml or cursor_to_xml
+func isRelationReader(name string) bool {
+	return strings.HasSuffix(name, "_to_xml") ||
+		strings.HasSuffix(name, "_to_xmlschema") ||
+		strings.HasSuffix(name, "_to_xml_and_xmlschema") ||
+		strings.HasPrefix(name, "cursor_to_") ||
+		strings.HasPrefix(name, "to_") && regTypes[strings.TrimPrefix(name, "to_")]
+}
+
+// ValidateFilter checks a row filter such as id > 100. On top of the checks
+// of ValidateExpression, subqueries, set operations, the functions on the
+// read-only deny list and anything that reaches a relation by a name built
+// at run time (the XML export functions and casts to regclass and the other
+// object identifier types) are rejected. Filters are the caller's own SQL,
+// and the schema restrictions are checked through the references Postgres
+// records, which such lookups do not leave.
+func ValidateFilter(expr string) error {
+	if err := ValidateExpression(expr); err != nil {
+		return err
+	}
+
+	tokens, _ := tokenize(expr, false)
+	for i, tok := range tokens {
+		kw := keywordOf(tok)
+		switch {
+		case kw == "select" || kw == "union" || kw == "intersect" || kw == "except":
+			return fmt.Errorf("invalid filter: subqueries and set operations are not allowed")
+		case (kw == "with" || kw == "values" || kw == "table") && i > 0 && isSymbol(tokens[i-1], "("):
+			return fmt.Errorf("invalid filter: subqueries and set operations are not allowed")
+		case (tok.kind == tokenIdent || tok.kind == tokenQuotedIdent) && i+1 < len(tokens) && isSymbol(tokens[i+1], "("):
+			if name := functionName(tokens, i); isDeniedFunction(name) || isRelationReader(name) {
+				return fmt.Errorf("invalid filter: function %s is not allowed", name)
+			}
+		case (tok.kind == tokenIdent || tok.kind == tokenQuotedIdent) && regTypes[functionName(tokens, i)]:
+			return fmt.Errorf("invalid filter: type %s is not allowed", functionName(tokens, i))
+		}
+	}
+	return nil
+}
+
+// PolicyCommand validates the command a policy applies to
+func PolicyCommand(operation string) (string, error) {
+	switch op := strings.ToUpper(strings.TrimSpace(operation)); op {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "ALL":
+		return op, nil
+	default:
+		return "", fmt.Errorf("invalid operation %q; must be SELECT, INSERT, UPDATE, DELETE or ALL", operation)
+	}
+}
+
+// PolicyType validates whether a policy is PERMISSIVE or RESTRICTIVE
+func PolicyType(kind string) (string, error) {
+	switch typ := strings.ToUpper(strings.TrimSpace(kind)); typ {
+	case "PERMISSIVE", "RESTRICTIVE":
+		return typ, nil
+	default:
+		return "", fmt.Errorf("invalid policy type %q; must be PERMISSIVE or RESTRICTIVE", kind)
+	}
+}
+
+// defaultParser is a small recursive descent parser for DEFAULT clauses
+type defaultParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *defaultParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *defaultParser) peek() token {
+	if p.done() {
+		return token{tokenSymbol, ""}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *defaultParser) next() token {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *defaultParser) expect(symbol string) error {
+	if tok := p.next(); tok.kind != tokenSymbol || tok.text != symbol {
+		return fmt.Errorf("expected %q", symbol)
+	}
+	return nil
+}
+
+// expression := term { ('+' | '-' | '*' | '/') term }
+func (p *defaultParser) expression() error {
+	if err := p.term(); err != nil {
+		return err
+	}
+	for tok := p.peek(); tok.kind == tokenSymbol && strings.Contains("+-*/", tok.text) && tok.text != ""; tok = p.peek() {
+		p.next()
+		if err := p.term(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// term := ['-' | '+'] primary { '::' type }
+func (p *defaultParser) term() error {
+	if tok := p.peek(); tok.kind == tokenSymbol && (tok.text == "-" || tok.text == "+") {
+		p.next()
+	}
+	if err := p.primary(); err != nil {
+		return err
+	}
+	for tok := p.peek(); tok.kind == tokenSymbol && tok.text == "::"; tok = p.peek() {
+		p.next()
+		if err := p.castType(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *defaultParser) primary() error {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber, tokenString:
+		return nil
+	case tokenSymbol:
+		if tok.text != "(" {
+			return fmt.Errorf("unexpected %q", tok.text)
+		}
+		if err := p.expression(); err != nil {
+			return err
+		}
+		return p.expect(")")
+	case tokenIdent:
+		name := strings.ToLower(tok.text)
+
+		if name == "array" {
+			if err := p.expect("["); err != nil {
+				return err
+			}
+			return p.list("]")
+		}
+
+		if next := p.peek(); next.kind == tokenSymbol && next.text == "." {
+			p.next()
+			member := p.next()
+			if member.kind != tokenIdent {
+				return fmt.Errorf("unexpected %q", member.text)
+			}
+			name += "." + strings.ToLower(member.text)
+		}
+
+		if next := p.peek(); next.kind == tokenSymbol && next.text == "(" {
+			if !defaultFunctions[name] {
+				return fmt.Errorf("function %s is not allowed in defaults", name)
+			}
+			p.next()
+			return p.list(")")
+		}
+
+		if defaultKeywords[name] {
+			return nil
+		}
+
+		// Typed literal, e.g. interval '1 day'
+		if next := p.peek(); next.kind == tokenString && knownTypes[name] {
+			p.next()
+			return nil
+		}
+		return fmt.Errorf("unexpected %q", tok.text)
+	default:
+		return fmt.Errorf("unexpected %q", tok.text)
+	}
+}
+
+// list parses a comma separated list of expressions up to the closing symbol
+func (p *defaultParser) list(closing string) error {
+	if tok := p.peek(); tok.kind == tokenSymbol && tok.text == closing {
+		p.next()
+		return nil
+	}
+	for {
+		if err := p.expression(); err != nil {
+			return err
+		}
+		tok := p.next()
+		if tok.kind == tokenSymbol && tok.text == closing {
+			return nil
+		}
+		if tok.kind != tokenSymbol || tok.text != "," {
+			return fmt.Errorf("expected ',' or %q", closing)
+		}
+	}
+}
+
+// castType consumes the type name following '::' and checks it with
+// ValidateType
+func (p *defaultParser) castType() error {
+	var typ strings.Builder
+
+	words := func() error {
+		for tok := p.peek(); tok.kind == tokenIdent; tok = p.peek() {
+			if typ.Len() > 0 {
+				typ.WriteByte(' ')
+			}
+			typ.WriteString(p.next().text)
+			if next := p.peek(); next.kind == tokenSymbol && next.text == "." {
+				p.next()
+				member := p.next()
+				if member.kind != tokenIdent {
+					return fmt.Errorf("unexpected %q", member.text)
+				}
+				typ.WriteString("." + member.text)
+			}
+		}
+		return nil
+	}
+
+	if err := words(); err != nil {
+		return err
+	}
+	if typ.Len() == 0 {
+		return fmt.Errorf("expected a type after '::'")
+	}
+
+	// Type modifier, e.g. varchar(20) or numeric(10, 2)
+	if tok := p.peek(); tok.kind == tokenSymbol && tok.text == "(" {
+		p.next()
+		typ.WriteByte('(')
+		for {
+			tok := p.next()
+			if tok.kind != tokenNumber {
+				return fmt.Errorf("invalid type modifier")
+			}
+			typ.WriteString(tok.text)
+			sep := p.next()
+			if sep.kind == tokenSymbol && sep.text == ")" {
+				typ.WriteByte(')')
+				break
+			}
+			if sep.kind != tokenSymbol || sep.text != "," {
+				return fmt.Errorf("invalid type modifier")
+			}
+			typ.WriteByte(',')
+		}
+		// with/without time zone may follow the modifier
+		if err := words(); err != nil {
+			return err
+		}
+	}
+
+	for tok := p.peek(); tok.kind == tokenSymbol && tok.text == "["; tok = p.peek() {
+		p.next()
+		if p.peek().kind == tokenNumber {
+			p.next()
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+		typ.WriteString("[]")
+	}
+
+	_, err := ValidateType(typ.String())
+	return err
+}
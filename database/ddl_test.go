@@ -0,0 +1,153 @@
+package database
+
+import "testing"
+
+func TestValidateType(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want string
+	}{
+		{"text", "text"},
+		{" VARCHAR ( 255 ) ", "varchar(255)"},
+		{"timestamp with time zone", "timestamp with time zone"},
+		{"numeric(10, 2)[]", "numeric(10,2)[]"},
+		{"public.mood", "public.mood"},
+		{"text; DROP TABLE users", ""},
+		{`"text"`, ""},
+		{"public.mood; --", ""},
+		{`U&"\0074ext"`, ""},
+		{"$$text$$", ""},
+		{"text DEFAULT pg_sleep(10)", ""},
+		{"integer with time zone", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := ValidateType(tt.typ)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ValidateType(%q) = %q, want an error", tt.typ, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ValidateType(%q) = %q, %v, want %q", tt.typ, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidateDefault(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"0", true},
+		{"'draft'", true},
+		{"'it''s'", true},
+		{"now()", true},
+		{"gen_random_uuid()", true},
+		{"auth.uid()", true},
+		{"'{}'::jsonb", true},
+		{"ARRAY[1, 2]", true},
+		{"-1.5 * 2", true},
+		{"true", true},
+		{"'x'); DROP TABLE users; --", false},
+		{"0; DROP TABLE users", false},
+		{"pg_sleep(10)", false},
+		{`"pg_sleep"(10)`, false},
+		{`U&"\0070g_sleep"(10)`, false},
+		{"$$x$$ || pg_sleep(1)", false},
+		{"(SELECT password FROM auth.users LIMIT 1)", false},
+		{"0 -- comment", false},
+		{"'unterminated", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		_, err := ValidateDefault(tt.expr)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateDefault(%q) = %v, want nil", tt.expr, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("ValidateDefault(%q) = nil, want an error", tt.expr)
+		}
+	}
+}
+
+func TestValidateExpression(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"auth.uid() = user_id", true},
+		{"(SELECT auth.uid()) = user_id", true},
+		{"status = 'a;b'", true},
+		{"name = '(('", true},
+		{"body = $$)$$", true},
+		{`"weird"")"" col" = 1`, true},
+		{"true) WITH CHECK (true", false},
+		{"true); DROP TABLE users; --", false},
+		{"true; DROP TABLE users", false},
+		{"(true", false},
+		{"true /* comment */", false},
+		{"'unterminated", false},
+		{"$$unterminated", false},
+		{"  ", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateExpression(tt.expr)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateExpression(%q) = %v, want nil", tt.expr, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("ValidateExpression(%q) = nil, want an error", tt.expr)
+		}
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		valid  bool
+	}{
+		{"id > 100", true},
+		{"status = 'draft' OR owner_id = auth.uid()", true},
+		{"created_at > '2024-01-01'::timestamp with time zone", true},
+		{"values @> ARRAY[1]", true},
+		{"note = 'select * from secrets'", true},
+		{"false INTERSECT SELECT 1", false},
+		{"true UNION SELECT 1", false},
+		{"id IN (SELECT id FROM private.secrets)", false},
+		{"EXISTS (SELECT 1)", false},
+		{"id IN (VALUES (1))", false},
+		{"id IN (TABLE private.ids)", false},
+		{"pg_sleep(10) IS NULL", false},
+		{`"pg_read_file"('/etc/passwd') IS NOT NULL`, false},
+		{"true; DROP TABLE posts", false},
+		{"true) OR (true", false},
+		{"true -- comment", false},
+		{"", false},
+		{"table_to_xml(('auth.'||'users')::regclass,true,false,'')::text::int > 0", false},
+		{"schema_to_xml('auth', true, false, '') IS NOT NULL", false},
+		{"pg_catalog.database_to_xml(true, false, '') IS NOT NULL", false},
+		{"cursor_to_xml('c', 1, true, false, '') IS NOT NULL", false},
+		{"table_to_xmlschema('auth.users', true, false, '') IS NOT NULL", false},
+		{"to_regclass('auth.' || 'users') IS NOT NULL", false},
+		{"('auth.' || 'users')::regclass::oid > 0", false},
+		{`CAST('auth.users' AS "regclass") IS NOT NULL`, false},
+		{"owner_id::pg_catalog.regrole IS NOT NULL", false},
+		{"regclass 'auth.users' IS NOT NULL", false},
+		{"xml_column IS NOT NULL AND to_region(area) > 0", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateFilter(tt.filter)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateFilter(%q) = %v, want nil", tt.filter, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("ValidateFilter(%q) = nil, want an error", tt.filter)
+		}
+	}
+}
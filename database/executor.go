@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/config"
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+	"github.com/sirupsen/logrus"
+)
+
+// Executor runs SQL statements against the Supabase database.
+// Placeholders are written as $1, $2, ... and bound to args.
+type Executor interface {
+	// Query runs a statement and returns its rows and metadata
+	Query(ctx context.Context, sql string, args ...interface{}) (*Result, error)
+
+	// QueryReadOnly runs a statement in a READ ONLY transaction
+	QueryReadOnly(ctx context.Context, sql string, args ...interface{}) (*Result, error)
+
+	// Mode names the backend in use ("postgres" or "rpc")
+	Mode() string
+
+	// Close releases the resources held by the executor
+	Close()
+}
+
+// Column describes a column of a result set
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Result represents the outcome of a SQL statement
+type Result struct {
+	Command      string                   `json:"command,omitempty"`
+	Columns      []Column                 `json:"columns"`
+	Rows         []map[string]interface{} `json:"rows"`
+	RowsAffected int64                    `json:"rows_affected"`
+}
+
+// Decode converts the result rows into a slice of structs (or any other
+// JSON-compatible destination) using their json tags
+func (r *Result) Decode(v interface{}) error {
+	data, err := json.Marshal(r.Rows)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Error represents an error reported by Postgres
+type Error struct {
+	Severity string `json:"severity,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+	Detail   string `json:"detail,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+	Position int32  `json:"position,omitempty"`
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (SQLSTATE %s)", e.Message, e.Code)
+}
+
+// Open returns the executor to use for the given configuration: a native
+// Postgres pool when PG_CONNECTION_STRING is set, otherwise the
+// execute_sql RPC function exposed through PostgREST
+func Open(ctx context.Context, cfg *config.Config, client *supabase.SupabaseClientExtended) (Executor, error) {
+	if cfg.Supabase.PGConnStr == "" {
+		logrus.Info("PG_CONNECTION_STRING not set, running SQL through the execute_sql RPC function")
+		return NewRPCExecutor(client), nil
+	}
+
+	executor, err := NewPostgresExecutor(ctx, cfg.Supabase.PGConnStr, cfg.SQL.StatementTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Info("Using direct PostgreSQL connection pool")
+	return executor, nil
+}
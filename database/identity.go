@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrIdentityUnsupported is returned by executors that cannot run
+// statements as an end user
+var ErrIdentityUnsupported = errors.New("running SQL as a user requires a direct database connection; set PG_CONNECTION_STRING")
+
+// Identity is the end user a statement runs as. Like PostgREST, the
+// executor switches to Role for the transaction and exposes Claims as
+// request.jwt.claims, which auth.uid() and auth.jwt() read, so row level
+// security applies as it would for that user.
+type Identity struct {
+	Role   string
+	Claims map[string]interface{}
+}
+
+type identityKey struct{}
+
+// AsIdentity returns a context whose SQL statements run as identity
+func AsIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// identityFrom returns the identity to run statements as, or nil
+func identityFrom(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// setIdentity switches the current transaction to identity
+func setIdentity(ctx context.Context, conn *pgx.Conn, identity *Identity) error {
+	claims, err := json.Marshal(identity.Claims)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(ctx, "SELECT set_config('request.jwt.claims', $1, true), set_config('role', $2, true)", string(claims), identity.Role)
+	return err
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenQuotedIdent
+	tokenNumber
+	tokenString
+	tokenSymbol
+	tokenParam
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits SQL text into tokens. Comments are skipped when
+// allowComments is set and rejected otherwise; unterminated quotes and
+// comments are reported as errors.
+func tokenize(sql string, allowComments bool) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case strings.HasPrefix(sql[i:], "--") || strings.HasPrefix(sql[i:], "/*"):
+			if !allowComments {
+				return nil, fmt.Errorf("comments are not allowed")
+			}
+			end, err := skipComment(sql, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case ch == '\'':
+			end, err := scanQuoted(sql, i, '\'', false)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenString, sql[i:end]})
+			i = end
+		case (ch == 'u' || ch == 'U') && i+2 < len(sql) && sql[i+1] == '&' && (sql[i+2] == '"' || sql[i+2] == '\''):
+			// Unicode escapes: U&"d\0061ta" and U&'d\0061ta'
+			end, err := scanQuoted(sql, i+2, sql[i+2], false)
+			if err != nil {
+				return nil, err
+			}
+			kind := tokenQuotedIdent
+			if sql[i+2] == '\'' {
+				kind = tokenString
+			}
+			tokens = append(tokens, token{kind, sql[i:end]})
+			i = end
+		case (ch == 'e' || ch == 'E') && i+1 < len(sql) && sql[i+1] == '\'':
+			end, err := scanQuoted(sql, i+1, '\'', true)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenString, sql[i:end]})
+			i = end
+		case ch == '"':
+			end, err := scanQuoted(sql, i, '"', false)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenQuotedIdent, sql[i:end]})
+			i = end
+		case ch == '$' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9':
+			j := i + 1
+			for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			tokens = append(tokens, token{tokenParam, sql[i:j]})
+			i = j
+		case ch == '$':
+			tagEnd := strings.IndexByte(sql[i+1:], '$')
+			if tagEnd < 0 || !isDollarTag(sql[i+1:i+1+tagEnd]) {
+				return nil, fmt.Errorf("unexpected '$'")
+			}
+			tag := sql[i : i+tagEnd+2]
+			closing := strings.Index(sql[i+len(tag):], tag)
+			if closing < 0 {
+				return nil, fmt.Errorf("unterminated dollar-quoted string")
+			}
+			end := i + len(tag) + closing + len(tag)
+			tokens = append(tokens, token{tokenString, sql[i:end]})
+			i = end
+		case ch >= '0' && ch <= '9' || (ch == '.' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9'):
+			j := i
+			for j < len(sql) && (sql[j] >= '0' && sql[j] <= '9' || sql[j] == '.' || sql[j] == 'e' || sql[j] == 'E' ||
+				((sql[j] == '-' || sql[j] == '+') && (sql[j-1] == 'e' || sql[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, sql[i:j]})
+			i = j
+		case isIdentChar(ch) || ch >= 0x80:
+			j := i
+			for j < len(sql) && (isIdentChar(sql[j]) || sql[j] == '$' || sql[j] >= 0x80) {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, sql[i:j]})
+			i = j
+		case strings.HasPrefix(sql[i:], "::"):
+			tokens = append(tokens, token{tokenSymbol, "::"})
+			i += 2
+		default:
+			tokens = append(tokens, token{tokenSymbol, string(ch)})
+			i++
+		}
+	}
+
+	return mergeUEscape(tokens), nil
+}
+
+// mergeUEscape folds a UESCAPE clause into the Unicode escape token it
+// belongs to, so that U&"x" UESCAPE '!' stays a single identifier
+func mergeUEscape(tokens []token) []token {
+	merged := tokens[:0]
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if isUnicodeQuoted(tok) && i+2 < len(tokens) && keywordOf(tokens[i+1]) == "uescape" && tokens[i+2].kind == tokenString {
+			tok.text += " UESCAPE " + tokens[i+2].text
+			i += 2
+		}
+		merged = append(merged, tok)
+	}
+	return merged
+}
+
+func isUnicodeQuoted(tok token) bool {
+	return (tok.kind == tokenQuotedIdent || tok.kind == tokenString) && len(tok.text) > 2 && (tok.text[0] == 'u' || tok.text[0] == 'U') && tok.text[1] == '&'
+}
+
+// unquoteIdent returns the name denoted by a quoted identifier, decoding
+// the escapes of U&"..." identifiers. Invalid escapes are left as written;
+// Postgres rejects them anyway.
+func unquoteIdent(text string) string {
+	escape := byte('\\')
+	unicode := strings.HasPrefix(text, "U&") || strings.HasPrefix(text, "u&")
+	if unicode {
+		text = text[2:]
+		if end := strings.LastIndex(text, " UESCAPE '"); end >= 0 {
+			escape = text[end+len(" UESCAPE '")]
+			text = text[:end]
+		}
+	}
+
+	name := strings.ReplaceAll(text[1:len(text)-1], `""`, `"`)
+	if !unicode {
+		return name
+	}
+	if decoded, ok := decodeUnicodeEscapes(name, escape); ok {
+		return decoded
+	}
+	return name
+}
+
+// decodeUnicodeEscapes expands \XXXX and \+XXXXXX escapes (with the given
+// escape character), combining UTF-16 surrogate pairs as Postgres does
+func decodeUnicodeEscapes(s string, escape byte) (string, bool) {
+	var out strings.Builder
+	var high rune
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != escape {
+			if high != 0 {
+				return "", false
+			}
+			out.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == escape {
+			out.WriteByte(escape)
+			i++
+			continue
+		}
+
+		digits := 4
+		if i+1 < len(s) && s[i+1] == '+' {
+			digits = 6
+			i++
+		}
+		if i+digits >= len(s) {
+			return "", false
+		}
+		code, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+		if err != nil {
+			return "", false
+		}
+		i += digits
+
+		r := rune(code)
+		switch {
+		case utf16.IsSurrogate(r) && high == 0:
+			high = r
+			continue
+		case high != 0:
+			r = utf16.DecodeRune(high, r)
+			high = 0
+			if r == utf8.RuneError {
+				return "", false
+			}
+		}
+		out.WriteRune(r)
+	}
+	if high != 0 {
+		return "", false
+	}
+	return out.String(), true
+}
+
+// scanQuoted returns the index just past the quoted token starting at i.
+// Doubled quotes are part of the token; backslash escapes are honoured
+// for E” strings.
+func scanQuoted(sql string, i int, quote byte, escapes bool) (int, error) {
+	for j := i + 1; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			if escapes {
+				j++
+			}
+		case quote:
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1, nil
+		}
+	}
+	if quote == '"' {
+		return 0, fmt.Errorf("unterminated quoted identifier")
+	}
+	return 0, fmt.Errorf("unterminated string literal")
+}
+
+// skipComment returns the index just past the comment starting at i.
+// Block comments nest, as they do in Postgres.
+func skipComment(sql string, i int) (int, error) {
+	if strings.HasPrefix(sql[i:], "--") {
+		end := strings.IndexByte(sql[i:], '\n')
+		if end < 0 {
+			return len(sql), nil
+		}
+		return i + end + 1, nil
+	}
+
+	depth := 0
+	for j := i; j < len(sql)-1; j++ {
+		switch {
+		case sql[j] == '/' && sql[j+1] == '*':
+			depth++
+			j++
+		case sql[j] == '*' && sql[j+1] == '/':
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated comment")
+}
@@ -0,0 +1,361 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresExecutor runs SQL over a native connection pool
+type PostgresExecutor struct {
+	pool *pgxpool.Pool
+
+	// defaultTimeout is the statement_timeout applied when the context
+	// does not carry one; zero leaves the server setting alone
+	defaultTimeout time.Duration
+
+	typesMu   sync.RWMutex
+	typeNames map[uint32]string
+
+	// notices collects server notices per connection while a script runs
+	notices sync.Map
+}
+
+// NewPostgresExecutor creates a connection pool for the given connection
+// string. Connections are established lazily on first use. Statements are
+// cancelled by the server after statementTimeout unless it is zero.
+func NewPostgresExecutor(ctx context.Context, connString string, statementTimeout time.Duration) (*PostgresExecutor, error) {
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PG_CONNECTION_STRING: %w", err)
+	}
+
+	executor := &PostgresExecutor{
+		defaultTimeout: statementTimeout,
+		typeNames:      make(map[uint32]string),
+	}
+	poolConfig.ConnConfig.OnNotice = executor.handleNotice
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	executor.pool = pool
+
+	return executor, nil
+}
+
+// Pool exposes the underlying pool for callers that need transactions
+func (e *PostgresExecutor) Pool() *pgxpool.Pool {
+	return e.pool
+}
+
+// Mode implements Executor
+func (e *PostgresExecutor) Mode() string {
+	return "postgres"
+}
+
+// Close implements Executor
+func (e *PostgresExecutor) Close() {
+	e.pool.Close()
+}
+
+// Query implements Executor. Statements with arguments use the extended
+// protocol and real parameter binding; statements without arguments use
+// the simple protocol so that scripts with several statements work, and
+// the last result set is returned.
+//
+// The statement timeout is set for the session and reset afterwards rather
+// than with SET LOCAL, so that statements which cannot run inside a
+// transaction block keep working.
+func (e *PostgresExecutor) Query(ctx context.Context, sql string, args ...interface{}) (*Result, error) {
+	// The identity is scoped to a transaction
+	if identityFrom(ctx) != nil {
+		return e.queryInTx(ctx, pgx.TxOptions{}, true, sql, args...)
+	}
+
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	defer conn.Release()
+
+	if timeout := e.statementTimeout(ctx); timeout > 0 {
+		if err := setStatementTimeout(ctx, conn.Conn(), timeout, false); err != nil {
+			return nil, convertError(err)
+		}
+		defer func() {
+			// A connection that keeps the timeout must not go back to the pool
+			if _, err := conn.Exec(context.Background(), "RESET statement_timeout"); err != nil {
+				conn.Conn().Close(context.Background())
+			}
+		}()
+	}
+
+	return e.QueryConn(ctx, conn.Conn(), sql, args...)
+}
+
+// QueryReadOnly implements Executor. The statement runs in a READ ONLY
+// transaction that is always rolled back.
+func (e *PostgresExecutor) QueryReadOnly(ctx context.Context, sql string, args ...interface{}) (*Result, error) {
+	return e.queryInTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, false, sql, args...)
+}
+
+// queryInTx runs a statement in its own transaction, committing it only
+// when commit is true
+func (e *PostgresExecutor) queryInTx(ctx context.Context, opts pgx.TxOptions, commit bool, sql string, args ...interface{}) (*Result, error) {
+	tx, err := e.pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := e.prepareTx(ctx, tx.Conn()); err != nil {
+		return nil, convertError(err)
+	}
+
+	result, err := e.QueryConn(ctx, tx.Conn(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if commit {
+		if err := tx.Commit(ctx); err != nil {
+			return nil, convertError(err)
+		}
+	}
+	return result, nil
+}
+
+// prepareTx applies the statement timeout and the identity carried by ctx
+// to the open transaction on conn
+func (e *PostgresExecutor) prepareTx(ctx context.Context, conn *pgx.Conn) error {
+	if timeout := e.statementTimeout(ctx); timeout > 0 {
+		if err := setStatementTimeout(ctx, conn, timeout, true); err != nil {
+			return err
+		}
+	}
+	if identity := identityFrom(ctx); identity != nil {
+		return setIdentity(ctx, conn, identity)
+	}
+	return nil
+}
+
+// QueryConn runs a statement on a specific connection, for example one
+// that has an open transaction
+func (e *PostgresExecutor) QueryConn(ctx context.Context, conn *pgx.Conn, sql string, args ...interface{}) (*Result, error) {
+	var (
+		result *Result
+		err    error
+	)
+
+	if len(args) == 0 {
+		result, err = e.querySimple(ctx, conn, sql)
+	} else {
+		result, err = e.queryExtended(ctx, conn, sql, args)
+	}
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return result, nil
+}
+
+// queryExtended runs a single statement with bound parameters
+func (e *PostgresExecutor) queryExtended(ctx context.Context, conn *pgx.Conn, sql string, args []interface{}) (*Result, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	result := &Result{Rows: []map[string]interface{}{}}
+
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(fields))
+		for i, field := range fields {
+			row[field.Name] = jsonValue(values[i])
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tag := rows.CommandTag()
+	result.Command = tag.String()
+	result.RowsAffected = tag.RowsAffected()
+
+	columns, err := e.columns(ctx, conn, fields)
+	if err != nil {
+		return nil, err
+	}
+	result.Columns = columns
+
+	return result, nil
+}
+
+// querySimple runs one or more statements without parameters
+func (e *PostgresExecutor) querySimple(ctx context.Context, conn *pgx.Conn, sql string) (*Result, error) {
+	results, err := conn.PgConn().Exec(ctx, sql).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return &Result{Rows: []map[string]interface{}{}, Columns: []Column{}}, nil
+	}
+	return e.decodeResult(ctx, conn, results[len(results)-1])
+}
+
+// decodeResult converts a result read with the simple protocol
+func (e *PostgresExecutor) decodeResult(ctx context.Context, conn *pgx.Conn, raw *pgconn.Result) (*Result, error) {
+	result := &Result{Rows: []map[string]interface{}{}, Columns: []Column{}}
+	typeMap := conn.TypeMap()
+
+	for _, values := range raw.Rows {
+		row := make(map[string]interface{}, len(raw.FieldDescriptions))
+		for i, field := range raw.FieldDescriptions {
+			value, err := decodeValue(typeMap, field, values[i])
+			if err != nil {
+				return nil, err
+			}
+			row[field.Name] = jsonValue(value)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	result.Command = raw.CommandTag.String()
+	result.RowsAffected = raw.CommandTag.RowsAffected()
+
+	columns, err := e.columns(ctx, conn, raw.FieldDescriptions)
+	if err != nil {
+		return nil, err
+	}
+	result.Columns = columns
+
+	return result, nil
+}
+
+// decodeValue decodes a raw column value using the connection's type map
+func decodeValue(typeMap *pgtype.Map, field pgconn.FieldDescription, src []byte) (interface{}, error) {
+	if src == nil {
+		return nil, nil
+	}
+	if dt, ok := typeMap.TypeForOID(field.DataTypeOID); ok {
+		return dt.Codec.DecodeValue(typeMap, field.DataTypeOID, field.Format, src)
+	}
+	return string(src), nil
+}
+
+// columns resolves the column metadata of a result set, looking up the
+// SQL names of types not seen before
+func (e *PostgresExecutor) columns(ctx context.Context, conn *pgx.Conn, fields []pgconn.FieldDescription) ([]Column, error) {
+	var missing []uint32
+
+	e.typesMu.RLock()
+	for _, field := range fields {
+		if _, ok := e.typeNames[field.DataTypeOID]; !ok {
+			missing = append(missing, field.DataTypeOID)
+		}
+	}
+	e.typesMu.RUnlock()
+
+	if len(missing) > 0 {
+		rows, err := conn.Query(ctx, `SELECT oid, format_type(oid, NULL) FROM pg_type WHERE oid = ANY($1)`, missing)
+		if err != nil {
+			return nil, err
+		}
+
+		names := make(map[uint32]string)
+		for rows.Next() {
+			var oid uint32
+			var name string
+			if err := rows.Scan(&oid, &name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			names[oid] = name
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		e.typesMu.Lock()
+		for oid, name := range names {
+			e.typeNames[oid] = name
+		}
+		e.typesMu.Unlock()
+	}
+
+	e.typesMu.RLock()
+	defer e.typesMu.RUnlock()
+
+	columns := make([]Column, 0, len(fields))
+	for _, field := range fields {
+		name, ok := e.typeNames[field.DataTypeOID]
+		if !ok {
+			name = strconv.FormatUint(uint64(field.DataTypeOID), 10)
+		}
+		columns = append(columns, Column{Name: field.Name, Type: name})
+	}
+	return columns, nil
+}
+
+// jsonValue converts a decoded Postgres value into something that
+// encodes naturally as JSON
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case [16]byte:
+		// uuid
+		return fmt.Sprintf("%x-%x-%x-%x-%x", val[0:4], val[4:6], val[6:8], val[8:10], val[10:16])
+	case []interface{}:
+		for i := range val {
+			val[i] = jsonValue(val[i])
+		}
+		return val
+	case time.Time, json.Marshaler:
+		return val
+	case driver.Valuer:
+		if value, err := val.Value(); err == nil {
+			return value
+		}
+	case fmt.Stringer:
+		return val.String()
+	}
+	return v
+}
+
+// convertError turns driver errors into *Error values
+func convertError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return &Error{
+			Severity: pgErr.Severity,
+			Code:     pgErr.Code,
+			Message:  pgErr.Message,
+			Detail:   pgErr.Detail,
+			Hint:     pgErr.Hint,
+			Position: pgErr.Position,
+		}
+	}
+	return err
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v6"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// deniedFunctions are functions with side effects outside the current
+// query: server administration, session state, locks, sleeping, file and
+// large object access, sequences, notifications and dynamic SQL
+var deniedFunctions = map[string]bool{
+	"pg_terminate_backend": true, "pg_cancel_backend": true, "pg_reload_conf": true,
+	"pg_rotate_logfile": true, "pg_promote": true, "pg_switch_wal": true,
+	"pg_backup_start": true, "pg_backup_stop": true, "pg_start_backup": true, "pg_stop_backup": true,
+	"pg_log_backend_memory_contexts": true, "pg_import_system_collations": true,
+	"set_config": true, "pg_notify": true, "nextval": true, "setval": true,
+	"pg_read_file": true, "pg_read_binary_file": true, "pg_ls_dir": true, "pg_stat_file": true,
+	"pg_file_write": true, "pg_file_rename": true, "pg_file_unlink": true,
+	"query_to_xml": true, "query_to_xmlschema": true, "query_to_xml_and_xmlschema": true,
+	"cursor_to_xml": true, "cursor_to_xmlschema": true,
+	"table_to_xml": true, "table_to_xmlschema": true, "table_to_xml_and_xmlschema": true,
+	"schema_to_xml": true, "schema_to_xmlschema": true, "schema_to_xml_and_xmlschema": true,
+	"database_to_xml": true, "database_to_xmlschema": true, "database_to_xml_and_xmlschema": true,
+	"txid_current": true, "pg_current_xact_id": true,
+}
+
+// deniedFunctionPrefixes cover families of functions in the same categories
+var deniedFunctionPrefixes = []string{
+	"pg_sleep",
+	"pg_advisory_",
+	"pg_try_advisory_",
+	"lo_",
+	"dblink",
+	"pg_stat_reset",
+	"pg_create_",
+	"pg_drop_replication_slot",
+	"pg_replication_origin_",
+	"pg_replication_slot_advance",
+	"pg_logical_",
+	"pg_wal_replay_",
+	"http",
+}
+
+// CheckReadOnly parses sql with the Postgres parser and returns an error
+// describing the first construct that could modify data or server state.
+// It accepts one or more SELECT, WITH, VALUES, TABLE, SHOW and EXPLAIN
+// statements and rejects data-modifying WITH queries, SELECT INTO, row
+// locking clauses and calls to functions on the deny list anywhere in the
+// parse tree.
+//
+// Functions can still have side effects the deny list does not know about,
+// so callers should also run the statement in a read-only transaction.
+func CheckReadOnly(sql string) error {
+	tree, err := pg_query.Parse(sql)
+	if err != nil {
+		return fmt.Errorf("unable to parse query: %v", err)
+	}
+	if len(tree.Stmts) == 0 {
+		return fmt.Errorf("query is empty")
+	}
+
+	for i, raw := range tree.Stmts {
+		if err := checkStatement(sql, raw); err != nil {
+			if len(tree.Stmts) > 1 {
+				return fmt.Errorf("statement %d: %v", i+1, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStatement classifies a single top-level statement
+func checkStatement(sql string, raw *pg_query.RawStmt) error {
+	stmt := raw.Stmt
+	switch {
+	case stmt.GetSelectStmt() != nil, stmt.GetVariableShowStmt() != nil:
+	case stmt.GetExplainStmt() != nil:
+		// EXPLAIN ANALYZE executes the explained statement
+		if query := stmt.GetExplainStmt().Query; query.GetSelectStmt() == nil {
+			return fmt.Errorf("EXPLAIN of %s statements is not allowed", statementName(nodeMessage(query)))
+		}
+	default:
+		return fmt.Errorf("%s statements are not allowed; only SELECT, WITH, VALUES, TABLE, SHOW and EXPLAIN can be used", firstKeyword(sql, raw))
+	}
+
+	return walkTree(stmt.ProtoReflect(), func(node proto.Message) error {
+		switch n := node.(type) {
+		case *pg_query.InsertStmt, *pg_query.UpdateStmt, *pg_query.DeleteStmt, *pg_query.MergeStmt:
+			return fmt.Errorf("data-modifying statements (%s) inside WITH are not allowed", statementName(n))
+		case *pg_query.SelectStmt:
+			if n.IntoClause != nil {
+				return fmt.Errorf("SELECT INTO is not allowed because it creates a table")
+			}
+			if len(n.LockingClause) > 0 {
+				return fmt.Errorf("row locking clauses (FOR UPDATE/FOR SHARE) are not allowed")
+			}
+		case *pg_query.FuncCall:
+			if len(n.Funcname) == 0 {
+				break
+			}
+			// The parser folds unquoted names to lower case and decodes
+			// quoted ones, including U& escapes
+			name := n.Funcname[len(n.Funcname)-1].GetString_().GetSval()
+			if isDeniedFunction(name) {
+				return fmt.Errorf("function %s is not allowed in read-only queries", name)
+			}
+		}
+		return nil
+	})
+}
+
+// walkTree calls visit for m and every message below it
+func walkTree(m protoreflect.Message, visit func(proto.Message) error) error {
+	if err := visit(m.Interface()); err != nil {
+		return err
+	}
+
+	var err error
+	m.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.Message() == nil || field.IsMap():
+		case field.IsList():
+			list := value.List()
+			for i := 0; i < list.Len() && err == nil; i++ {
+				err = walkTree(list.Get(i).Message(), visit)
+			}
+		default:
+			err = walkTree(value.Message(), visit)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// nodeMessage returns the statement or expression held by a parse tree node
+func nodeMessage(node *pg_query.Node) proto.Message {
+	m := node.ProtoReflect()
+	field := m.WhichOneof(m.Descriptor().Oneofs().Get(0))
+	if field == nil {
+		return nil
+	}
+	return m.Get(field).Message().Interface()
+}
+
+// statementName returns the SQL keyword of a statement node, such as INSERT
+// for an InsertStmt
+func statementName(stmt proto.Message) string {
+	if stmt == nil {
+		return "empty"
+	}
+	switch stmt.(type) {
+	case *pg_query.CreateTableAsStmt:
+		return "CREATE TABLE AS"
+	case *pg_query.DeclareCursorStmt:
+		return "DECLARE"
+	}
+	name := string(stmt.ProtoReflect().Descriptor().Name())
+	return strings.ToUpper(strings.TrimSuffix(name, "Stmt"))
+}
+
+// firstKeyword returns the first word of a top-level statement as written,
+// for error messages
+func firstKeyword(sql string, raw *pg_query.RawStmt) string {
+	text := sql[raw.StmtLocation:]
+	if raw.StmtLen > 0 {
+		text = text[:raw.StmtLen]
+	}
+	tokens, err := tokenize(text, true)
+	if err != nil {
+		return "these"
+	}
+	for _, tok := range tokens {
+		if !isSymbol(tok, "(") {
+			return strings.ToUpper(tok.text)
+		}
+	}
+	return "these"
+}
+
+// splitStatements splits the token stream on top-level semicolons
+func splitStatements(tokens []token) ([][]token, error) {
+	var statements [][]token
+	var current []token
+	depth := 0
+
+	for _, tok := range tokens {
+		if tok.kind == tokenSymbol {
+			switch tok.text {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				depth--
+				if depth < 0 {
+					return nil, fmt.Errorf("unable to parse query: unbalanced parentheses")
+				}
+			case ";":
+				if depth != 0 {
+					return nil, fmt.Errorf("unable to parse query: unbalanced parentheses")
+				}
+				if len(current) > 0 {
+					statements = append(statements, current)
+				}
+				current = nil
+				continue
+			}
+		}
+		current = append(current, tok)
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("unable to parse query: unbalanced parentheses")
+	}
+	if len(current) > 0 {
+		statements = append(statements, current)
+	}
+	return statements, nil
+}
+
+// functionName returns the lower-case name of the function called at
+// tokens[i], without its schema qualifier
+func functionName(tokens []token, i int) string {
+	tok := tokens[i]
+	if tok.kind == tokenQuotedIdent {
+		return unquoteIdent(tok.text)
+	}
+	return strings.ToLower(tok.text)
+}
+
+func isDeniedFunction(name string) bool {
+	if deniedFunctions[name] {
+		return true
+	}
+	for _, prefix := range deniedFunctionPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSymbol(tok token, symbol string) bool {
+	return tok.kind == tokenSymbol && tok.text == symbol
+}
+
+func keywordOf(tok token) string {
+	if tok.kind != tokenIdent {
+		return ""
+	}
+	return strings.ToLower(tok.text)
+}
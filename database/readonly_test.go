@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{"SELECT * FROM posts", true},
+		{"select 1; select 2", true},
+		{"WITH recent AS (SELECT * FROM posts) SELECT * FROM recent", true},
+		{"(SELECT 1) UNION (SELECT 2)", true},
+		{"EXPLAIN SELECT 1", true},
+		{"SHOW search_path", true},
+		{`SELECT "select" FROM "posts"`, true},
+		{`SELECT U&"d\0061ta" FROM posts`, true},
+		{"SELECT 'pg_sleep(1)'", true},
+		{"INSERT INTO posts DEFAULT VALUES", false},
+		{"SELECT 1; DELETE FROM posts", false},
+		{"WITH d AS (DELETE FROM posts RETURNING *) SELECT * FROM d", false},
+		{"SELECT * INTO copy FROM posts", false},
+		{"SELECT * FROM posts FOR UPDATE", false},
+		{"EXPLAIN ANALYZE DELETE FROM posts", false},
+		{"SELECT pg_sleep(10)", false},
+		{"SELECT pg_catalog.pg_sleep(10)", false},
+		{`SELECT "pg_sleep"(10)`, false},
+		{"SELECT pg_sleep/**/(10)", false},
+		{`SELECT U&"\0070g_sleep"(10)`, false},
+		{`SELECT u&"pg_\+000073leep"(10)`, false},
+		{`SELECT U&"!0070g_sleep" UESCAPE '!'(10)`, false},
+		{`SELECT U&"!0070g_sleep" /* x */ uescape '!' (10)`, false},
+		{`SELECT pg_catalog.U&"set\005Fconfig"('role', 'postgres', false)`, false},
+		{`SELECT U&"\D835\DC00"(1), U&"\0070g_advisory_lock"(1)`, false},
+		{"COPY posts TO '/tmp/posts'", false},
+		{"SELECT database_to_xml(true, false, '')", false},
+		{"SELECT schema_to_xml('auth', true, false, '')", false},
+		{"SELECT table_to_xml('auth.users', true, false, '')", false},
+		{"SELECT cursor_to_xml('c', 1, true, false, '')", false},
+		{"SELECT * FROM pg_catalog.table_to_xmlschema('auth.users', true, false, '') AS x", false},
+		{"SELECT 1 FROM posts WHERE id IN (SELECT nextval('seq'))", false},
+		{"EXPLAIN (ANALYZE, FORMAT JSON) UPDATE posts SET title = ''", false},
+		{"SELECT * FROM posts FOR SHARE OF posts NOWAIT", false},
+		{"SELECT 1 /* ; DELETE FROM posts */", true},
+		{"TABLE posts", true},
+		{"VALUES (1), (2)", true},
+		{"SELECT 'unterminated", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		err := CheckReadOnly(tt.query)
+		if tt.valid && err != nil {
+			t.Errorf("CheckReadOnly(%q) = %v, want nil", tt.query, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("CheckReadOnly(%q) = nil, want an error", tt.query)
+		}
+	}
+}
+
+func TestUnquoteIdent(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`"pg_sleep"`, "pg_sleep"},
+		{`"say ""hi"""`, `say "hi"`},
+		{`U&"d\0061t\+000061"`, "data"},
+		{`u&"\00e9t\00E9"`, "été"},
+		{`U&"a\\b"`, `a\b`},
+		{`U&"d!0061ta" UESCAPE '!'`, "data"},
+		{`U&"d""!0061" UESCAPE '!'`, `d"a`},
+		{`U&"\D83D\DE00"`, "\U0001F600"},
+		{`U&"\zzzz"`, `\zzzz`},
+		{`U&"\D83D"`, `\D83D`},
+	}
+
+	for _, tt := range tests {
+		tokens, err := tokenize(tt.sql, false)
+		if err != nil || len(tokens) != 1 || tokens[0].kind != tokenQuotedIdent {
+			t.Errorf("tokenize(%q) = %v, %v, want a single quoted identifier", tt.sql, tokens, err)
+			continue
+		}
+		if got := unquoteIdent(tokens[0].text); got != tt.want {
+			t.Errorf("unquoteIdent(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestRPCExecutorReadOnly(t *testing.T) {
+	var methods, queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		queries = append(queries, r.URL.Query().Get("query"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"n": 1}]`))
+	}))
+	defer server.Close()
+
+	executor := NewRPCExecutor(supabase.CreateClientExtended(server.URL, "service-key"))
+
+	// PostgREST runs GET calls in a READ ONLY transaction
+	result, err := executor.QueryReadOnly(context.Background(), "SELECT 1 AS n")
+	if err != nil || len(result.Rows) != 1 {
+		t.Fatalf("QueryReadOnly() = %v, %v", result, err)
+	}
+	if methods[0] != http.MethodGet || queries[0] != "SELECT 1 AS n" {
+		t.Errorf("QueryReadOnly() called execute_sql with %s %q, want GET", methods[0], queries[0])
+	}
+
+	if _, err := executor.QueryReadOnly(context.Background(), "DELETE FROM posts"); err == nil {
+		t.Error("QueryReadOnly(DELETE) succeeded, want an error")
+	}
+	if len(methods) != 1 {
+		t.Errorf("QueryReadOnly(DELETE) called execute_sql")
+	}
+
+	if _, err := executor.Query(context.Background(), "SELECT 1"); err != nil {
+		t.Errorf("Query() = %v, want nil", err)
+	}
+	if len(methods) != 2 || methods[1] != http.MethodPost {
+		t.Errorf("Query() called execute_sql with %v, want POST", methods)
+	}
+}
@@ -0,0 +1,318 @@
+package database
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
+)
+
+// RPCExecutor runs SQL through the execute_sql function exposed by
+// PostgREST. It is used when no direct connection string is configured.
+// The function receives a single query string, so bound arguments are
+// rendered as escaped SQL literals before the call.
+type RPCExecutor struct {
+	client *supabase.SupabaseClientExtended
+}
+
+// NewRPCExecutor creates an executor backed by the execute_sql RPC function
+func NewRPCExecutor(client *supabase.SupabaseClientExtended) *RPCExecutor {
+	return &RPCExecutor{
+		client: client,
+	}
+}
+
+// Mode implements Executor
+func (e *RPCExecutor) Mode() string {
+	return "rpc"
+}
+
+// Close implements Executor
+func (e *RPCExecutor) Close() {}
+
+// Query implements Executor
+func (e *RPCExecutor) Query(ctx context.Context, sql string, args ...interface{}) (*Result, error) {
+	return e.query(ctx, false, sql, args...)
+}
+
+// QueryReadOnly implements Executor. The statement must pass CheckReadOnly,
+// and execute_sql is called with GET, which PostgREST runs in a READ ONLY
+// transaction.
+func (e *RPCExecutor) QueryReadOnly(ctx context.Context, sql string, args ...interface{}) (*Result, error) {
+	if err := CheckReadOnly(sql); err != nil {
+		return nil, err
+	}
+	return e.query(ctx, true, sql, args...)
+}
+
+// query calls execute_sql, with GET when readOnly is set
+func (e *RPCExecutor) query(ctx context.Context, readOnly bool, sql string, args ...interface{}) (*Result, error) {
+	if identityFrom(ctx) != nil {
+		return nil, ErrIdentityUnsupported
+	}
+
+	query, err := Interpolate(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The server-side timeout cannot be set through execute_sql, so an
+	// explicit one only bounds the HTTP call
+	if timeout, ok := ctx.Value(statementTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var raw interface{}
+	if readOnly {
+		err = e.client.Functions().InvokeReadOnly(ctx, "execute_sql", map[string]string{"query": query}, &raw)
+	} else {
+		err = e.client.Functions().Invoke(ctx, "execute_sql", map[string]interface{}{
+			"query": query,
+		}, &raw)
+	}
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	result := &Result{Rows: []map[string]interface{}{}, Columns: []Column{}}
+
+	// execute_sql usually returns the rows as a JSON array; anything else
+	// (e.g. the status of a DDL statement) is returned as a single row
+	switch value := raw.(type) {
+	case []interface{}:
+		for _, item := range value {
+			if row, ok := item.(map[string]interface{}); ok {
+				result.Rows = append(result.Rows, row)
+			} else {
+				result.Rows = append(result.Rows, map[string]interface{}{"value": item})
+			}
+		}
+	case map[string]interface{}:
+		result.Rows = append(result.Rows, value)
+	case nil:
+	default:
+		result.Rows = append(result.Rows, map[string]interface{}{"value": value})
+	}
+
+	// Column types are not reported by the RPC function
+	if len(result.Rows) > 0 {
+		names := make([]string, 0, len(result.Rows[0]))
+		for name := range result.Rows[0] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			result.Columns = append(result.Columns, Column{Name: name})
+		}
+	}
+	result.RowsAffected = int64(len(result.Rows))
+
+	return result, nil
+}
+
+// rpcError reports the database errors relayed by PostgREST as *Error, so
+// they carry their SQLSTATE like errors from a direct connection
+func rpcError(err error) error {
+	var apiErr *supabase.APIError
+	if !errors.As(err, &apiErr) || !isSQLState(apiErr.Code) {
+		return err
+	}
+
+	return &Error{
+		Severity: "ERROR",
+		Code:     apiErr.Code,
+		Message:  apiErr.Message,
+		Detail:   apiErr.Details,
+		Hint:     apiErr.Hint,
+	}
+}
+
+// isSQLState reports whether code is a five character SQLSTATE rather than
+// a PGRST code
+func isSQLState(code string) bool {
+	if len(code) != 5 {
+		return false
+	}
+	for _, r := range code {
+		if (r < '0' || r > '9') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// Interpolate replaces $n placeholders with escaped SQL literals. Dollar
+// signs inside string literals, quoted identifiers, dollar-quoted bodies
+// and comments are left untouched.
+func Interpolate(sql string, args ...interface{}) (string, error) {
+	if len(args) == 0 {
+		return sql, nil
+	}
+
+	var out strings.Builder
+	used := make([]bool, len(args))
+
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+
+		switch {
+		case ch == '\'' || ch == '"':
+			end := skipQuoted(sql, i, ch)
+			out.WriteString(sql[i:end])
+			i = end
+		case ch == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			out.WriteString(sql[i : i+end])
+			i += end
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				end = len(sql) - i
+			} else {
+				end += 4
+			}
+			out.WriteString(sql[i : i+end])
+			i += end
+		case ch == '$':
+			j := i + 1
+			for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			if j > i+1 && (i == 0 || !isIdentChar(sql[i-1])) {
+				n, _ := strconv.Atoi(sql[i+1 : j])
+				if n < 1 || n > len(args) {
+					return "", fmt.Errorf("placeholder $%d has no matching argument", n)
+				}
+				literal, err := Literal(args[n-1])
+				if err != nil {
+					return "", err
+				}
+				used[n-1] = true
+				out.WriteString(literal)
+				i = j
+				continue
+			}
+
+			// Dollar-quoted string: $tag$ ... $tag$
+			if tagEnd := strings.IndexByte(sql[i+1:], '$'); tagEnd >= 0 && isDollarTag(sql[i+1:i+1+tagEnd]) {
+				tag := sql[i : i+tagEnd+2]
+				closing := strings.Index(sql[i+len(tag):], tag)
+				end := len(sql)
+				if closing >= 0 {
+					end = i + len(tag) + closing + len(tag)
+				}
+				out.WriteString(sql[i:end])
+				i = end
+				continue
+			}
+
+			out.WriteByte(ch)
+			i++
+		default:
+			out.WriteByte(ch)
+			i++
+		}
+	}
+
+	for n, ok := range used {
+		if !ok {
+			return "", fmt.Errorf("argument $%d is not referenced by the query", n+1)
+		}
+	}
+
+	return out.String(), nil
+}
+
+// skipQuoted returns the index just past the quoted token starting at i
+func skipQuoted(sql string, i int, quote byte) int {
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] == quote {
+			if j+1 < len(sql) && sql[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+func isDollarTag(tag string) bool {
+	for i := 0; i < len(tag); i++ {
+		if !isIdentChar(tag[i]) || (i == 0 && tag[i] >= '0' && tag[i] <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Literal renders a Go value as a SQL literal, escaping it the way
+// quote_literal does
+func Literal(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return QuoteLiteral(val), nil
+	case bool:
+		if val {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", val), nil
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64), nil
+	case json.Number:
+		if _, err := val.Float64(); err != nil {
+			return "", fmt.Errorf("invalid number %q", val)
+		}
+		return val.String(), nil
+	case time.Time:
+		return QuoteLiteral(val.Format(time.RFC3339Nano)), nil
+	case []byte:
+		return `'\x` + hex.EncodeToString(val) + `'::bytea`, nil
+	case []string:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			items = append(items, QuoteLiteral(item))
+		}
+		return "ARRAY[" + strings.Join(items, ", ") + "]::text[]", nil
+	default:
+		// Maps, slices and structs are passed as JSON documents
+		data, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+		return QuoteLiteral(string(data)) + "::jsonb", nil
+	}
+}
+
+// QuoteLiteral quotes a string as a SQL literal, doubling embedded quotes.
+// Backslashes are escaped with the E” syntax so the result is correct
+// regardless of standard_conforming_strings.
+func QuoteLiteral(s string) string {
+	escaped := strings.ReplaceAll(s, "'", "''")
+	if strings.Contains(escaped, `\`) {
+		return `E'` + strings.ReplaceAll(escaped, `\`, `\\`) + `'`
+	}
+	return "'" + escaped + "'"
+}
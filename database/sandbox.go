@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Sandbox is a transaction that is always rolled back, for trying out
+// changes without keeping them. Each statement runs in its own savepoint,
+// so a failing statement does not abort the statements after it.
+type Sandbox struct {
+	executor   *PostgresExecutor
+	conn       *pgx.Conn
+	savepoints int
+}
+
+// Sandboxer is implemented by executors that can run a sandbox
+type Sandboxer interface {
+	// Sandbox runs fn in a transaction and rolls it back when fn returns
+	Sandbox(ctx context.Context, fn func(sandbox *Sandbox) error) error
+}
+
+// Sandbox implements Sandboxer
+func (e *PostgresExecutor) Sandbox(ctx context.Context, fn func(sandbox *Sandbox) error) error {
+	tx, err := e.pool.Begin(ctx)
+	if err != nil {
+		return convertError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := e.prepareTx(ctx, tx.Conn()); err != nil {
+		return convertError(err)
+	}
+
+	return fn(&Sandbox{executor: e, conn: tx.Conn()})
+}
+
+// Exec runs a statement and keeps its effects for the rest of the sandbox.
+// If it fails, its effects are undone and the sandbox stays usable.
+func (s *Sandbox) Exec(ctx context.Context, sql string, args ...interface{}) (*Result, error) {
+	return s.savepoint(ctx, false, sql, args...)
+}
+
+// Try runs a statement and then undoes its effects, whether it succeeds
+// or not
+func (s *Sandbox) Try(ctx context.Context, sql string, args ...interface{}) (*Result, error) {
+	return s.savepoint(ctx, true, sql, args...)
+}
+
+// SetIdentity switches the rest of the sandbox to identity; nil switches
+// back to the connection's own role
+func (s *Sandbox) SetIdentity(ctx context.Context, identity *Identity) error {
+	if identity == nil {
+		_, err := s.conn.Exec(ctx, "RESET ROLE; SELECT set_config('request.jwt.claims', '', true)")
+		return convertError(err)
+	}
+	return convertError(setIdentity(ctx, s.conn, identity))
+}
+
+func (s *Sandbox) savepoint(ctx context.Context, undo bool, sql string, args ...interface{}) (*Result, error) {
+	s.savepoints++
+	name := fmt.Sprintf("sandbox_%d", s.savepoints)
+
+	if _, err := s.conn.Exec(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, convertError(err)
+	}
+
+	result, err := s.executor.QueryConn(ctx, s.conn, sql, args...)
+	if err != nil || undo {
+		if _, rollbackErr := s.conn.Exec(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil && err == nil {
+			err = convertError(rollbackErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !undo {
+		if _, err := s.conn.Exec(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+			return nil, convertError(err)
+		}
+	}
+	return result, nil
+}
+
+// Reference is a relation or function that a query depends on
+type Reference struct {
+	Kind   string `json:"kind"`
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+}
+
+// referencesSQL lists what the rule of the sandbox_references view depends
+// on. Built-in objects are pinned and have no pg_depend entries, so only
+// relations and functions created in the database are reported.
+const referencesSQL = `
+	SELECT 'relation' AS kind, n.nspname AS schema, c.relname AS name
+	FROM pg_rewrite r
+	JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = r.oid AND d.refclassid = 'pg_class'::regclass
+	JOIN pg_class c ON c.oid = d.refobjid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE r.ev_class = 'pg_temp.sandbox_references'::regclass AND c.oid <> r.ev_class
+	UNION
+	SELECT 'function', n.nspname, p.proname
+	FROM pg_rewrite r
+	JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = r.oid AND d.refclassid = 'pg_proc'::regclass
+	JOIN pg_proc p ON p.oid = d.refobjid
+	JOIN pg_namespace n ON n.oid = p.pronamespace
+	WHERE r.ev_class = 'pg_temp.sandbox_references'::regclass
+	ORDER BY 1, 2, 3`
+
+// References lists the relations and functions a SELECT query refers to,
+// as resolved by the Postgres parser. The query is turned into a temporary
+// view to read its dependencies, and the view is dropped again.
+func (s *Sandbox) References(ctx context.Context, query string) ([]Reference, error) {
+	s.savepoints++
+	name := fmt.Sprintf("sandbox_%d", s.savepoints)
+
+	if _, err := s.conn.Exec(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, convertError(err)
+	}
+	defer s.conn.Exec(ctx, "ROLLBACK TO SAVEPOINT "+name)
+
+	// The extended protocol refuses more than one statement
+	if err := s.conn.PgConn().ExecParams(ctx, "CREATE TEMP VIEW sandbox_references AS "+query, nil, nil, nil, nil).Read().Err; err != nil {
+		return nil, convertError(err)
+	}
+
+	result, err := s.executor.QueryConn(ctx, s.conn, referencesSQL)
+	if err != nil {
+		return nil, err
+	}
+	var references []Reference
+	if err := result.Decode(&references); err != nil {
+		return nil, err
+	}
+	return references, nil
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QuoteIdent quotes an identifier the way quote_ident does: the name is
+// wrapped in double quotes and embedded double quotes are doubled, so any
+// input is treated as a single name
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QualifiedName quotes each non-empty part and joins them with dots,
+// e.g. QualifiedName("public", "users") returns "public"."users"
+func QualifiedName(parts ...string) string {
+	quoted := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			quoted = append(quoted, QuoteIdent(part))
+		}
+	}
+	return strings.Join(quoted, ".")
+}
+
+// Atomic combines statements into a single one that applies all of them or
+// none, even where each statement would otherwise be committed on its own.
+// One statement is returned as is; several are executed from a DO block.
+func Atomic(statements ...string) string {
+	if len(statements) == 1 {
+		return statements[0]
+	}
+
+	var body strings.Builder
+	for _, statement := range statements {
+		body.WriteString("\tEXECUTE " + QuoteLiteral(statement) + ";\n")
+	}
+
+	// The dollar quote must not appear in the statements
+	tag := "$$"
+	for i := 0; strings.Contains(body.String(), tag); i++ {
+		tag = fmt.Sprintf("$atomic%d$", i)
+	}
+	return "DO " + tag + "\nBEGIN\n" + body.String() + "END\n" + tag
+}
+
+// Query builds a SQL statement while keeping values apart from the text.
+// Values are appended as $n placeholders and returned by Args; identifiers
+// are quoted with QuoteIdent.
+type Query struct {
+	sql  strings.Builder
+	args []interface{}
+}
+
+// NewQuery creates a query starting with the given SQL text
+func NewQuery(sql string) *Query {
+	q := &Query{}
+	q.sql.WriteString(sql)
+	return q
+}
+
+// Write appends trusted SQL text
+func (q *Query) Write(sql string) *Query {
+	q.sql.WriteString(sql)
+	return q
+}
+
+// Ident appends a quoted, optionally schema-qualified identifier
+func (q *Query) Ident(parts ...string) *Query {
+	q.sql.WriteString(QualifiedName(parts...))
+	return q
+}
+
+// Arg appends a placeholder bound to value
+func (q *Query) Arg(value interface{}) *Query {
+	q.sql.WriteString(q.Placeholder(value))
+	return q
+}
+
+// Placeholder registers value as an argument and returns its placeholder
+// without writing it, for callers that assemble the text themselves
+func (q *Query) Placeholder(value interface{}) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// SQL returns the statement text
+func (q *Query) SQL() string {
+	return q.sql.String()
+}
+
+// Args returns the values bound to the placeholders
+func (q *Query) Args() []interface{} {
+	return q.args
+}
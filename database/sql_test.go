@@ -0,0 +1,65 @@
+package database
+
+import "testing"
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"users", `"users"`},
+		{"Users", `"Users"`},
+		{`a"b`, `"a""b"`},
+		{`x"; DROP TABLE users; --`, `"x""; DROP TABLE users; --"`},
+		{`U&"\0070g_sleep"`, `"U&""\0070g_sleep"""`},
+		{`$$; DROP TABLE users; $$`, `"$$; DROP TABLE users; $$"`},
+		{`x'; SELECT 1; --`, `"x'; SELECT 1; --"`},
+		{"", `""`},
+	}
+
+	for _, tt := range tests {
+		if got := QuoteIdent(tt.name); got != tt.want {
+			t.Errorf("QuoteIdent(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQualifiedName(t *testing.T) {
+	if got, want := QualifiedName("public", `a"."b`), `"public"."a"".""b"`; got != want {
+		t.Errorf("QualifiedName = %s, want %s", got, want)
+	}
+	if got, want := QualifiedName("", "users"), `"users"`; got != want {
+		t.Errorf("QualifiedName = %s, want %s", got, want)
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"avatars", `'avatars'`},
+		{`it's`, `'it''s'`},
+		{`x'; DROP TABLE users; --`, `'x''; DROP TABLE users; --'`},
+		{`x\'; DROP TABLE users; --`, `E'x\\''; DROP TABLE users; --'`},
+		{`$$`, `'$$'`},
+	}
+
+	for _, tt := range tests {
+		if got := QuoteLiteral(tt.value); got != tt.want {
+			t.Errorf("QuoteLiteral(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAtomicDollarTag(t *testing.T) {
+	sql := Atomic(`SELECT 1`, `COMMENT ON TABLE t IS '$$; DROP TABLE users; $$'`)
+	tokens, err := tokenize(sql, false)
+	if err != nil {
+		t.Fatalf("tokenize(%q): %v", sql, err)
+	}
+	// DO, then the body as a single dollar-quoted string
+	if len(tokens) != 2 || tokens[1].kind != tokenString {
+		t.Fatalf("Atomic did not produce a single DO body: %q", sql)
+	}
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout returns a context whose SQL statements are cancelled
+// by the server after d, overriding the executor's default
+func WithStatementTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, d)
+}
+
+// statementTimeout returns the timeout for the statements run with ctx
+func (e *PostgresExecutor) statementTimeout(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(statementTimeoutKey{}).(time.Duration); ok {
+		return d
+	}
+	return e.defaultTimeout
+}
+
+// setStatementTimeout sets statement_timeout on a connection, for the
+// current transaction only when local is true. Transaction-scoped settings
+// also work through poolers such as Supavisor in transaction mode.
+func setStatementTimeout(ctx context.Context, conn *pgx.Conn, d time.Duration, local bool) error {
+	ms := strconv.FormatInt(d.Milliseconds(), 10)
+	_, err := conn.Exec(ctx, "SELECT set_config('statement_timeout', $1, $2)", ms, local)
+	return err
+}
@@ -0,0 +1,326 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Notice represents a NOTICE, WARNING or similar message raised by the
+// server while a statement runs
+type Notice struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+	Detail   string `json:"detail,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// ScriptResult represents the outcome of a script run in a transaction
+type ScriptResult struct {
+	Statements []*Result `json:"statements"`
+	Notices    []Notice  `json:"notices"`
+	Committed  bool      `json:"committed"`
+}
+
+// Transactor is implemented by executors that can run a script inside an
+// explicit transaction and decide whether to commit it
+type Transactor interface {
+	// ExecScript runs one or more statements in a single transaction,
+	// committing it when commit is true and rolling it back otherwise
+	ExecScript(ctx context.Context, sql string, commit bool) (*ScriptResult, error)
+}
+
+// CheckTransactional rejects transaction control statements, which would
+// end the transaction a script runs in and defeat a dry run, and
+// session-level SET and RESET, whose effect would outlive the transaction.
+// Calls to the functions on the read-only deny list and COPY to or from a
+// program or a server file are rejected as well: their effects, such as
+// terminated backends, advanced sequences or written files, are not undone
+// by a rollback, so they would already happen during a dry run. The bodies
+// of DO blocks are checked too.
+func CheckTransactional(sql string) error {
+	tokens, err := tokenize(sql, true)
+	if err != nil {
+		return fmt.Errorf("unable to parse query: %v", err)
+	}
+
+	statements, err := splitStatements(tokens)
+	if err != nil {
+		return err
+	}
+	if len(statements) == 0 {
+		return fmt.Errorf("query is empty")
+	}
+
+	for _, statement := range statements {
+		if err := checkScriptStatement(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkScriptStatement checks one top-level statement of a script
+func checkScriptStatement(statement []token) error {
+	if !definesOnly(statement) {
+		if err := checkSideEffects(statement); err != nil {
+			return err
+		}
+	}
+
+	switch keywordOf(statement[0]) {
+	case "begin", "start", "commit", "end", "rollback", "abort":
+		return fmt.Errorf("transaction control statements (%s) are not allowed; the script already runs in a transaction", strings.ToUpper(statement[0].text))
+	case "prepare":
+		if len(statement) > 1 && keywordOf(statement[1]) == "transaction" {
+			return fmt.Errorf("transaction control statements (PREPARE TRANSACTION) are not allowed; the script already runs in a transaction")
+		}
+	case "set", "reset":
+		return checkSessionSet(statement)
+	case "copy":
+		return checkCopy(statement)
+	case "do":
+		return checkDoBlock(statement)
+	}
+	return nil
+}
+
+// checkSideEffects rejects calls to the functions on the read-only deny
+// list
+func checkSideEffects(tokens []token) error {
+	for i, tok := range tokens {
+		if (tok.kind == tokenIdent || tok.kind == tokenQuotedIdent) && i+1 < len(tokens) && isSymbol(tokens[i+1], "(") {
+			if name := functionName(tokens, i); isDeniedFunction(name) {
+				return fmt.Errorf("function %s is not allowed because its effects are not rolled back", name)
+			}
+		}
+	}
+	return nil
+}
+
+// definesOnly reports whether a statement creates a table, view, function
+// or procedure without evaluating any of the expressions it holds, such as
+// DEFAULT nextval(...)
+func definesOnly(statement []token) bool {
+	i := 1
+	if i+1 < len(statement) && keywordOf(statement[i]) == "or" && keywordOf(statement[i+1]) == "replace" {
+		i += 2
+	}
+	for i < len(statement) {
+		switch keywordOf(statement[i]) {
+		case "temp", "temporary", "unlogged", "recursive":
+			i++
+			continue
+		}
+		break
+	}
+	if keywordOf(statement[0]) != "create" || i >= len(statement) {
+		return false
+	}
+
+	switch keywordOf(statement[i]) {
+	case "view", "function", "procedure":
+		return true
+	case "table":
+		// CREATE TABLE ... AS runs its query
+		depth := 0
+		for _, tok := range statement[i:] {
+			switch {
+			case isSymbol(tok, "("):
+				depth++
+			case isSymbol(tok, ")"):
+				depth--
+			case depth == 0 && keywordOf(tok) == "as":
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// checkCopy rejects COPY to or from a program or a file on the server.
+// COPY with STDIN or STDOUT cannot run in a script anyway.
+func checkCopy(statement []token) error {
+	for i, tok := range statement {
+		switch keywordOf(tok) {
+		case "program":
+			return fmt.Errorf("COPY ... PROGRAM is not allowed")
+		case "to", "from":
+			if i+1 < len(statement) && statement[i+1].kind == tokenString {
+				return fmt.Errorf("COPY to or from a server file is not allowed")
+			}
+		}
+	}
+	return nil
+}
+
+// checkSessionSet accepts SET LOCAL, SET TRANSACTION and SET CONSTRAINTS,
+// which end with the transaction, and rejects other forms of SET and RESET
+func checkSessionSet(statement []token) error {
+	if keywordOf(statement[0]) == "reset" {
+		return fmt.Errorf("RESET is not allowed because it changes the session; use SET LOCAL ... TO DEFAULT")
+	}
+	if len(statement) > 1 {
+		switch keywordOf(statement[1]) {
+		case "local", "transaction", "constraints":
+			return nil
+		}
+	}
+	return fmt.Errorf("session-level SET is not allowed because it would outlive the transaction; use SET LOCAL")
+}
+
+// checkDoBlock looks for COMMIT, ROLLBACK and session-level SET at the
+// start of the statements of a DO block. A body the tokenizer cannot read
+// is left to the server.
+func checkDoBlock(statement []token) error {
+	var body string
+	for _, tok := range statement[1:] {
+		if tok.kind == tokenString {
+			body = stringBody(tok.text)
+			break
+		}
+	}
+
+	tokens, err := tokenize(body, true)
+	if err != nil {
+		return nil
+	}
+
+	if err := checkSideEffects(tokens); err != nil {
+		return fmt.Errorf("DO block: %v", err)
+	}
+
+	start := true
+	for i, tok := range tokens {
+		keyword := keywordOf(tok)
+		if start {
+			switch keyword {
+			case "commit", "rollback":
+				return fmt.Errorf("transaction control statements (%s) inside DO blocks are not allowed; the script already runs in a transaction", strings.ToUpper(tok.text))
+			case "set", "reset":
+				if err := checkSessionSet(tokens[i:]); err != nil {
+					return fmt.Errorf("DO block: %v", err)
+				}
+			}
+		}
+		// PL/pgSQL statements follow a semicolon or open a block or branch
+		switch keyword {
+		case "begin", "then", "else", "loop":
+			start = true
+		default:
+			start = isSymbol(tok, ";")
+		}
+	}
+	return nil
+}
+
+// stringBody returns the content of a string literal token
+func stringBody(text string) string {
+	switch {
+	case strings.HasPrefix(text, "$"):
+		tag := text[:strings.IndexByte(text[1:], '$')+2]
+		return text[len(tag) : len(text)-len(tag)]
+	case strings.HasPrefix(text, "'"):
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+	case strings.HasPrefix(text, "E'") || strings.HasPrefix(text, "e'"):
+		body := strings.ReplaceAll(text[2:len(text)-1], "''", "'")
+		return strings.ReplaceAll(strings.ReplaceAll(body, `\'`, "'"), `\\`, `\`)
+	}
+	return ""
+}
+
+// noticeCollector gathers the notices raised on one connection
+type noticeCollector struct {
+	mu      sync.Mutex
+	notices []Notice
+}
+
+func (nc *noticeCollector) list() []Notice {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	return append([]Notice{}, nc.notices...)
+}
+
+// handleNotice is the pool's notice callback; it forwards notices to the
+// collector registered for the connection, if any
+func (e *PostgresExecutor) handleNotice(conn *pgconn.PgConn, notice *pgconn.Notice) {
+	value, ok := e.notices.Load(conn)
+	if !ok {
+		return
+	}
+
+	collector := value.(*noticeCollector)
+	collector.mu.Lock()
+	collector.notices = append(collector.notices, Notice{
+		Severity: notice.Severity,
+		Code:     notice.Code,
+		Message:  notice.Message,
+		Detail:   notice.Detail,
+		Hint:     notice.Hint,
+	})
+	collector.mu.Unlock()
+}
+
+// ExecScript implements Transactor
+func (e *PostgresExecutor) ExecScript(ctx context.Context, sql string, commit bool) (*ScriptResult, error) {
+	if err := CheckTransactional(sql); err != nil {
+		return nil, err
+	}
+
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	defer conn.Release()
+	// The script may still have changed the session, e.g. with set_config,
+	// a temporary table or an advisory lock, so its connection is closed
+	// rather than returned to the pool
+	defer conn.Conn().Close(context.Background())
+
+	pgConn := conn.Conn().PgConn()
+	collector := &noticeCollector{}
+	e.notices.Store(pgConn, collector)
+	defer e.notices.Delete(pgConn)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	// Rolling back after a commit is a no-op
+	defer tx.Rollback(ctx)
+
+	if err := e.prepareTx(ctx, conn.Conn()); err != nil {
+		return nil, convertError(err)
+	}
+
+	results, err := pgConn.Exec(ctx, sql).ReadAll()
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	script := &ScriptResult{Statements: make([]*Result, 0, len(results))}
+	for _, raw := range results {
+		result, err := e.decodeResult(ctx, conn.Conn(), raw)
+		if err != nil {
+			return nil, convertError(err)
+		}
+		script.Statements = append(script.Statements, result)
+	}
+
+	if commit {
+		if err := tx.Commit(ctx); err != nil {
+			return nil, convertError(err)
+		}
+		script.Committed = true
+	} else if err := tx.Rollback(ctx); err != nil {
+		return nil, convertError(err)
+	}
+
+	script.Notices = collector.list()
+	return script, nil
+}
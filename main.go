@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/dirgocs/supabase-self-hosted-mcp/auth"
 	"github.com/dirgocs/supabase-self-hosted-mcp/config"
 	"github.com/dirgocs/supabase-self-hosted-mcp/controllers"
+	"github.com/dirgocs/supabase-self-hosted-mcp/database"
+	"github.com/dirgocs/supabase-self-hosted-mcp/mcp"
 	"github.com/dirgocs/supabase-self-hosted-mcp/supabase"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -16,6 +23,11 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	serverName    = "Supabase Self-Hosted MCP Server"
+	serverVersion = "1.0.0"
+)
+
 func main() {
 	// Load environment variables
 	err := godotenv.Load()
@@ -26,12 +38,67 @@ func main() {
 	// Initialize configuration
 	cfg := config.LoadConfig()
 
+	// The transport can also be selected on the command line,
+	// e.g. when the server is spawned by an editor
+	flag.StringVar(&cfg.Server.Transport, "transport", cfg.Server.Transport, "MCP transport: http or stdio")
+	flag.Parse()
+
+	if cfg.Server.Transport == config.TransportStdio {
+		// stdout carries the protocol stream, so every log goes to stderr
+		log.SetOutput(os.Stderr)
+		logrus.SetOutput(os.Stderr)
+		gin.DefaultWriter = os.Stderr
+		gin.DefaultErrorWriter = os.Stderr
+		gin.SetMode(gin.ReleaseMode)
+	}
+
 	// Initialize extended Supabase client with Functions support
-	supabaseClient := supabase.CreateClientExtended(cfg.Supabase.URL, cfg.Supabase.Key)
+	clientOptions := []supabase.ClientOption{
+		supabase.WithHTTPClient(supabase.NewHTTPClient(cfg.Supabase.HTTPTimeout, cfg.Supabase.MaxIdleConns)),
+		supabase.WithRetries(cfg.Supabase.MaxRetries),
+		supabase.WithAnonKey(cfg.Supabase.AnonKey),
+	}
+	if cfg.Storage.TUSStub {
+		tusStub, err := supabase.StartTUSStub(cfg.Storage.TUSStubDir)
+		if err != nil {
+			log.Fatalf("Failed to start the TUS stub: %v", err)
+		}
+		defer tusStub.Close()
+		log.Printf("Warning: STORAGE_TUS_STUB is set; resumable uploads are written to %s instead of Supabase Storage", cfg.Storage.TUSStubDir)
+		clientOptions = append(clientOptions, supabase.WithTUSEndpoint(tusStub.URL))
+	}
+	supabaseClient := supabase.CreateClientExtended(cfg.Supabase.URL, cfg.Supabase.Key, clientOptions...)
 	
 	// The headers are already set up in the CreateClientExtended function
 	// No need to manually set them here
 
+	// Open the SQL executor: a native pool when PG_CONNECTION_STRING is set,
+	// otherwise the execute_sql RPC function
+	db, err := database.Open(context.Background(), cfg, supabaseClient)
+	if err != nil {
+		log.Fatalf("Failed to open database connection: %v", err)
+	}
+	defer db.Close()
+
+	// Register every tool once; the registry backs the REST routes,
+	// the MCP HTTP endpoint and the stdio transport
+	registry := mcp.NewRegistry()
+	controllers.RegisterTools(registry, cfg, supabaseClient, db)
+	mcpServer := mcp.NewServer(serverName, serverVersion, registry)
+
+	if cfg.Server.Transport == config.TransportStdio {
+		// The client spawned the server, so stdio needs no credentials
+		runStdio(mcpServer)
+		return
+	}
+
+	authenticator, err := auth.NewAuthenticator(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load API keys: %v", err)
+	}
+	registry.SetGuard(authenticator)
+	registry.SetMaxBodySize(cfg.Server.MaxRequestSize)
+
 	// Set up Gin router
 	router := gin.Default()
 
@@ -58,14 +125,26 @@ func main() {
 	}
 	router.SetTrustedProxies(trustedProxies)
 
-	// Apply CORS middleware
-	router.Use(cors.Default())
+	// Apply CORS middleware for the configured origins only, allowing
+	// browser-based MCP clients to send credentials and read the session
+	// headers. Without CORS_ALLOWED_ORIGINS browsers get no CORS access.
+	if len(cfg.Auth.AllowedOrigins) > 0 {
+		corsConfig := cors.DefaultConfig()
+		if len(cfg.Auth.AllowedOrigins) == 1 && cfg.Auth.AllowedOrigins[0] == "*" {
+			corsConfig.AllowAllOrigins = true
+		} else {
+			corsConfig.AllowOrigins = cfg.Auth.AllowedOrigins
+		}
+		corsConfig.AddAllowHeaders("Authorization", mcp.SessionHeader, "Mcp-Protocol-Version", "Last-Event-ID", "Accept")
+		corsConfig.AddExposeHeaders(mcp.SessionHeader, "WWW-Authenticate")
+		router.Use(cors.New(corsConfig))
+	}
 
 	// MCP Server info endpoint
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
-			"name":        "Supabase Self-Hosted MCP Server",
-			"version":     "1.0.0",
+			"name":        serverName,
+			"version":     serverVersion,
 			"description": "MCP Server para comunicação com Supabase Self-Hosted",
 			"status":      "running",
 		})
@@ -76,8 +155,18 @@ func main() {
 		// Check if Supabase is accessible
 		supabaseStatus := "unknown"
 		
-		// Try to make a simple request to Supabase
-		resp, err := http.Get(cfg.Supabase.URL)
+		// Try to make a simple request to Supabase, giving up when the
+		// caller does
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+		var resp *http.Response
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Supabase.URL, nil)
+		if err == nil {
+			resp, err = supabaseClient.HTTPClient().Do(req)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
 		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
 			supabaseStatus = "connected"
 		} else {
@@ -88,56 +177,51 @@ func main() {
 			"status": "healthy",
 			"timestamp": time.Now().Format(time.RFC3339),
 			"supabase": supabaseStatus,
+			"database": db.Mode(),
 		})
 	})
 
+	// OAuth protected resource metadata, telling MCP clients where to get
+	// a token (at the root and at the path of the MCP endpoint)
+	router.GET(auth.ProtectedResourcePath, authenticator.HandleProtectedResource)
+	router.GET(auth.ProtectedResourcePath+"/mcp", authenticator.HandleProtectedResource)
+
+	if cfg.Auth.StubIssuer {
+		stubIssuer, err := auth.NewStubIssuer(cfg)
+		if err != nil {
+			log.Fatalf("Failed to start the stub issuer: %v", err)
+		}
+		stubIssuer.Register(router)
+		authenticator.TrustStubIssuer(stubIssuer)
+	}
+
+	// Every other route requires an API key or a JWT; the tools and
+	// schemas a caller may use are enforced by the registry's guard
+	protected := router.Group("/", authenticator.Middleware())
+
 	// MCP specification endpoint
-	router.GET("/v1/specification", controllers.GetMCPSpecification)
-
-	// Register database endpoints
-	dbController := controllers.NewDatabaseController(supabaseClient)
-	router.POST("/v1/execute_query", dbController.ExecuteQuery)
-	router.POST("/v1/get_database_schema", dbController.GetDatabaseSchema)
-	router.POST("/v1/create_schema", dbController.CreateSchema)
-	router.POST("/v1/delete_schema", dbController.DeleteSchema)
-	router.POST("/v1/get_rls_policies", dbController.GetRLSPolicies)
-	router.POST("/v1/create_rls_policy", dbController.CreateRLSPolicy)
-	router.POST("/v1/update_rls_policy", dbController.UpdateRLSPolicy)
-	router.POST("/v1/delete_rls_policy", dbController.DeleteRLSPolicy)
-
-	// Register table endpoints
-	tableController := controllers.NewTableController(supabaseClient)
-	router.POST("/v1/query_table", tableController.QueryTable)
-	router.POST("/v1/generate_types", tableController.GenerateTypes)
-	router.POST("/v1/list_tables", tableController.ListTables)
-	router.POST("/v1/create_table", tableController.CreateTable)
-	router.POST("/v1/alter_table", tableController.AlterTable)
-	router.POST("/v1/drop_table", tableController.DropTable)
-
-	// Register storage endpoints
-	storageController := controllers.NewStorageController(supabaseClient)
-	router.POST("/v1/get_buckets", storageController.GetBuckets)
-	router.POST("/v1/create_bucket", storageController.CreateBucket)
-	router.POST("/v1/update_bucket", storageController.UpdateBucket)
-	router.POST("/v1/delete_bucket", storageController.DeleteBucket)
-	router.POST("/v1/get_bucket_policies", storageController.GetBucketPolicies)
-	router.POST("/v1/create_bucket_policy", storageController.CreateBucketPolicy)
-	router.POST("/v1/update_bucket_policy", storageController.UpdateBucketPolicy)
-	router.POST("/v1/delete_bucket_policy", storageController.DeleteBucketPolicy)
-
-	// Register edge function endpoints
-	edgeFunctionsController := controllers.NewEdgeFunctionsController(supabaseClient)
-	router.POST("/v1/get_edge_functions", edgeFunctionsController.GetEdgeFunctions)
-	router.POST("/v1/create_edge_function", edgeFunctionsController.CreateEdgeFunction)
-	router.POST("/v1/update_edge_function", edgeFunctionsController.UpdateEdgeFunction)
-	router.POST("/v1/delete_edge_function", edgeFunctionsController.DeleteEdgeFunction)
-	router.POST("/v1/deploy_edge_function", edgeFunctionsController.DeployEdgeFunction)
+	protected.GET("/v1/specification", registry.HandleSpecification)
+
+	for _, tool := range registry.Tools() {
+		protected.POST("/v1/"+tool.Name, registry.Handlers(tool.Name)...)
+	}
+
+	// MCP Streamable HTTP endpoint
+	protected.POST("/mcp", mcpServer.HandleHTTP)
+	protected.GET("/mcp", mcpServer.HandleStream)
+	protected.DELETE("/mcp", mcpServer.HandleDeleteSession)
+
+	// Legacy HTTP+SSE endpoints for clients predating Streamable HTTP
+	protected.GET("/sse", mcpServer.HandleLegacySSE)
+	protected.POST("/messages", mcpServer.HandleLegacyMessage)
 
 	// Start the server
 	port := fmt.Sprintf(":%d", cfg.Server.Port)
 	log.Printf("Supabase Self-Hosted MCP Server running on port %d", cfg.Server.Port)
 	log.Printf("Server URL: http://localhost%s", port)
 	log.Printf("MCP Specification URL: http://localhost%s/v1/specification", port)
+	log.Printf("MCP endpoint: http://localhost%s/mcp", port)
+	log.Printf("Legacy MCP SSE endpoint: http://localhost%s/sse", port)
 	log.Printf("Supabase URL: %s", cfg.Supabase.URL)
 
 	if err := router.Run(port); err != nil {
@@ -145,3 +229,15 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runStdio serves MCP over stdin/stdout until the client closes the stream
+func runStdio(server *mcp.Server) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Supabase Self-Hosted MCP Server running on stdio")
+
+	if err := server.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+		log.Fatalf("stdio transport stopped: %v", err)
+	}
+}
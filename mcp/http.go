@@ -0,0 +1,311 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHeader carries the session id of the Streamable HTTP transport
+const SessionHeader = "Mcp-Session-Id"
+
+// keepAliveInterval is how often an idle SSE stream receives a comment so
+// that proxies such as Cloudflare do not close it
+const keepAliveInterval = 15 * time.Second
+
+// messageInfo summarises the JSON-RPC messages in a request body
+type messageInfo struct {
+	initialize bool
+	requests   bool
+	toolCall   bool
+}
+
+// inspectMessage reports what kind of JSON-RPC messages a body contains
+func inspectMessage(body []byte) messageInfo {
+	var info messageInfo
+	var requests []Request
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &requests); err != nil {
+			return messageInfo{requests: true}
+		}
+	} else {
+		var req Request
+		if err := json.Unmarshal(trimmed, &req); err != nil {
+			// Let HandleMessage produce the parse error
+			return messageInfo{requests: true}
+		}
+		requests = []Request{req}
+	}
+
+	for _, req := range requests {
+		if req.Method == "" || req.IsNotification() {
+			continue
+		}
+		info.requests = true
+		switch req.Method {
+		case "initialize":
+			info.initialize = true
+		case "tools/call":
+			info.toolCall = true
+		}
+	}
+	return info
+}
+
+// acceptsEventStream reports whether the client accepts an SSE response
+func acceptsEventStream(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// HandleHTTP serves JSON-RPC messages posted to the Streamable HTTP
+// endpoint. An initialize request starts a new session whose id is
+// returned in the Mcp-Session-Id header; later requests may carry it.
+// Tool calls are answered over SSE when the client accepts it, which
+// keeps long-running calls alive behind proxies; everything else is
+// answered with plain JSON.
+func (s *Server) HandleHTTP(c *gin.Context) {
+	s.registry.limitBody(c)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(bodyErrorStatus(err), newError(nil, ParseError, "Parse error", err.Error()))
+		return
+	}
+
+	info := inspectMessage(body)
+	ctx := c.Request.Context()
+
+	if info.initialize {
+		sess := s.sessions.create(s.registry.callerID(ctx))
+		c.Header(SessionHeader, sess.id)
+	} else if id := c.GetHeader(SessionHeader); id != "" {
+		sess, ok := s.sessions.get(id, s.registry.callerID(ctx))
+		if !ok {
+			c.JSON(http.StatusNotFound, newError(nil, InvalidRequest, "Session not found", nil))
+			return
+		}
+
+		// Ending the session cancels its in-flight requests
+		var cancel context.CancelFunc
+		ctx, cancel = sessionContext(ctx, sess)
+		defer cancel()
+	}
+
+	if !info.requests {
+		// Notifications and client responses are acknowledged without a body
+		s.HandleMessage(ctx, body)
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	if info.toolCall && acceptsEventStream(c) {
+		s.streamReply(ctx, c, body)
+		return
+	}
+
+	reply := s.HandleMessage(ctx, body)
+	if reply == nil {
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", reply)
+}
+
+// streamReply answers a request over a short-lived SSE stream, sending
+// keep-alive comments until the reply is ready
+func (s *Server) streamReply(ctx context.Context, c *gin.Context, body []byte) {
+	replies := make(chan []byte, 1)
+
+	go func() {
+		replies <- s.HandleMessage(ctx, body)
+	}()
+
+	startEventStream(c)
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			writeComment(c, "keep-alive")
+		case reply := <-replies:
+			if reply != nil {
+				writeEvent(c, "message", reply)
+			}
+			return
+		}
+	}
+}
+
+// HandleStream opens an SSE stream for server-initiated messages on an
+// existing session
+func (s *Server) HandleStream(c *gin.Context) {
+	if !acceptsEventStream(c) {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "Accept header must include text/event-stream"})
+		return
+	}
+
+	id := c.GetHeader(SessionHeader)
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": SessionHeader + " header is required"})
+		return
+	}
+
+	sess, ok := s.sessions.get(id, s.registry.callerID(c.Request.Context()))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	startEventStream(c)
+	s.pump(c, sess)
+}
+
+// HandleDeleteSession terminates a session at the client's request
+func (s *Server) HandleDeleteSession(c *gin.Context) {
+	id := c.GetHeader(SessionHeader)
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": SessionHeader + " header is required"})
+		return
+	}
+
+	if !s.sessions.remove(id, s.registry.callerID(c.Request.Context())) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// HandleLegacySSE implements the GET side of the 2024-11-05 HTTP+SSE
+// transport: it opens a session, announces the endpoint the client must
+// post messages to, and streams the replies
+func (s *Server) HandleLegacySSE(c *gin.Context) {
+	sess := s.sessions.create(s.registry.callerID(c.Request.Context()))
+	defer s.sessions.remove(sess.id, sess.owner)
+
+	startEventStream(c)
+	writeEvent(c, "endpoint", []byte(fmt.Sprintf("/messages?sessionId=%s", sess.id)))
+	s.pump(c, sess)
+}
+
+// HandleLegacyMessage implements the POST side of the 2024-11-05 HTTP+SSE
+// transport. Messages are accepted immediately and the reply is delivered
+// on the session's SSE stream.
+func (s *Server) HandleLegacyMessage(c *gin.Context) {
+	sess, ok := s.sessions.get(c.Query("sessionId"), s.registry.callerID(c.Request.Context()))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	s.registry.limitBody(c)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(bodyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// The reply outlives this request, but the caller's identity must
+	// still reach the tools
+	ctx := valuesFrom(sess.ctx, c.Request.Context())
+	go func() {
+		if reply := s.HandleMessage(ctx, body); reply != nil {
+			sess.send(reply)
+		}
+	}()
+
+	c.String(http.StatusAccepted, "Accepted")
+}
+
+// pump forwards queued session messages to the client until either side
+// goes away
+func (s *Server) pump(c *gin.Context, sess *session) {
+	sess.openStream()
+	defer sess.closeStream()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-sess.ctx.Done():
+			return
+		case <-ticker.C:
+			writeComment(c, "keep-alive")
+		case message := <-sess.outbox:
+			writeEvent(c, "message", message)
+		}
+	}
+}
+
+// startEventStream writes the SSE response headers
+func startEventStream(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Disable response buffering in nginx-based proxies
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// writeEvent writes a single SSE event and flushes it to the client
+func writeEvent(c *gin.Context, event string, data []byte) {
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, data)
+	c.Writer.Flush()
+}
+
+// writeComment writes an SSE comment line, ignored by clients
+func writeComment(c *gin.Context, comment string) {
+	fmt.Fprintf(c.Writer, ": %s\n\n", comment)
+	c.Writer.Flush()
+}
+
+// sessionContext returns a context that ends with either the request or
+// the session
+func sessionContext(ctx context.Context, sess *session) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-sess.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// valuesContext ends with its parent but also resolves values from another
+// context
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+// valuesFrom returns a context that ends with ctx and carries the values of
+// both contexts, preferring those of values
+func valuesFrom(ctx, values context.Context) context.Context {
+	return valuesContext{Context: ctx, values: values}
+}
+
+func (c valuesContext) Value(key interface{}) interface{} {
+	if value := c.values.Value(key); value != nil {
+		return value
+	}
+	return c.Context.Value(key)
+}
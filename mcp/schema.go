@@ -0,0 +1,242 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema used to describe tool arguments
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// rawMessageType is handled as "any value"
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// SchemaFor derives a JSON Schema from a Go request struct. Field names
+// come from the json tag; the description tag documents a field and the
+// jsonschema tag holds comma-separated constraints:
+//
+//	required        the field must be present (and non-empty for strings)
+//	enum=a|b|c      the value must be one of the listed values
+//	default=value   value applied when the field is omitted
+//	minimum=n       lower bound for numbers
+//	maximum=n       upper bound for numbers
+//	minItems=n      lower bound for array length
+//
+// Recursive types are emitted once under $defs and referenced by $ref.
+func SchemaFor(v interface{}) *Schema {
+	g := &schemaGenerator{
+		defs:      make(map[string]*Schema),
+		visiting:  make(map[reflect.Type]bool),
+		recursive: make(map[reflect.Type]bool),
+	}
+
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil {
+		return &Schema{Type: "object", Properties: map[string]*Schema{}}
+	}
+
+	schema := g.generate(t)
+	if len(g.defs) > 0 {
+		schema.Defs = g.defs
+	}
+	return schema
+}
+
+// schemaGenerator keeps the state needed to handle recursive types
+type schemaGenerator struct {
+	defs      map[string]*Schema
+	visiting  map[reflect.Type]bool
+	recursive map[reflect.Type]bool
+}
+
+// generate builds the schema of a Go type
+func (g *schemaGenerator) generate(t reflect.Type) *Schema {
+	if t == rawMessageType {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.generate(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.generate(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.generate(t.Elem())}
+	case reflect.Struct:
+		return g.generateStruct(t)
+	default:
+		// interface{} and anything else accepts any JSON value
+		return &Schema{}
+	}
+}
+
+// generateStruct builds an object schema from the exported fields of a struct
+func (g *schemaGenerator) generateStruct(t reflect.Type) *Schema {
+	if g.visiting[t] {
+		g.recursive[t] = true
+		return &Schema{Ref: "#/$defs/" + t.Name()}
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	schema := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		prop := g.generate(field.Type)
+		prop.Description = field.Tag.Get("description")
+
+		if err := applyConstraints(prop, field); err != nil {
+			panic(fmt.Sprintf("mcp: %s.%s: %v", t.Name(), field.Name, err))
+		}
+
+		if hasConstraint(field, "required") {
+			schema.Required = append(schema.Required, name)
+			if prop.Type == "string" && prop.MinLength == nil {
+				one := 1
+				prop.MinLength = &one
+			}
+		}
+
+		schema.Properties[name] = prop
+	}
+
+	if g.recursive[t] {
+		g.defs[t.Name()] = schema
+		return &Schema{Ref: "#/$defs/" + t.Name()}
+	}
+	return schema
+}
+
+// jsonFieldName returns the JSON property name of a struct field
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// constraints splits the jsonschema tag of a field
+func constraints(field reflect.StructField) []string {
+	tag := field.Tag.Get("jsonschema")
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+// hasConstraint reports whether a field carries a flag constraint
+func hasConstraint(field reflect.StructField, flag string) bool {
+	for _, c := range constraints(field) {
+		if c == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConstraints copies the key=value constraints of a field onto its schema
+func applyConstraints(schema *Schema, field reflect.StructField) error {
+	for _, c := range constraints(field) {
+		key, value, found := strings.Cut(c, "=")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "enum":
+			for _, option := range strings.Split(value, "|") {
+				parsed, err := parseValue(schema.Type, option)
+				if err != nil {
+					return err
+				}
+				schema.Enum = append(schema.Enum, parsed)
+			}
+		case "default":
+			parsed, err := parseValue(schema.Type, value)
+			if err != nil {
+				return err
+			}
+			schema.Default = parsed
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return err
+			}
+			if key == "minimum" {
+				schema.Minimum = &n
+			} else {
+				schema.Maximum = &n
+			}
+		case "minItems":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			schema.MinItems = &n
+		default:
+			return fmt.Errorf("unknown jsonschema constraint %q", key)
+		}
+	}
+	return nil
+}
+
+// parseValue converts a tag value to the Go value matching a schema type
+func parseValue(schemaType, value string) (interface{}, error) {
+	switch schemaType {
+	case "boolean":
+		return strconv.ParseBool(value)
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	default:
+		return value, nil
+	}
+}
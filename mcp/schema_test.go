@@ -0,0 +1,113 @@
+package mcp
+
+import (
+	"reflect"
+	"testing"
+)
+
+type schemaNode struct {
+	Name     string       `json:"name" jsonschema:"required"`
+	Children []schemaNode `json:"children"`
+}
+
+type schemaRequest struct {
+	Table   string         `json:"table" jsonschema:"required" description:"Table name"`
+	Mode    string         `json:"mode" jsonschema:"enum=fast|safe,default=safe"`
+	Limit   int            `json:"limit" jsonschema:"minimum=1,maximum=1000,default=100"`
+	Ratio   float64        `json:"ratio" jsonschema:"minimum=0.5"`
+	Level   int            `json:"level" jsonschema:"enum=1|2|3"`
+	Strict  bool           `json:"strict" jsonschema:"default=true"`
+	Columns []string       `json:"columns" jsonschema:"minItems=1"`
+	Labels  map[string]int `json:"labels"`
+	Tree    *schemaNode    `json:"tree"`
+	Skipped string         `json:"-"`
+	Plain   string         `json:""`
+	hidden  string
+	Extra   map[string]string `json:"extra,omitempty"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	schema := SchemaFor(schemaRequest{})
+
+	if schema.Type != "object" || schema.AdditionalProperties != false {
+		t.Fatalf("SchemaFor() = %+v, want a closed object", schema)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"table"}) {
+		t.Errorf("Required = %v, want [table]", schema.Required)
+	}
+
+	float := func(f float64) *float64 { return &f }
+	one := 1
+	tests := []struct {
+		name string
+		want Schema
+	}{
+		{"table", Schema{Type: "string", Description: "Table name", MinLength: &one}},
+		{"mode", Schema{Type: "string", Enum: []interface{}{"fast", "safe"}, Default: "safe"}},
+		{"limit", Schema{Type: "integer", Minimum: float(1), Maximum: float(1000), Default: int64(100)}},
+		{"ratio", Schema{Type: "number", Minimum: float(0.5)}},
+		{"level", Schema{Type: "integer", Enum: []interface{}{int64(1), int64(2), int64(3)}}},
+		{"strict", Schema{Type: "boolean", Default: true}},
+		{"columns", Schema{Type: "array", Items: &Schema{Type: "string"}, MinItems: &one}},
+		{"labels", Schema{Type: "object", AdditionalProperties: &Schema{Type: "integer"}}},
+		{"tree", Schema{Ref: "#/$defs/schemaNode"}},
+		{"Plain", Schema{Type: "string"}},
+		{"extra", Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}},
+	}
+
+	for _, tt := range tests {
+		got, ok := schema.Properties[tt.name]
+		if !ok {
+			t.Errorf("property %s is missing", tt.name)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("property %s = %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+	for _, name := range []string{"Skipped", "-", "hidden"} {
+		if _, ok := schema.Properties[name]; ok {
+			t.Errorf("property %s should be omitted", name)
+		}
+	}
+
+	// The recursive type is defined once and refers to itself
+	node := schema.Defs["schemaNode"]
+	if node == nil || node.Properties["children"].Items.Ref != "#/$defs/schemaNode" {
+		t.Errorf("$defs = %+v, want schemaNode referring to itself", schema.Defs)
+	}
+}
+
+func TestSchemaForInvalidConstraints(t *testing.T) {
+	tests := []struct {
+		name    string
+		request interface{}
+	}{
+		{"unknown constraint", struct {
+			A string `json:"a" jsonschema:"pattern=x"`
+		}{}},
+		{"integer default", struct {
+			A int `json:"a" jsonschema:"default=many"`
+		}{}},
+		{"boolean enum", struct {
+			A bool `json:"a" jsonschema:"enum=yes|no"`
+		}{}},
+		{"minimum", struct {
+			A int `json:"a" jsonschema:"minimum=one"`
+		}{}},
+		{"minItems", struct {
+			A []int `json:"a" jsonschema:"minItems=1.5"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("SchemaFor(%s) did not panic", tt.name)
+				}
+			}()
+			SchemaFor(tt.request)
+		}()
+	}
+}
@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// LatestProtocolVersion is the newest MCP revision implemented by the server
+const LatestProtocolVersion = "2025-06-18"
+
+// supportedProtocolVersions lists the MCP revisions the server can speak
+var supportedProtocolVersions = []string{
+	"2025-06-18",
+	"2025-03-26",
+	"2024-11-05",
+}
+
+// Implementation identifies an MCP client or server
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// InitializeParams represents the params of an initialize request
+type InitializeParams struct {
+	ProtocolVersion string          `json:"protocolVersion"`
+	Capabilities    json.RawMessage `json:"capabilities"`
+	ClientInfo      Implementation  `json:"clientInfo"`
+}
+
+// InitializeResult represents the result of an initialize request
+type InitializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      Implementation         `json:"serverInfo"`
+}
+
+// ListToolsResult represents the result of a tools/list request
+type ListToolsResult struct {
+	Tools []ToolInfo `json:"tools"`
+}
+
+// CallToolParams represents the params of a tools/call request
+type CallToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Server implements the MCP lifecycle and tool methods on top of a Registry
+type Server struct {
+	info     Implementation
+	registry *Registry
+	sessions *sessionStore
+}
+
+// NewServer creates a new MCP server
+func NewServer(name, version string, registry *Registry) *Server {
+	return &Server{
+		info: Implementation{
+			Name:    name,
+			Version: version,
+		},
+		registry: registry,
+		sessions: newSessionStore(),
+	}
+}
+
+// Registry returns the tool registry backing the server
+func (s *Server) Registry() *Registry {
+	return s.registry
+}
+
+// HandleMessage processes a raw JSON-RPC message, which may be a single
+// request or a batch, and returns the encoded reply. A nil reply means
+// the message contained only notifications.
+func (s *Server) HandleMessage(ctx context.Context, message []byte) []byte {
+	trimmed := bytes.TrimSpace(message)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return encode(newError(nil, ParseError, "Parse error", err.Error()))
+		}
+		if len(batch) == 0 {
+			return encode(newError(nil, InvalidRequest, "Invalid Request", "empty batch"))
+		}
+
+		var responses []*Response
+		for _, item := range batch {
+			if resp := s.handleOne(ctx, item); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return encode(responses)
+	}
+
+	if resp := s.handleOne(ctx, trimmed); resp != nil {
+		return encode(resp)
+	}
+	return nil
+}
+
+// handleOne processes a single JSON-RPC message
+func (s *Server) handleOne(ctx context.Context, message []byte) *Response {
+	var req Request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return newError(nil, ParseError, "Parse error", err.Error())
+	}
+
+	if req.JSONRPC != JSONRPCVersion || req.Method == "" {
+		// Responses sent by the client (e.g. to server requests) carry no method
+		if req.Method == "" && !req.IsNotification() {
+			return nil
+		}
+		return newError(req.ID, InvalidRequest, "Invalid Request", nil)
+	}
+
+	result, rpcErr := s.dispatch(ctx, &req)
+
+	if req.IsNotification() {
+		return nil
+	}
+	if rpcErr != nil {
+		return newError(req.ID, rpcErr.Code, rpcErr.Message, rpcErr.Data)
+	}
+	return newResult(req.ID, result)
+}
+
+// dispatch routes a request to its method implementation
+func (s *Server) dispatch(ctx context.Context, req *Request) (interface{}, *Error) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params)
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return ListToolsResult{Tools: s.registry.List(ctx)}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	default:
+		return nil, &Error{Code: MethodNotFound, Message: "Method not found: " + req.Method}
+	}
+}
+
+// initialize negotiates the protocol version and advertises capabilities
+func (s *Server) initialize(params json.RawMessage) (interface{}, *Error) {
+	var p InitializeParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &Error{Code: InvalidParams, Message: "Invalid params", Data: err.Error()}
+		}
+	}
+
+	// Echo the client's version when supported, otherwise offer our latest
+	version := LatestProtocolVersion
+	for _, supported := range supportedProtocolVersions {
+		if p.ProtocolVersion == supported {
+			version = supported
+			break
+		}
+	}
+
+	return InitializeResult{
+		ProtocolVersion: version,
+		Capabilities: map[string]interface{}{
+			"tools": map[string]interface{}{
+				"listChanged": false,
+			},
+		},
+		ServerInfo: s.info,
+	}, nil
+}
+
+// callTool executes a tools/call request
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (interface{}, *Error) {
+	var p CallToolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &Error{Code: InvalidParams, Message: "Invalid params", Data: err.Error()}
+	}
+
+	if _, ok := s.registry.Lookup(p.Name); !ok {
+		return nil, &Error{Code: InvalidParams, Message: "Unknown tool: " + p.Name}
+	}
+
+	result, err := s.registry.Call(ctx, p.Name, p.Arguments)
+	if err != nil {
+		return nil, &Error{Code: InternalError, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// encode marshals a response value, falling back to an internal error
+func encode(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(newError(nil, InternalError, "Internal error", err.Error()))
+	}
+	return data
+}
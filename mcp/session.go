@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// sessionIdleTimeout is how long a session survives without any traffic
+const sessionIdleTimeout = 30 * time.Minute
+
+// session tracks a client connected over one of the HTTP transports
+type session struct {
+	id string
+	// owner identifies the principal that opened the session; requests
+	// from anyone else are answered as if it did not exist
+	owner string
+
+	mu       sync.Mutex
+	lastSeen time.Time
+	streams  int
+
+	// outbox carries server-to-client messages to an open SSE stream
+	outbox chan []byte
+
+	// ctx is cancelled when the session ends, aborting in-flight work
+	// started on its behalf
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// touch records activity on the session
+func (s *session) touch() {
+	s.mu.Lock()
+	s.lastSeen = time.Now()
+	s.mu.Unlock()
+}
+
+// idle reports whether the session has expired
+func (s *session) idle(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams == 0 && now.Sub(s.lastSeen) > sessionIdleTimeout
+}
+
+// openStream marks an SSE stream as attached to the session
+func (s *session) openStream() {
+	s.mu.Lock()
+	s.streams++
+	s.mu.Unlock()
+}
+
+// closeStream marks an SSE stream as detached from the session
+func (s *session) closeStream() {
+	s.mu.Lock()
+	s.streams--
+	s.lastSeen = time.Now()
+	s.mu.Unlock()
+}
+
+// send queues a message for delivery on the session's SSE stream.
+// It returns false if the session was closed.
+func (s *session) send(message []byte) bool {
+	select {
+	case <-s.ctx.Done():
+		return false
+	case s.outbox <- message:
+		return true
+	}
+}
+
+// close terminates the session and any attached streams
+func (s *session) close() {
+	s.cancel()
+}
+
+// sessionStore holds the active sessions keyed by Mcp-Session-Id
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessions: make(map[string]*session),
+	}
+}
+
+// create starts a new session with a random, unguessable id, owned by the
+// principal identified by owner
+func (st *sessionStore) create(owner string) *session {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &session{
+		id:       hex.EncodeToString(buf),
+		owner:    owner,
+		lastSeen: time.Now(),
+		outbox:   make(chan []byte, 16),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.sweepLocked()
+	st.sessions[s.id] = s
+	return s
+}
+
+// get returns the live session with the given id if owner opened it
+func (st *sessionStore) get(id, owner string) (*session, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.sessions[id]
+	if !ok || s.owner != owner {
+		return nil, false
+	}
+	if s.idle(time.Now()) {
+		delete(st.sessions, id)
+		s.close()
+		return nil, false
+	}
+
+	s.touch()
+	return s, true
+}
+
+// remove terminates and forgets a session if owner opened it
+func (st *sessionStore) remove(id, owner string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.sessions[id]
+	if !ok || s.owner != owner {
+		return false
+	}
+	delete(st.sessions, id)
+	s.close()
+	return true
+}
+
+// sweepLocked drops expired sessions; the caller must hold st.mu
+func (st *sessionStore) sweepLocked() {
+	now := time.Now()
+	for id, s := range st.sessions {
+		if s.idle(now) {
+			delete(st.sessions, id)
+			s.close()
+		}
+	}
+}
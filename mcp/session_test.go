@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type callerKey struct{}
+
+// callerGuard allows everything and identifies callers by the X-Caller
+// header, which the router copies into the request context
+type callerGuard struct{}
+
+func (callerGuard) AllowTool(ctx context.Context, tool Tool) bool { return true }
+
+func (callerGuard) CheckCall(ctx context.Context, tool Tool, arguments map[string]interface{}) error {
+	return nil
+}
+
+func (callerGuard) CallerID(ctx context.Context) string {
+	id, _ := ctx.Value(callerKey{}).(string)
+	return id
+}
+
+func TestSessionsBelongToTheirCaller(t *testing.T) {
+	server := testServer()
+	server.Registry().SetGuard(callerGuard{})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), callerKey{}, c.GetHeader("X-Caller")))
+	})
+	router.POST("/mcp", server.HandleHTTP)
+	router.GET("/mcp", server.HandleStream)
+	router.DELETE("/mcp", server.HandleDeleteSession)
+	router.POST("/messages", server.HandleLegacyMessage)
+
+	request := func(method, path, caller, session, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Caller", caller)
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		if session != "" {
+			req.Header.Set(SessionHeader, session)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	initialized := request(http.MethodPost, "/mcp", "alice", "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	session := initialized.Header().Get(SessionHeader)
+	if initialized.Code != http.StatusOK || session == "" {
+		t.Fatalf("initialize: status %d, session %q", initialized.Code, session)
+	}
+
+	const ping = `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+	if got := request(http.MethodPost, "/mcp", "alice", session, ping).Code; got != http.StatusOK {
+		t.Errorf("ping from the owner: status %d, want 200", got)
+	}
+
+	// Other callers, anonymous ones included, cannot tell the session exists
+	for _, caller := range []string{"bob", ""} {
+		if got := request(http.MethodPost, "/mcp", caller, session, ping).Code; got != http.StatusNotFound {
+			t.Errorf("ping from %q: status %d, want 404", caller, got)
+		}
+		if got := request(http.MethodGet, "/mcp", caller, session, "").Code; got != http.StatusNotFound {
+			t.Errorf("stream from %q: status %d, want 404", caller, got)
+		}
+		if got := request(http.MethodPost, "/messages?sessionId="+session, caller, "", ping).Code; got != http.StatusNotFound {
+			t.Errorf("legacy message from %q: status %d, want 404", caller, got)
+		}
+		if got := request(http.MethodDelete, "/mcp", caller, session, "").Code; got != http.StatusNotFound {
+			t.Errorf("delete from %q: status %d, want 404", caller, got)
+		}
+	}
+
+	if got := request(http.MethodDelete, "/mcp", "alice", session, "").Code; got != http.StatusNoContent {
+		t.Errorf("delete from the owner: status %d, want 204", got)
+	}
+	if got := request(http.MethodPost, "/mcp", "alice", session, ping).Code; got != http.StatusNotFound {
+		t.Errorf("ping after delete: status %d, want 404", got)
+	}
+}
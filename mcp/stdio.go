@@ -0,0 +1,87 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ServeStdio serves newline-delimited JSON-RPC messages read from in and
+// writes replies to out, one message per line. Messages are handled
+// concurrently so a slow tool call does not block pings or other calls.
+// It returns when in reaches EOF or ctx is cancelled.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+	)
+
+	write := func(reply []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+
+		if _, err := out.Write(append(reply, '\n')); err != nil {
+			return err
+		}
+		if f, ok := out.(interface{ Flush() error }); ok {
+			return f.Flush()
+		}
+		return nil
+	}
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr <- err
+				}
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				wg.Wait()
+				select {
+				case err := <-readErr:
+					return err
+				default:
+					return nil
+				}
+			}
+
+			wg.Add(1)
+			go func(message []byte) {
+				defer wg.Done()
+				if reply := s.HandleMessage(ctx, message); reply != nil {
+					_ = write(reply)
+				}
+			}(line)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tool describes an operation exposed to MCP clients and the REST API.
+// Each tool is declared once: its input schema is derived from Request,
+// a zero value of the Go struct the handler binds.
+type Tool struct {
+	Name        string
+	Description string
+	Request     interface{}
+	Handler     gin.HandlerFunc
+
+	// SchemaArgument names the argument that selects the database schema
+	// the tool works on; it defaults to "schema" when Request has that
+	// field. AnySchema marks tools that can reach every schema, such as
+	// those running raw SQL.
+	SchemaArgument string
+	AnySchema      bool
+
+	// InputSchema is filled in from Request at registration
+	InputSchema *Schema
+}
+
+// Guard restricts what a caller may do. The caller is identified from the
+// request context, so the same checks apply to REST and MCP calls.
+type Guard interface {
+	// AllowTool reports whether the caller may list and call the tool
+	AllowTool(ctx context.Context, tool Tool) bool
+
+	// CheckCall vets validated arguments before the tool runs
+	CheckCall(ctx context.Context, tool Tool, arguments map[string]interface{}) error
+
+	// CallerID identifies the caller, so that a session can only be used
+	// by the caller that opened it; it is empty for anonymous callers
+	CallerID(ctx context.Context) string
+}
+
+// ToolInfo is the tools/list representation of a tool
+type ToolInfo struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	InputSchema *Schema `json:"inputSchema"`
+}
+
+// Content represents a single content block of a tool result
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// CallToolResult is the result of a tools/call request
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Registry holds the tools served over MCP and the REST routes.
+// Tool calls are dispatched to the same Gin handlers used by the
+// REST API through an internal engine, so both paths share one
+// implementation.
+type Registry struct {
+	mu     sync.RWMutex
+	tools  map[string]Tool
+	engine *gin.Engine
+	guard  Guard
+	// maxBodySize bounds the body of MCP and REST requests; zero leaves
+	// it unbounded
+	maxBodySize int64
+}
+
+// NewRegistry creates an empty tool registry
+func NewRegistry() *Registry {
+	return &Registry{
+		tools:  make(map[string]Tool),
+		engine: gin.New(),
+	}
+}
+
+// Register adds a tool to the registry
+func (r *Registry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[tool.Name]; exists {
+		panic(fmt.Sprintf("mcp: tool %q registered twice", tool.Name))
+	}
+
+	if tool.InputSchema == nil {
+		tool.InputSchema = SchemaFor(tool.Request)
+	}
+	if tool.SchemaArgument == "" && !tool.AnySchema {
+		if _, ok := tool.InputSchema.Properties["schema"]; ok {
+			tool.SchemaArgument = "schema"
+		}
+	}
+
+	r.tools[tool.Name] = tool
+	r.engine.POST("/"+tool.Name, r.handlers(tool)...)
+}
+
+// SetGuard installs the access checks applied to every tool
+func (r *Registry) SetGuard(guard Guard) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.guard = guard
+}
+
+// SetMaxBodySize bounds the size in bytes of the request bodies read by the
+// REST routes and the HTTP transports
+func (r *Registry) SetMaxBodySize(size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxBodySize = size
+}
+
+// limitBody caps the request body at the registry's maximum size, so that
+// reading a larger one fails with *http.MaxBytesError
+func (r *Registry) limitBody(c *gin.Context) {
+	r.mu.RLock()
+	limit := r.maxBodySize
+	r.mu.RUnlock()
+	if limit > 0 && c.Request.Body != nil {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
+}
+
+// bodyErrorStatus is the status for a request body that could not be read
+func bodyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// Handlers returns the handler chain serving a tool over REST
+func (r *Registry) Handlers(name string) []gin.HandlerFunc {
+	tool, ok := r.Lookup(name)
+	if !ok {
+		return nil
+	}
+	return r.handlers(tool)
+}
+
+// handlers chains the body limit, argument validation, the guard and the
+// tool handler
+func (r *Registry) handlers(tool Tool) []gin.HandlerFunc {
+	return []gin.HandlerFunc{r.limitBody, validateArguments(tool.InputSchema), r.guardTool(tool), tool.Handler}
+}
+
+// guardTool applies the registry's guard to validated arguments, so that
+// defaults such as schema=public are checked too
+func (r *Registry) guardTool(tool Tool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r.mu.RLock()
+		guard := r.guard
+		r.mu.RUnlock()
+		if guard == nil {
+			return
+		}
+
+		ctx := c.Request.Context()
+		if !guard.AllowTool(ctx, tool) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Not allowed to use tool %s", tool.Name)})
+			return
+		}
+
+		raw, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+		var arguments map[string]interface{}
+		if err := json.Unmarshal(raw, &arguments); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := guard.CheckCall(ctx, tool, arguments); err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+}
+
+// callerID identifies the caller in ctx through the guard
+func (r *Registry) callerID(ctx context.Context) string {
+	r.mu.RLock()
+	guard := r.guard
+	r.mu.RUnlock()
+	if guard == nil {
+		return ""
+	}
+	return guard.CallerID(ctx)
+}
+
+// allowed reports whether the caller in ctx may use the tool
+func (r *Registry) allowed(ctx context.Context, tool Tool) bool {
+	r.mu.RLock()
+	guard := r.guard
+	r.mu.RUnlock()
+	return guard == nil || guard.AllowTool(ctx, tool)
+}
+
+// Lookup returns the tool with the given name
+func (r *Registry) Lookup(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Tools returns all registered tools sorted by name, whether or not the
+// guard allows them
+func (r *Registry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Name < tools[j].Name
+	})
+	return tools
+}
+
+// List returns the tools/list representation of the tools the caller in
+// ctx may use
+func (r *Registry) List(ctx context.Context) []ToolInfo {
+	tools := r.Tools()
+	infos := make([]ToolInfo, 0, len(tools))
+	for _, tool := range tools {
+		if !r.allowed(ctx, tool) {
+			continue
+		}
+		infos = append(infos, ToolInfo{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
+	return infos
+}
+
+// HandleSpecification serves the tool catalogue in the format of the
+// original /v1/specification endpoint
+func (r *Registry) HandleSpecification(c *gin.Context) {
+	tools := r.Tools()
+	functions := make([]gin.H, 0, len(tools))
+	for _, tool := range tools {
+		if !r.allowed(c.Request.Context(), tool) {
+			continue
+		}
+		functions = append(functions, gin.H{
+			"name":        tool.Name,
+			"description": tool.Description,
+			"parameters":  tool.InputSchema,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"functions": functions})
+}
+
+// Call runs a tool with the given JSON arguments and converts the
+// handler's HTTP response into an MCP tool result. A handler that panics
+// is reported as an error, since over stdio the panic would otherwise end
+// the process.
+func (r *Registry) Call(ctx context.Context, name string, arguments json.RawMessage) (result *CallToolResult, err error) {
+	if _, ok := r.Lookup(name); !ok {
+		return nil, fmt.Errorf("unknown tool: %s", name)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			fmt.Fprintf(gin.DefaultErrorWriter, "mcp: tool %s panicked: %v\n%s", name, p, debug.Stack())
+			result, err = nil, fmt.Errorf("tool %s failed unexpectedly", name)
+		}
+	}()
+
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/"+name, bytes.NewReader(arguments))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	w := newResponseBuffer()
+	r.engine.ServeHTTP(w, req)
+
+	return &CallToolResult{
+		Content: []Content{{Type: "text", Text: w.body.String()}},
+		IsError: w.status >= http.StatusBadRequest,
+	}, nil
+}
+
+// responseBuffer is an in-memory http.ResponseWriter used to capture
+// the output of a tool handler
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (w *responseBuffer) Header() http.Header {
+	return w.header
+}
+
+func (w *responseBuffer) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *responseBuffer) WriteHeader(status int) {
+	w.status = status
+}
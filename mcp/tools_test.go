@@ -0,0 +1,97 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type echoRequest struct {
+	Text string `json:"text" jsonschema:"required"`
+}
+
+func testServer() *Server {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultErrorWriter = io.Discard
+
+	registry := NewRegistry()
+	registry.Register(Tool{
+		Name:    "echo",
+		Request: echoRequest{},
+		Handler: func(c *gin.Context) {
+			var req echoRequest
+			c.ShouldBindJSON(&req)
+			c.JSON(http.StatusOK, gin.H{"text": req.Text})
+		},
+	})
+	registry.Register(Tool{
+		Name:    "explode",
+		Request: echoRequest{},
+		Handler: func(c *gin.Context) {
+			var rows []int
+			c.JSON(http.StatusOK, rows[0])
+		},
+	})
+	return NewServer("test", "0.0.0", registry)
+}
+
+func TestCallRecoversFromPanics(t *testing.T) {
+	server := testServer()
+
+	result, err := server.Registry().Call(context.Background(), "explode", json.RawMessage(`{"text":"x"}`))
+	if err == nil || result != nil {
+		t.Fatalf("Call(explode) = %v, %v, want an error", result, err)
+	}
+
+	// The server keeps answering after a panic, and reports it as an
+	// internal error
+	reply := server.HandleMessage(context.Background(), []byte(
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"explode","arguments":{"text":"x"}}}`))
+	var response Response
+	if err := json.Unmarshal(reply, &response); err != nil {
+		t.Fatalf("invalid response %s: %v", reply, err)
+	}
+	if response.Error == nil || response.Error.Code != InternalError {
+		t.Errorf("tools/call explode = %s, want an internal error", reply)
+	}
+
+	reply = server.HandleMessage(context.Background(), []byte(
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"text":"still here"}}}`))
+	if !strings.Contains(string(reply), `still here`) || strings.Contains(string(reply), `"error"`) {
+		t.Errorf("tools/call echo after a panic = %s", reply)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	server := testServer()
+	server.Registry().SetMaxBodySize(64)
+
+	router := gin.New()
+	router.POST("/v1/echo", server.Registry().Handlers("echo")...)
+	router.POST("/mcp", server.HandleHTTP)
+
+	tests := []struct {
+		path string
+		body string
+		want int
+	}{
+		{"/v1/echo", `{"text": "short"}`, http.StatusOK},
+		{"/v1/echo", `{"text": "` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		{"/mcp", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, http.StatusOK},
+		{"/mcp", `{"jsonrpc":"2.0","id":1,"method":"ping","params":{"pad":"` + strings.Repeat("x", 64) + `"}}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+		if recorder.Code != tt.want {
+			t.Errorf("POST %s with %d bytes: status %d, want %d", tt.path, len(tt.body), recorder.Code, tt.want)
+		}
+	}
+}
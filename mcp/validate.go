@@ -0,0 +1,250 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FieldError describes a single argument that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when tool arguments do not match the schema
+type ValidationError struct {
+	Errors []FieldError `json:"validation_errors"`
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", fe.Field, fe.Message))
+	}
+	return "invalid arguments: " + strings.Join(messages, "; ")
+}
+
+// PrepareArguments validates raw JSON arguments against the schema, fills
+// in defaults for omitted fields and returns the normalised JSON
+func (s *Schema) PrepareArguments(raw []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		raw = []byte("{}")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, &ValidationError{Errors: []FieldError{{Field: "(root)", Message: "invalid JSON: " + err.Error()}}}
+	}
+	if value == nil {
+		value = map[string]interface{}{}
+	}
+
+	v := validator{root: s}
+	value = v.validate(s, value, "")
+	if len(v.errors) > 0 {
+		return nil, &ValidationError{Errors: v.errors}
+	}
+
+	return json.Marshal(value)
+}
+
+// validator accumulates errors while walking a value
+type validator struct {
+	root   *Schema
+	errors []FieldError
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "(root)"
+	}
+	v.errors = append(v.errors, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolve follows a local $ref to its definition
+func (v *validator) resolve(schema *Schema) *Schema {
+	if schema.Ref == "" {
+		return schema
+	}
+	name := strings.TrimPrefix(schema.Ref, "#/$defs/")
+	if def, ok := v.root.Defs[name]; ok {
+		return def
+	}
+	return &Schema{}
+}
+
+// validate checks a value and returns it with defaults applied
+func (v *validator) validate(schema *Schema, value interface{}, path string) interface{} {
+	schema = v.resolve(schema)
+
+	switch schema.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			v.fail(path, "must be a string")
+			return value
+		}
+		if schema.MinLength != nil && len(s) < *schema.MinLength {
+			v.fail(path, "must not be empty")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "must be a boolean")
+			return value
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			v.fail(path, "must be a %s", schema.Type)
+			return value
+		}
+		f, err := n.Float64()
+		if err != nil || (schema.Type == "integer" && f != math.Trunc(f)) {
+			v.fail(path, "must be a %s", schema.Type)
+			return value
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			v.fail(path, "must be >= %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			v.fail(path, "must be <= %v", *schema.Maximum)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "must be an array")
+			return value
+		}
+		if schema.MinItems != nil && len(items) < *schema.MinItems {
+			v.fail(path, "must contain at least %d item(s)", *schema.MinItems)
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				if item == nil {
+					continue
+				}
+				items[i] = v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, "must be an object")
+			return value
+		}
+		v.validateObject(schema, obj, path)
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		options := make([]string, 0, len(schema.Enum))
+		for _, option := range schema.Enum {
+			options = append(options, fmt.Sprint(option))
+		}
+		v.fail(path, "must be one of: %s", strings.Join(options, ", "))
+	}
+
+	return value
+}
+
+// validateObject checks the properties of an object value
+func (v *validator) validateObject(schema *Schema, obj map[string]interface{}, path string) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	for _, name := range schema.Required {
+		if value, ok := obj[name]; !ok || value == nil {
+			v.fail(join(name), "is required")
+		}
+	}
+
+	// Report unknown fields in a stable order
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := obj[key]
+		prop, known := schema.Properties[key]
+		if !known {
+			switch extra := schema.AdditionalProperties.(type) {
+			case *Schema:
+				if value != nil {
+					obj[key] = v.validate(extra, value, join(key))
+				}
+			case bool:
+				if !extra {
+					v.fail(join(key), "is not a recognised field")
+				}
+			}
+			continue
+		}
+
+		// null is accepted for optional fields and means "not set"
+		if value == nil {
+			continue
+		}
+		obj[key] = v.validate(prop, value, join(key))
+	}
+
+	for name, prop := range schema.Properties {
+		if _, present := obj[name]; !present && prop.Default != nil {
+			obj[name] = prop.Default
+		}
+	}
+}
+
+// inEnum reports whether a value is one of the enum options
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, option := range enum {
+		if fmt.Sprint(option) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateArguments returns middleware that validates the request body
+// against a tool's schema before the handler runs
+func validateArguments(schema *Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(bodyErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		prepared, err := schema.PrepareArguments(raw)
+		if err != nil {
+			if ve, ok := err.(*ValidationError); ok {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error":             "Invalid arguments",
+					"validation_errors": ve.Errors,
+				})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(prepared))
+		c.Request.ContentLength = int64(len(prepared))
+	}
+}
@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPrepareArguments(t *testing.T) {
+	schema := SchemaFor(schemaRequest{})
+
+	tests := []struct {
+		name   string
+		args   string
+		want   map[string]interface{}
+		errors []FieldError
+	}{
+		{
+			name: "defaults fill omitted fields",
+			args: `{"table": "posts"}`,
+			want: map[string]interface{}{"table": "posts", "mode": "safe", "limit": 100.0, "strict": true},
+		},
+		{
+			name: "given values are kept",
+			args: `{"table": "posts", "mode": "fast", "limit": 5, "strict": false}`,
+			want: map[string]interface{}{"table": "posts", "mode": "fast", "limit": 5.0, "strict": false},
+		},
+		{
+			name: "null means not set",
+			args: `{"table": "posts", "mode": null}`,
+			want: map[string]interface{}{"table": "posts", "mode": nil, "limit": 100.0, "strict": true},
+		},
+		{
+			name:   "empty body",
+			args:   ``,
+			errors: []FieldError{{"table", "is required"}},
+		},
+		{
+			name:   "required string must not be empty",
+			args:   `{"table": ""}`,
+			errors: []FieldError{{"table", "must not be empty"}},
+		},
+		{
+			name:   "enum",
+			args:   `{"table": "posts", "mode": "slow", "level": 4}`,
+			errors: []FieldError{{"level", "must be one of: 1, 2, 3"}, {"mode", "must be one of: fast, safe"}},
+		},
+		{
+			name:   "enum compares numbers as written",
+			args:   `{"table": "posts", "level": 2.0}`,
+			errors: []FieldError{{"level", "must be one of: 1, 2, 3"}},
+		},
+		{
+			name:   "bounds",
+			args:   `{"table": "posts", "limit": 0, "ratio": 0.25}`,
+			errors: []FieldError{{"limit", "must be >= 1"}, {"ratio", "must be >= 0.5"}},
+		},
+		{
+			name:   "maximum",
+			args:   `{"table": "posts", "limit": 1001}`,
+			errors: []FieldError{{"limit", "must be <= 1000"}},
+		},
+		{
+			name:   "types",
+			args:   `{"table": 1, "limit": 1.5, "strict": "yes", "columns": "id"}`,
+			errors: []FieldError{{"columns", "must be an array"}, {"limit", "must be a integer"}, {"strict", "must be a boolean"}, {"table", "must be a string"}},
+		},
+		{
+			name:   "minItems",
+			args:   `{"table": "posts", "columns": []}`,
+			errors: []FieldError{{"columns", "must contain at least 1 item(s)"}},
+		},
+		{
+			name:   "array items",
+			args:   `{"table": "posts", "columns": ["id", 2]}`,
+			errors: []FieldError{{"columns[1]", "must be a string"}},
+		},
+		{
+			name:   "additional properties",
+			args:   `{"table": "posts", "labels": {"a": 1, "b": "two"}, "unknown": true}`,
+			errors: []FieldError{{"labels.b", "must be a integer"}, {"unknown", "is not a recognised field"}},
+		},
+		{
+			name:   "recursive definitions",
+			args:   `{"table": "posts", "tree": {"name": "root", "children": [{"children": []}]}}`,
+			errors: []FieldError{{"tree.children[0].name", "is required"}},
+		},
+		{
+			name:   "invalid JSON",
+			args:   `{"table": `,
+			errors: []FieldError{{"(root)", "invalid JSON: unexpected EOF"}},
+		},
+		{
+			name:   "not an object",
+			args:   `[1]`,
+			errors: []FieldError{{"(root)", "must be an object"}},
+		},
+	}
+
+	for _, tt := range tests {
+		prepared, err := schema.PrepareArguments([]byte(tt.args))
+		if tt.errors != nil {
+			ve, ok := err.(*ValidationError)
+			if !ok {
+				t.Errorf("%s: PrepareArguments() = %s, %v, want validation errors", tt.name, prepared, err)
+				continue
+			}
+			if !reflect.DeepEqual(ve.Errors, tt.errors) {
+				t.Errorf("%s: validation errors = %v, want %v", tt.name, ve.Errors, tt.errors)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: PrepareArguments() = %v", tt.name, err)
+			continue
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(prepared, &got); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: PrepareArguments() = %s, want %v", tt.name, prepared, tt.want)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package supabase
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
@@ -13,8 +15,18 @@ import (
 // SupabaseClientExtended extends the supabase.Client with additional functionality
 type SupabaseClientExtended struct {
 	*supabase.Client
-	apiKey string
+	apiKey  string
 	baseURL string
+
+	httpClient *http.Client
+	maxRetries int
+
+	// anonKey is sent instead of the service role key on requests made
+	// on behalf of a user
+	anonKey string
+
+	// tusURL replaces the resumable upload endpoint of the Storage API
+	tusURL string
 }
 
 // Functions provides access to Supabase Edge Functions
@@ -22,14 +34,24 @@ type Functions struct {
 	client *SupabaseClientExtended
 }
 
-// CreateClientExtended creates an extended Supabase client
-func CreateClientExtended(supabaseURL, supabaseKey string) *SupabaseClientExtended {
+// CreateClientExtended creates an extended Supabase client. Without
+// options it uses a pooled HTTP client that gives requests without a
+// deadline 30 seconds, and retries idempotent requests twice.
+func CreateClientExtended(supabaseURL, supabaseKey string, opts ...ClientOption) *SupabaseClientExtended {
 	client := supabase.CreateClient(supabaseURL, supabaseKey)
-	return &SupabaseClientExtended{
-		Client: client,
-		apiKey: supabaseKey,
-		baseURL: supabaseURL,
+	extended := &SupabaseClientExtended{
+		Client:     client,
+		apiKey:     supabaseKey,
+		baseURL:    supabaseURL,
+		httpClient: NewHTTPClient(defaultHTTPTimeout, defaultMaxIdleConns),
+		maxRetries: defaultMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(extended)
 	}
+	// The embedded client is used for the Auth and Storage APIs
+	client.HTTPClient = extended.httpClient
+	return extended
 }
 
 // Functions returns the Functions API
@@ -39,8 +61,9 @@ func (c *SupabaseClientExtended) Functions() *Functions {
 	}
 }
 
-// Invoke calls a Supabase Edge Function or RPC function
-func (f *Functions) Invoke(functionName string, body interface{}, result interface{}) error {
+// Invoke calls a Supabase Edge Function or RPC function. The call is
+// cancelled with ctx. Error responses are returned as *APIError.
+func (f *Functions) Invoke(ctx context.Context, functionName string, body interface{}, result interface{}) error {
 	// Use direct HTTP request approach for all functions
 	// The supabase-go library doesn't have a direct way to call RPC functions
 	// Convert the request body to JSON
@@ -67,7 +90,7 @@ func (f *Functions) Invoke(functionName string, body interface{}, result interfa
 	}
 	
 	// Create the request
-	req, err := http.NewRequest("POST", baseURL.String(), bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", baseURL.String(), bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return err
 	}
@@ -77,16 +100,45 @@ func (f *Functions) Invoke(functionName string, body interface{}, result interfa
 	req.Header.Set("apikey", f.client.apiKey)
 	req.Header.Set("Authorization", "Bearer "+f.client.apiKey)
 
-	// Send the request
-	resp, err := http.DefaultClient.Do(req)
+	return f.send(req, result)
+}
+
+// InvokeReadOnly calls an RPC function with GET, passing params in the
+// query string. PostgREST runs GET requests in a READ ONLY transaction, so
+// nothing the function does can write. Error responses are returned as
+// *APIError.
+func (f *Functions) InvokeReadOnly(ctx context.Context, functionName string, params map[string]string, result interface{}) error {
+	query := url.Values{}
+	for name, value := range params {
+		query.Set(name, value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.client.endpoint("/rest/v1/rpc/"+url.PathEscape(functionName))+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("apikey", f.client.apiKey)
+	req.Header.Set("Authorization", "Bearer "+f.client.apiKey)
+
+	return f.send(req, result)
+}
+
+// send performs a function call and decodes the response into result
+func (f *Functions) send(req *http.Request, result interface{}) error {
+	resp, err := f.client.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	// Check for errors
+	// Check for errors, keeping the status and the error reported by PostgREST
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("function call failed with status code: %d", resp.StatusCode)
+		errBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("function call failed with status code: %d", resp.StatusCode)
+		}
+		return newAPIError(resp.StatusCode, errBody)
 	}
 
 	// Decode the response
@@ -0,0 +1,44 @@
+package supabase
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError represents an error response from a Supabase API. PostgREST
+// reports its own PGRST codes as well as the SQLSTATE of database errors.
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details"`
+	Hint    string `json:"hint"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return e.Message
+}
+
+// IsFunctionNotFound reports whether PostgREST could not find the called
+// function (PGRST202)
+func (e *APIError) IsFunctionNotFound() bool {
+	return e.Code == "PGRST202"
+}
+
+// newAPIError decodes an error body, falling back to the raw text when it
+// is not JSON
+func newAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{Status: status}
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(status)
+		}
+	}
+	return apiErr
+}
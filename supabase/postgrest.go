@@ -0,0 +1,236 @@
+package supabase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The postgrest-go builder embedded in supabase.Client sets the schema once
+// per client and hides its per-request headers, so requests that need a
+// schema, Prefer options or on_conflict are built with PostgrestRequest.
+
+// PostgrestRequest builds a request against the PostgREST API
+type PostgrestRequest struct {
+	client *SupabaseClientExtended
+	method string
+	path   string
+	params url.Values
+	header http.Header
+	prefer []string
+	body   interface{}
+
+	// userToken replaces the service role when the request runs as a user
+	userToken string
+}
+
+// ErrAnonKeyRequired is returned when a request should run as a user but
+// no anon key is configured
+var ErrAnonKeyRequired = errors.New("SUPABASE_ANON_KEY is required to run requests as a user")
+
+// PostgrestResponse holds the status and headers of a PostgREST response
+type PostgrestResponse struct {
+	StatusCode int
+	Header     http.Header
+}
+
+// Rest starts a PostgREST request for a table or view
+func (c *SupabaseClientExtended) Rest(table string) *PostgrestRequest {
+	return &PostgrestRequest{
+		client: c,
+		method: http.MethodGet,
+		path:   "/rest/v1/" + url.PathEscape(table),
+		params: url.Values{},
+		header: http.Header{},
+	}
+}
+
+// RPC starts a PostgREST request that calls a database function. The
+// request is a GET until Call sets a body.
+func (c *SupabaseClientExtended) RPC(function string) *PostgrestRequest {
+	return &PostgrestRequest{
+		client: c,
+		method: http.MethodGet,
+		path:   "/rest/v1/rpc/" + url.PathEscape(function),
+		params: url.Values{},
+		header: http.Header{},
+	}
+}
+
+// Schema selects the schema the request runs against
+func (r *PostgrestRequest) Schema(schema string) *PostgrestRequest {
+	if schema != "" {
+		r.header.Set("Accept-Profile", schema)
+		r.header.Set("Content-Profile", schema)
+	}
+	return r
+}
+
+// Select sets the columns to return
+func (r *PostgrestRequest) Select(columns string) *PostgrestRequest {
+	if columns != "" {
+		r.params.Set("select", columns)
+	}
+	return r
+}
+
+// Filter adds a column filter such as id=eq.1
+func (r *PostgrestRequest) Filter(column, operator, value string) *PostgrestRequest {
+	r.params.Add(column, operator+"."+value)
+	return r
+}
+
+// Group adds a logic tree such as or=(a.eq.1,b.eq.2); operator is and, or,
+// not.and or not.or
+func (r *PostgrestRequest) Group(operator string, conditions ...string) *PostgrestRequest {
+	r.params.Add(operator, "("+strings.Join(conditions, ",")+")")
+	return r
+}
+
+// Param sets a query string parameter
+func (r *PostgrestRequest) Param(key, value string) *PostgrestRequest {
+	r.params.Set(key, value)
+	return r
+}
+
+// Header sets a request header
+func (r *PostgrestRequest) Header(key, value string) *PostgrestRequest {
+	r.header.Set(key, value)
+	return r
+}
+
+// Prefer appends options to the Prefer header
+func (r *PostgrestRequest) Prefer(options ...string) *PostgrestRequest {
+	r.prefer = append(r.prefer, options...)
+	return r
+}
+
+// Insert turns the request into an insert of one or more rows
+func (r *PostgrestRequest) Insert(rows interface{}) *PostgrestRequest {
+	r.method = http.MethodPost
+	r.body = rows
+	return r
+}
+
+// Upsert turns the request into an insert that resolves conflicts on the
+// given columns, merging or ignoring the duplicates
+func (r *PostgrestRequest) Upsert(rows interface{}, onConflict []string, ignoreDuplicates bool) *PostgrestRequest {
+	r.method = http.MethodPost
+	r.body = rows
+	if len(onConflict) > 0 {
+		r.params.Set("on_conflict", strings.Join(onConflict, ","))
+	}
+	if ignoreDuplicates {
+		r.prefer = append(r.prefer, "resolution=ignore-duplicates")
+	} else {
+		r.prefer = append(r.prefer, "resolution=merge-duplicates")
+	}
+	return r
+}
+
+// Call turns the request into a POST with the function arguments as body
+func (r *PostgrestRequest) Call(args interface{}) *PostgrestRequest {
+	r.method = http.MethodPost
+	r.body = args
+	return r
+}
+
+// Update turns the request into an update of the matching rows
+func (r *PostgrestRequest) Update(values interface{}) *PostgrestRequest {
+	r.method = http.MethodPatch
+	r.body = values
+	return r
+}
+
+// Delete turns the request into a delete of the matching rows
+func (r *PostgrestRequest) Delete() *PostgrestRequest {
+	r.method = http.MethodDelete
+	r.body = nil
+	return r
+}
+
+// AsUser sends the request with the anon key and a user's access token
+// instead of the service role key, so that row level security applies as
+// it would for that user
+func (r *PostgrestRequest) AsUser(token string) *PostgrestRequest {
+	r.userToken = token
+	return r
+}
+
+// Execute sends the request and decodes the response body into result,
+// with numbers in interface{} values as json.Number. Error responses are
+// returned as *APIError.
+func (r *PostgrestRequest) Execute(ctx context.Context, result interface{}) (*PostgrestResponse, error) {
+	var body io.Reader
+	if r.body != nil {
+		jsonBody, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(jsonBody)
+	}
+
+	requestURL := r.client.endpoint(r.path)
+	if len(r.params) > 0 {
+		requestURL += "?" + r.params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, requestURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	apiKey, bearer := r.client.apiKey, r.client.apiKey
+	if r.userToken != "" {
+		if r.client.anonKey == "" {
+			return nil, ErrAnonKeyRequired
+		}
+		apiKey, bearer = r.client.anonKey, r.userToken
+	}
+	req.Header.Set("apikey", apiKey)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	if len(r.prefer) > 0 {
+		req.Header.Set("Prefer", strings.Join(r.prefer, ","))
+	}
+
+	resp, err := r.client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &PostgrestResponse{StatusCode: resp.StatusCode, Header: resp.Header}
+	if resp.StatusCode >= 400 {
+		return response, newAPIError(resp.StatusCode, respBody)
+	}
+
+	if result != nil && len(bytes.TrimSpace(respBody)) > 0 {
+		// bigint columns above 2^53 would be rounded through float64
+		decoder := json.NewDecoder(bytes.NewReader(respBody))
+		decoder.UseNumber()
+		if err := decoder.Decode(result); err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+// endpoint joins a path to the base URL
+func (c *SupabaseClientExtended) endpoint(path string) string {
+	return strings.TrimRight(c.baseURL, "/") + path
+}
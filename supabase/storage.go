@@ -0,0 +1,431 @@
+package supabase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrObjectTooLarge is returned when an object exceeds the size allowed
+// for a download
+var ErrObjectTooLarge = errors.New("object is larger than the download limit")
+
+// StorageAPI provides access to the Storage API
+type StorageAPI struct {
+	client *SupabaseClientExtended
+}
+
+// StorageAPI returns the Storage API
+func (c *SupabaseClientExtended) StorageAPI() *StorageAPI {
+	return &StorageAPI{client: c}
+}
+
+// StorageObject represents an object or a folder returned by a listing.
+// Folders have no ID and no metadata.
+type StorageObject struct {
+	Name           string                 `json:"name"`
+	ID             *string                `json:"id"`
+	UpdatedAt      *string                `json:"updated_at"`
+	CreatedAt      *string                `json:"created_at"`
+	LastAccessedAt *string                `json:"last_accessed_at"`
+	Metadata       map[string]interface{} `json:"metadata"`
+}
+
+// ListObjectsOptions selects the objects returned by ListObjects
+type ListObjectsOptions struct {
+	Prefix    string
+	Search    string
+	Limit     int
+	Offset    int
+	SortBy    string
+	SortOrder string
+}
+
+// UploadOptions describes an object to upload
+type UploadOptions struct {
+	ContentType  string
+	CacheControl string
+	Upsert       bool
+}
+
+// ImageTransform resizes or converts an image as it is served
+type ImageTransform struct {
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	Resize  string `json:"resize,omitempty"`
+	Quality int    `json:"quality,omitempty"`
+	Format  string `json:"format,omitempty"`
+}
+
+// query returns the transform as URL query parameters
+func (t *ImageTransform) query() url.Values {
+	values := url.Values{}
+	if t.Width > 0 {
+		values.Set("width", strconv.Itoa(t.Width))
+	}
+	if t.Height > 0 {
+		values.Set("height", strconv.Itoa(t.Height))
+	}
+	if t.Resize != "" {
+		values.Set("resize", t.Resize)
+	}
+	if t.Quality > 0 {
+		values.Set("quality", strconv.Itoa(t.Quality))
+	}
+	if t.Format != "" {
+		values.Set("format", t.Format)
+	}
+	return values
+}
+
+// SignedUpload holds a URL that allows a single upload to a path without
+// other credentials
+type SignedUpload struct {
+	URL   string
+	Token string
+}
+
+// DownloadedObject holds the content of a downloaded object
+type DownloadedObject struct {
+	Data        []byte
+	ContentType string
+}
+
+// ListObjects lists the objects and folders directly under a prefix
+func (s *StorageAPI) ListObjects(ctx context.Context, bucket string, opts ListObjectsOptions) ([]StorageObject, error) {
+	body := map[string]interface{}{
+		"prefix": opts.Prefix,
+		"limit":  opts.Limit,
+		"offset": opts.Offset,
+	}
+	if opts.Search != "" {
+		body["search"] = opts.Search
+	}
+	if opts.SortBy != "" {
+		body["sortBy"] = map[string]string{"column": opts.SortBy, "order": opts.SortOrder}
+	}
+
+	objects := []StorageObject{}
+	err := s.sendJSON(ctx, http.MethodPost, "/object/list/"+url.PathEscape(bucket), body, &objects)
+	return objects, err
+}
+
+// Upload stores data at path, replacing an existing object when upsert is
+// set. It returns the key of the object.
+func (s *StorageAPI) Upload(ctx context.Context, bucket, path string, data []byte, opts UploadOptions) (string, error) {
+	req, err := s.newRequest(withTransferSize(ctx, int64(len(data))), http.MethodPost, "/object/"+objectPath(bucket, path), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if opts.CacheControl != "" {
+		req.Header.Set("Cache-Control", opts.CacheControl)
+	}
+	req.Header.Set("x-upsert", strconv.FormatBool(opts.Upsert))
+
+	var result struct {
+		Key string `json:"Key"`
+	}
+	if err := s.send(req, &result); err != nil {
+		return "", err
+	}
+	return result.Key, nil
+}
+
+// Download reads an object of at most maxSize bytes; larger objects give
+// ErrObjectTooLarge without being transferred in full
+func (s *StorageAPI) Download(ctx context.Context, bucket, path string, maxSize int64) (*DownloadedObject, error) {
+	req, err := s.newRequest(withTransferSize(ctx, maxSize), http.MethodGet, "/object/authenticated/"+objectPath(bucket, path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, newStorageError(resp.StatusCode, body)
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrObjectTooLarge, resp.ContentLength, maxSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrObjectTooLarge, maxSize)
+	}
+
+	return &DownloadedObject{Data: data, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// Move renames an object, optionally into another bucket
+func (s *StorageAPI) Move(ctx context.Context, bucket, from, to, destinationBucket string) error {
+	return s.sendJSON(ctx, http.MethodPost, "/object/move", transferBody(bucket, from, to, destinationBucket), nil)
+}
+
+// Copy copies an object, optionally into another bucket, and returns the
+// key of the copy
+func (s *StorageAPI) Copy(ctx context.Context, bucket, from, to, destinationBucket string) (string, error) {
+	var result struct {
+		Key string `json:"Key"`
+	}
+	err := s.sendJSON(ctx, http.MethodPost, "/object/copy", transferBody(bucket, from, to, destinationBucket), &result)
+	return result.Key, err
+}
+
+// Remove deletes objects and returns those that existed
+func (s *StorageAPI) Remove(ctx context.Context, bucket string, paths []string) ([]StorageObject, error) {
+	removed := []StorageObject{}
+	err := s.sendJSON(ctx, http.MethodDelete, "/object/"+url.PathEscape(bucket), map[string]interface{}{"prefixes": paths}, &removed)
+	return removed, err
+}
+
+// CreateSignedURL returns a URL that grants read access to an object for
+// expiresIn seconds. The URL is relative to the Storage API, as returned by
+// the server; see StorageURL.
+func (s *StorageAPI) CreateSignedURL(ctx context.Context, bucket, path string, expiresIn int, transform *ImageTransform) (string, error) {
+	body := map[string]interface{}{"expiresIn": expiresIn}
+	if transform != nil {
+		body["transform"] = transform
+	}
+
+	var result struct {
+		SignedURL string `json:"signedURL"`
+	}
+	if err := s.sendJSON(ctx, http.MethodPost, "/object/sign/"+objectPath(bucket, path), body, &result); err != nil {
+		return "", err
+	}
+	return result.SignedURL, nil
+}
+
+// CreateSignedUploadURL returns a URL, relative to the Storage API, to which
+// a file can be uploaded once. The Storage API lets it expire after two
+// hours.
+func (s *StorageAPI) CreateSignedUploadURL(ctx context.Context, bucket, path string, upsert bool) (*SignedUpload, error) {
+	req, err := s.newRequest(ctx, http.MethodPost, "/object/upload/sign/"+objectPath(bucket, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-upsert", strconv.FormatBool(upsert))
+
+	var result struct {
+		URL string `json:"url"`
+	}
+	if err := s.send(req, &result); err != nil {
+		return nil, err
+	}
+
+	upload := &SignedUpload{URL: result.URL}
+	if u, err := url.Parse(result.URL); err == nil {
+		upload.Token = u.Query().Get("token")
+	}
+	return upload, nil
+}
+
+// PublicObjectURL returns the URL of an object in a public bucket, served
+// through the image renderer when a transform is given
+func PublicObjectURL(baseURL, bucket, path string, transform *ImageTransform) string {
+	link := strings.TrimRight(baseURL, "/") + "/storage/v1/object/public/" + objectPath(bucket, path)
+	if transform != nil {
+		link = strings.TrimRight(baseURL, "/") + "/storage/v1/render/image/public/" + objectPath(bucket, path)
+		if query := transform.query().Encode(); query != "" {
+			link += "?" + query
+		}
+	}
+	return link
+}
+
+// StorageURL resolves a URL returned by the Storage API against baseURL.
+// Relative URLs are taken to be relative to /storage/v1; absolute ones keep
+// their path but get the scheme and host of baseURL, since the Storage API
+// builds them from the address it was reached at.
+func StorageURL(baseURL, ref string) (string, error) {
+	base, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	if !u.IsAbs() {
+		return base.String() + "/storage/v1/" + strings.TrimLeft(u.String(), "/"), nil
+	}
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	return u.String(), nil
+}
+
+// BucketOptions holds the settings of a bucket. Nil fields are left out,
+// so an update keeps their current value.
+type BucketOptions struct {
+	Public        *bool
+	FileSizeLimit *int64
+	// ClearFileSizeLimit removes the file size limit
+	ClearFileSizeLimit bool
+	// AllowedMimeTypes restricts the types of uploaded files; an empty,
+	// non-nil list removes the restriction
+	AllowedMimeTypes []string
+}
+
+// body returns the settings in the form the bucket endpoints expect
+func (o BucketOptions) body() map[string]interface{} {
+	body := map[string]interface{}{}
+	if o.Public != nil {
+		body["public"] = *o.Public
+	}
+	if o.FileSizeLimit != nil {
+		body["file_size_limit"] = *o.FileSizeLimit
+	} else if o.ClearFileSizeLimit {
+		body["file_size_limit"] = nil
+	}
+	if o.AllowedMimeTypes != nil {
+		if len(o.AllowedMimeTypes) > 0 {
+			body["allowed_mime_types"] = o.AllowedMimeTypes
+		} else {
+			body["allowed_mime_types"] = nil
+		}
+	}
+	return body
+}
+
+// CreateBucket creates a bucket
+func (s *StorageAPI) CreateBucket(ctx context.Context, id, name string, opts BucketOptions) error {
+	body := opts.body()
+	body["id"] = id
+	body["name"] = name
+	return s.sendJSON(ctx, http.MethodPost, "/bucket", body, nil)
+}
+
+// UpdateBucket changes the settings of a bucket
+func (s *StorageAPI) UpdateBucket(ctx context.Context, id string, opts BucketOptions) error {
+	return s.sendJSON(ctx, http.MethodPut, "/bucket/"+url.PathEscape(id), opts.body(), nil)
+}
+
+// EmptyBucket deletes every object in a bucket. The Storage API deletes
+// them in batches in the background, so objects may remain for a while
+// after it returns.
+func (s *StorageAPI) EmptyBucket(ctx context.Context, id string) error {
+	return s.sendJSON(ctx, http.MethodPost, "/bucket/"+url.PathEscape(id)+"/empty", map[string]interface{}{}, nil)
+}
+
+// DeleteBucket deletes a bucket, which must be empty
+func (s *StorageAPI) DeleteBucket(ctx context.Context, id string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, "/bucket/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	return s.send(req, nil)
+}
+
+func transferBody(bucket, from, to, destinationBucket string) map[string]string {
+	body := map[string]string{
+		"bucketId":       bucket,
+		"sourceKey":      from,
+		"destinationKey": to,
+	}
+	if destinationBucket != "" {
+		body["destinationBucket"] = destinationBucket
+	}
+	return body
+}
+
+// objectPath escapes each segment of an object path, keeping the slashes
+func objectPath(bucket, path string) string {
+	segments := strings.Split(strings.TrimLeft(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return url.PathEscape(bucket) + "/" + strings.Join(segments, "/")
+}
+
+// newRequest creates a request to the Storage API authorized with the
+// service role key
+func (s *StorageAPI) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.client.endpoint("/storage/v1"+path), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("apikey", s.client.apiKey)
+	req.Header.Set("Authorization", "Bearer "+s.client.apiKey)
+	return req, nil
+}
+
+// sendJSON sends a JSON body and decodes the JSON response into result
+func (s *StorageAPI) sendJSON(ctx context.Context, method, path string, body interface{}, result interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := s.newRequest(ctx, method, path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return s.send(req, result)
+}
+
+// send performs a request and decodes the JSON response into result.
+// Error responses are returned as *APIError.
+func (s *StorageAPI) send(req *http.Request, result interface{}) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return newStorageError(resp.StatusCode, body)
+	}
+
+	if result != nil && len(bytes.TrimSpace(body)) > 0 {
+		return json.Unmarshal(body, result)
+	}
+	return nil
+}
+
+// newStorageError decodes a Storage API error. The API reports some errors,
+// such as missing objects, with status 400 and the real status in the
+// statusCode field, which is preferred.
+func newStorageError(status int, body []byte) *APIError {
+	apiErr := newAPIError(status, body)
+
+	var storageErr struct {
+		StatusCode string `json:"statusCode"`
+		Error      string `json:"error"`
+	}
+	if json.Unmarshal(body, &storageErr) == nil {
+		if code, err := strconv.Atoi(storageErr.StatusCode); err == nil && code >= 400 && code < 600 {
+			apiErr.Status = code
+		}
+		if apiErr.Code == "" {
+			apiErr.Code = storageErr.Error
+		}
+	}
+	return apiErr
+}
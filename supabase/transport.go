@@ -0,0 +1,213 @@
+package supabase
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultHTTPTimeout  = 30 * time.Second
+	defaultMaxIdleConns = 100
+	defaultMaxRetries   = 2
+
+	retryBaseDelay = 200 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+
+	// minTransferRate is the slowest transfer, in bytes per second, that
+	// uploads and downloads are given time for
+	minTransferRate = 256 << 10
+)
+
+// ClientOption configures a SupabaseClientExtended
+type ClientOption func(*SupabaseClientExtended)
+
+// WithHTTPClient sets the HTTP client used for the Supabase APIs
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *SupabaseClientExtended) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how often idempotent requests are retried after a 502,
+// 503 or 504 response; zero disables retries
+func WithRetries(retries int) ClientOption {
+	return func(c *SupabaseClientExtended) {
+		if retries < 0 {
+			retries = 0
+		}
+		c.maxRetries = retries
+	}
+}
+
+// WithAnonKey sets the anon key used for requests made as a user
+func WithAnonKey(anonKey string) ClientOption {
+	return func(c *SupabaseClientExtended) {
+		c.anonKey = anonKey
+	}
+}
+
+// WithTUSEndpoint sends resumable uploads to url instead of the Storage
+// API, e.g. to a TUSStub
+func WithTUSEndpoint(url string) ClientOption {
+	return func(c *SupabaseClientExtended) {
+		c.tusURL = url
+	}
+}
+
+// NewHTTPClient creates an HTTP client with a pool of keep-alive
+// connections sized for a single upstream host (Kong). Requests whose
+// context has no deadline are given timeout, which also covers reading the
+// response body; requests with a deadline of their own, such as an
+// execute_query timeout, keep it even when it is longer.
+func NewHTTPClient(timeout time.Duration, maxIdleConns int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if maxIdleConns > 0 {
+		transport.MaxIdleConns = maxIdleConns
+		transport.MaxIdleConnsPerHost = maxIdleConns
+	}
+	transport.IdleConnTimeout = 90 * time.Second
+
+	return &http.Client{
+		Transport: &timeoutTransport{base: transport, timeout: timeout},
+	}
+}
+
+// timeoutTransport applies the default timeout of a client per request,
+// through the request context
+type timeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Deadline(); ok || t.timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	timeout := t.timeout
+	if size, ok := req.Context().Value(transferSizeKey{}).(int64); ok && size > 0 {
+		timeout += time.Duration(size/minTransferRate) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the timeout of a request once its response body
+// is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// transferSizeKey holds the number of bytes a request sends or receives
+type transferSizeKey struct{}
+
+// withTransferSize extends the default timeout of the requests made with
+// ctx by the time needed to move size bytes at minTransferRate, so that
+// large uploads and downloads are not cut off after the usual timeout
+func withTransferSize(ctx context.Context, size int64) context.Context {
+	return context.WithValue(ctx, transferSizeKey{}, size)
+}
+
+// HTTPClient returns the HTTP client shared by every Supabase API call
+func (c *SupabaseClientExtended) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+// do sends a request with the shared client. Idempotent requests are
+// retried with exponential backoff and jitter when the gateway answers
+// 502, 503 or 504; the request context bounds the whole exchange.
+func (c *SupabaseClientExtended) do(req *http.Request) (*http.Response, error) {
+	retries := c.maxRetries
+	if !idempotent(req.Method) || (req.Body != nil && req.GetBody == nil) {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := c.httpClient.Do(attemptReq)
+		if err != nil || attempt >= retries || !retryable(resp.StatusCode) {
+			return resp, err
+		}
+
+		delay := retryDelay(attempt, resp.Header.Get("Retry-After"))
+		resp.Body.Close()
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// idempotent reports whether a request can be repeated safely
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func retryable(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// retryDelay returns the wait before the next attempt: the Retry-After
+// header when the server sends one, otherwise exponential backoff with
+// jitter so that concurrent callers do not retry in lockstep
+func retryDelay(attempt int, retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		delay := time.Duration(seconds) * time.Second
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+		return delay
+	}
+
+	backoff := retryBaseDelay << attempt
+	if backoff <= 0 || backoff > retryMaxDelay {
+		backoff = retryMaxDelay
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,47 @@
+package supabase
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientTimeoutPerRequest(t *testing.T) {
+	// The headers arrive at once; the body only after a delay
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(100*time.Millisecond, 1)
+	get := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		return err
+	}
+
+	if err := get(context.Background()); err == nil {
+		t.Error("request without a deadline outlived the default timeout")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := get(ctx); err != nil {
+		t.Errorf("request with its own deadline: %v", err)
+	}
+
+	if err := get(withTransferSize(context.Background(), minTransferRate)); err != nil {
+		t.Errorf("request with a transfer size: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package supabase
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ResumableChunkSize is the size the Storage API requires for every chunk
+// of a resumable upload except the last
+const ResumableChunkSize = 6 << 20
+
+// tusVersion is the version of the TUS protocol spoken by the Storage API
+const tusVersion = "1.0.0"
+
+// CreateResumableUpload starts a TUS upload of size bytes to path and
+// returns the URL of the upload, to which the chunks are sent
+func (s *StorageAPI) CreateResumableUpload(ctx context.Context, bucket, path string, size int64, opts UploadOptions) (string, error) {
+	endpoint := s.tusEndpoint()
+	req, err := s.newTUSRequest(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	metadata := []string{
+		"bucketName " + base64.StdEncoding.EncodeToString([]byte(bucket)),
+		"objectName " + base64.StdEncoding.EncodeToString([]byte(strings.TrimLeft(path, "/"))),
+	}
+	if opts.ContentType != "" {
+		metadata = append(metadata, "contentType "+base64.StdEncoding.EncodeToString([]byte(opts.ContentType)))
+	}
+	if opts.CacheControl != "" {
+		metadata = append(metadata, "cacheControl "+base64.StdEncoding.EncodeToString([]byte(opts.CacheControl)))
+	}
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("Upload-Metadata", strings.Join(metadata, ","))
+	req.Header.Set("x-upsert", strconv.FormatBool(opts.Upsert))
+
+	resp, err := s.sendTUS(req, http.StatusCreated)
+	if err != nil {
+		return "", err
+	}
+	return s.uploadURL(endpoint, resp.Header.Get("Location"))
+}
+
+// UploadResumableChunk sends the chunk that starts at offset and returns
+// the offset the upload has reached
+func (s *StorageAPI) UploadResumableChunk(ctx context.Context, uploadURL string, offset int64, chunk []byte) (int64, error) {
+	req, err := s.newTUSRequest(withTransferSize(ctx, int64(len(chunk))), http.MethodPatch, uploadURL, bytes.NewReader(chunk))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+
+	resp, err := s.sendTUS(req, http.StatusNoContent)
+	if err != nil {
+		return 0, err
+	}
+	return uploadOffset(resp)
+}
+
+// ResumableUploadOffset returns how many bytes of an upload the Storage API
+// has received
+func (s *StorageAPI) ResumableUploadOffset(ctx context.Context, uploadURL string) (int64, error) {
+	req, err := s.newTUSRequest(ctx, http.MethodHead, uploadURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.sendTUS(req, http.StatusOK)
+	if err != nil {
+		return 0, err
+	}
+	return uploadOffset(resp)
+}
+
+// CancelResumableUpload discards an unfinished upload
+func (s *StorageAPI) CancelResumableUpload(ctx context.Context, uploadURL string) error {
+	req, err := s.newTUSRequest(ctx, http.MethodDelete, uploadURL, nil)
+	if err != nil {
+		return err
+	}
+	_, err = s.sendTUS(req, http.StatusNoContent)
+	return err
+}
+
+// tusEndpoint returns the URL resumable uploads are created at
+func (s *StorageAPI) tusEndpoint() string {
+	if s.client.tusURL != "" {
+		return s.client.tusURL
+	}
+	return s.client.endpoint("/storage/v1/upload/resumable")
+}
+
+// uploadURL resolves the Location of a new upload. The Storage API builds
+// it from the forwarded host, which may only be reachable from outside, so
+// the URL keeps its path but gets the scheme and host of the endpoint.
+func (s *StorageAPI) uploadURL(endpoint, location string) (string, error) {
+	if location == "" {
+		return "", fmt.Errorf("storage API did not return the upload location")
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid upload location %q: %w", location, err)
+	}
+	u := base.ResolveReference(ref)
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	return u.String(), nil
+}
+
+// newTUSRequest creates a TUS request authorized with the service role key
+func (s *StorageAPI) newTUSRequest(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("apikey", s.client.apiKey)
+	req.Header.Set("Authorization", "Bearer "+s.client.apiKey)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	return req, nil
+}
+
+// sendTUS performs a TUS request and checks that it got the expected
+// status. Error responses are returned as *APIError.
+func (s *StorageAPI) sendTUS(req *http.Request, status int) (*http.Response, error) {
+	resp, err := s.client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != status {
+		if resp.StatusCode < 400 {
+			return nil, &APIError{Status: http.StatusBadGateway, Message: fmt.Sprintf("unexpected status %d from the resumable upload endpoint", resp.StatusCode)}
+		}
+		return nil, newStorageError(resp.StatusCode, body)
+	}
+	return resp, nil
+}
+
+// uploadOffset reads the Upload-Offset header of a TUS response
+func uploadOffset(resp *http.Response) (int64, error) {
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Upload-Offset %q in the storage API response", resp.Header.Get("Upload-Offset"))
+	}
+	return offset, nil
+}
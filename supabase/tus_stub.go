@@ -0,0 +1,241 @@
+package supabase
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TUSStub is a minimal TUS server standing in for the resumable upload
+// endpoint of the Storage API, for testing resumable uploads without
+// Supabase. It enforces the same chunk size rule and writes finished
+// uploads to <dir>/<bucket>/<object path>. It must never be enabled in
+// production.
+type TUSStub struct {
+	// URL is the endpoint uploads are created at
+	URL string
+
+	dir      string
+	listener net.Listener
+
+	mu      sync.Mutex
+	uploads map[string]*stubUpload
+}
+
+// stubUpload is an upload in progress on the stub
+type stubUpload struct {
+	bucket string
+	object string
+	length int64
+	offset int64
+	file   *os.File
+}
+
+// StartTUSStub serves a TUS stub on a loopback port, writing files under dir
+func StartTUSStub(dir string) (*TUSStub, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	stub := &TUSStub{
+		URL:      fmt.Sprintf("http://%s/upload/resumable", listener.Addr()),
+		dir:      dir,
+		listener: listener,
+		uploads:  make(map[string]*stubUpload),
+	}
+	go http.Serve(listener, stub)
+	return stub, nil
+}
+
+// Close stops the stub
+func (s *TUSStub) Close() error {
+	return s.listener.Close()
+}
+
+// ServeHTTP implements http.Handler
+func (s *TUSStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		stubError(w, http.StatusPreconditionFailed, "unsupported TUS version")
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/upload/resumable"), "/")
+	if id == "" {
+		if r.Method != http.MethodPost {
+			stubError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		s.create(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, ok := s.uploads[id]
+	if !ok {
+		stubError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.length, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		s.patch(w, r, upload)
+	case http.MethodDelete:
+		upload.file.Close()
+		os.Remove(upload.file.Name())
+		delete(s.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		stubError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// create starts an upload from the Upload-Length and Upload-Metadata headers
+func (s *TUSStub) create(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		stubError(w, http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(r.Header.Get("Upload-Metadata"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			stubError(w, http.StatusBadRequest, "invalid Upload-Metadata")
+			return
+		}
+		metadata[key] = string(decoded)
+	}
+	bucket, object := metadata["bucketName"], metadata["objectName"]
+	if bucket == "" || object == "" {
+		stubError(w, http.StatusBadRequest, "bucketName and objectName are required")
+		return
+	}
+	if strings.Contains(bucket, "/") || !stubPath(bucket) || !stubPath(object) {
+		stubError(w, http.StatusBadRequest, "bucketName and objectName must not contain empty, '.' or '..' segments")
+		return
+	}
+	if r.Header.Get("x-upsert") != "true" {
+		if _, err := os.Stat(filepath.Join(s.dir, bucket, filepath.FromSlash(object))); err == nil {
+			stubError(w, http.StatusConflict, "The resource already exists")
+			return
+		}
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		stubError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	id := hex.EncodeToString(idBytes)
+
+	file, err := os.CreateTemp(s.dir, "upload-*.part")
+	if err != nil {
+		stubError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.uploads[id] = &stubUpload{bucket: bucket, object: object, length: length, file: file}
+	s.mu.Unlock()
+
+	w.Header().Set("Location", s.URL+"/"+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// patch appends a chunk and moves the file into place once it is complete.
+// Like the Storage API, it only accepts chunks of ResumableChunkSize bytes
+// except for the last one. Finished uploads are kept so that their offset
+// can still be read.
+func (s *TUSStub) patch(w http.ResponseWriter, r *http.Request, upload *stubUpload) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		stubError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+	if upload.offset == upload.length {
+		stubError(w, http.StatusConflict, "upload is already complete")
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset != upload.offset {
+		stubError(w, http.StatusConflict, fmt.Sprintf("Upload-Offset must be %d", upload.offset))
+		return
+	}
+
+	chunk, err := io.ReadAll(io.LimitReader(r.Body, ResumableChunkSize+1))
+	if err != nil {
+		stubError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	last := offset+int64(len(chunk)) == upload.length
+	if offset+int64(len(chunk)) > upload.length || (!last && len(chunk) != ResumableChunkSize) {
+		stubError(w, http.StatusBadRequest, fmt.Sprintf("chunks must be %d bytes except the last", ResumableChunkSize))
+		return
+	}
+
+	if _, err := upload.file.Write(chunk); err != nil {
+		stubError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	upload.offset += int64(len(chunk))
+
+	if last {
+		upload.file.Close()
+		target := filepath.Join(s.dir, upload.bucket, filepath.FromSlash(upload.object))
+		err := os.MkdirAll(filepath.Dir(target), 0o755)
+		if err == nil {
+			err = os.Rename(upload.file.Name(), target)
+		}
+		if err != nil {
+			stubError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stubPath reports whether an object path stays inside its bucket
+// directory: no segment may be empty, "." or "..", and backslashes are
+// refused since they separate paths on Windows
+func stubPath(path string) bool {
+	if strings.Contains(path, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// stubError writes an error shaped like the Storage API's
+func stubError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"statusCode":"%d","error":%q,"message":%q}`, status, http.StatusText(status), message)
+}
@@ -0,0 +1,108 @@
+package supabase
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func startTestStub(t *testing.T) (*TUSStub, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "stub")
+	stub, err := StartTUSStub(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stub.Close() })
+	return stub, dir
+}
+
+// createStubUpload starts an upload on the stub and returns its status and
+// location
+func createStubUpload(t *testing.T, stub *TUSStub, bucket, object string, length int) (int, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, stub.URL, nil)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Upload-Length", strconv.Itoa(length))
+	req.Header.Set("Upload-Metadata", "bucketName "+base64.StdEncoding.EncodeToString([]byte(bucket))+
+		",objectName "+base64.StdEncoding.EncodeToString([]byte(object)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("Location")
+}
+
+func patchStubUpload(t *testing.T, location string, offset int, chunk []byte) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPatch, location, bytes.NewReader(chunk))
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestTUSStubRejectsPathsOutsideItsDirectory(t *testing.T) {
+	stub, _ := startTestStub(t)
+
+	paths := []struct{ bucket, object string }{
+		{"..", "escape.txt"},
+		{".", "escape.txt"},
+		{"docs", "../escape.txt"},
+		{"docs", "a/../../escape.txt"},
+		{"docs", "./a.txt"},
+		{"docs", "/etc/escape.txt"},
+		{"docs", "a//b.txt"},
+		{"docs", `..\escape.txt`},
+		{"a/b", "c.txt"},
+		{"", "c.txt"},
+		{"docs", ""},
+	}
+	for _, p := range paths {
+		if status, _ := createStubUpload(t, stub, p.bucket, p.object, 1); status != http.StatusBadRequest {
+			t.Errorf("create %q/%q: status %d, want 400", p.bucket, p.object, status)
+		}
+	}
+
+	if status, _ := createStubUpload(t, stub, "docs", "a/b..c/d.txt", 1); status != http.StatusCreated {
+		t.Errorf("create docs/a/b..c/d.txt: status %d, want 201", status)
+	}
+}
+
+func TestTUSStubCompletedUpload(t *testing.T) {
+	stub, dir := startTestStub(t)
+
+	status, location := createStubUpload(t, stub, "docs", "notes/a.txt", 5)
+	if status != http.StatusCreated {
+		t.Fatalf("create: status %d, want 201", status)
+	}
+	if status := patchStubUpload(t, location, 0, []byte("hello")); status != http.StatusNoContent {
+		t.Fatalf("patch: status %d, want 204", status)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "docs", "notes", "a.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("finished upload = %q, %v", data, err)
+	}
+
+	// Another PATCH, even an empty one, must not touch the finished file
+	if status := patchStubUpload(t, location, 5, nil); status != http.StatusConflict {
+		t.Errorf("patch after completion: status %d, want 409", status)
+	}
+	if status := patchStubUpload(t, location, 0, []byte("HELLO")); status != http.StatusConflict {
+		t.Errorf("patch at offset 0 after completion: status %d, want 409", status)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "docs", "notes", "a.txt")); string(data) != "hello" {
+		t.Errorf("finished upload changed to %q", data)
+	}
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Errors returned by VerifyConfirmToken
+var (
+	ErrConfirmTokenInvalid = errors.New("confirmation token is invalid or was issued for a different statement")
+	ErrConfirmTokenExpired = errors.New("confirmation token has expired")
+)
+
+// NewConfirmToken signs payload with secret and returns a token that
+// VerifyConfirmToken accepts for the same payload until expiresAt
+func NewConfirmToken(secret []byte, payload string, expiresAt time.Time) string {
+	buf := make([]byte, 8, 8+sha256.Size)
+	binary.BigEndian.PutUint64(buf, uint64(expiresAt.Unix()))
+	buf = append(buf, confirmMAC(secret, buf[:8], payload)...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// VerifyConfirmToken checks that token was issued for payload and has not
+// expired
+func VerifyConfirmToken(secret []byte, payload, token string) error {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(buf) != 8+sha256.Size {
+		return ErrConfirmTokenInvalid
+	}
+
+	if !hmac.Equal(buf[8:], confirmMAC(secret, buf[:8], payload)) {
+		return ErrConfirmTokenInvalid
+	}
+
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(buf[:8])), 0)
+	if time.Now().After(expiresAt) {
+		return ErrConfirmTokenExpired
+	}
+	return nil
+}
+
+func confirmMAC(secret, expiry []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(expiry)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -17,15 +17,3 @@ func ToPascalCase(str string) string {
 	}
 	return strings.Join(words, "")
 }
-
-// IsReadOnlyQuery checks if a SQL query is read-only
-func IsReadOnlyQuery(query string) bool {
-	lowerQuery := strings.ToLower(strings.TrimSpace(query))
-	return strings.HasPrefix(lowerQuery, "select") &&
-		!strings.Contains(lowerQuery, "delete") &&
-		!strings.Contains(lowerQuery, "insert") &&
-		!strings.Contains(lowerQuery, "update") &&
-		!strings.Contains(lowerQuery, "drop") &&
-		!strings.Contains(lowerQuery, "alter") &&
-		!strings.Contains(lowerQuery, "create")
-}